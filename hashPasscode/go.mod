@@ -1,5 +0,0 @@
-module hashPasscode
-
-go 1.24.0
-
-require golang.org/x/crypto v0.35.0
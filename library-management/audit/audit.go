@@ -0,0 +1,149 @@
+// Package audit appends who-changed-what events to the audit log. Events are
+// written with the transaction that makes the change, so a rolled back change
+// leaves no event and a committed one always has its event.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"library-management/models"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Actor is the authenticated caller behind a change
+type Actor struct {
+	ID        *uint
+	Role      string
+	RequestID string
+	ClientIP  string
+}
+
+// System is the actor of changes no user asked for, such as those of scheduled
+// jobs. Its events have no actor ID.
+var System = Actor{Role: "system"}
+
+type actorKey struct{}
+
+// WithActor returns a context that attributes changes made under it to actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor attached to ctx, if any
+func ActorFrom(ctx context.Context) (Actor, bool) {
+	if ctx == nil {
+		return Actor{}, false
+	}
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
+
+// Context attributes changes to the caller identified by AuthMiddleware and RequestID.
+// Pass it to gorm with db.WithContext so Record can find the actor.
+func Context(c *gin.Context) context.Context {
+	actor := Actor{
+		Role:      c.GetString("userRole"),
+		RequestID: c.GetString("requestID"),
+		ClientIP:  c.ClientIP(),
+	}
+	if userID, ok := c.Get("userID"); ok {
+		if id, ok := userID.(uint); ok {
+			actor.ID = &id
+		}
+	}
+	return WithActor(c.Request.Context(), actor)
+}
+
+// ignoredFields never appear in an event: timestamps change on every write and secrets must not be copied
+var ignoredFields = map[string]bool{
+	"CreatedAt": true, "UpdatedAt": true, "DeletedAt": true,
+	"created_at": true, "updated_at": true, "deleted_at": true,
+	"Password": true, "password": true,
+}
+
+// Record appends an event for a change made in tx. before is nil for a create
+// and after nil for a delete. Changes made without an actor in tx's context,
+// such as those of scheduled jobs, are attributed to System.
+func Record(tx *gorm.DB, action, entityType string, entityID interface{}, before, after interface{}) error {
+	actor, ok := ActorFrom(tx.Statement.Context)
+	if !ok {
+		actor = System
+	}
+
+	beforeJSON, afterJSON, err := Diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	event := models.AuditEvent{
+		OccurredAt: time.Now().Unix(),
+		ActorID:    actor.ID,
+		ActorRole:  actor.Role,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		Before:     beforeJSON,
+		After:      afterJSON,
+		RequestID:  actor.RequestID,
+		ClientIP:   actor.ClientIP,
+	}
+	return tx.Create(&event).Error
+}
+
+// Diff reduces before and after to the fields that differ between them. When one
+// side is nil the other is returned in full, recording a create or a delete.
+func Diff(before, after interface{}) (models.JSON, models.JSON, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b != nil && a != nil {
+		for name, value := range a {
+			if old, ok := b[name]; ok && reflect.DeepEqual(old, value) {
+				delete(a, name)
+				delete(b, name)
+			}
+		}
+	}
+	return encode(b), encode(a), nil
+}
+
+// fields flattens an entity to its JSON fields, without the ignored ones
+func fields(entity interface{}) (map[string]interface{}, error) {
+	if entity == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("audit: %T is not an object", entity)
+	}
+	for name := range out {
+		if ignoredFields[name] {
+			delete(out, name)
+		}
+	}
+	return out, nil
+}
+
+func encode(fields map[string]interface{}) models.JSON {
+	if fields == nil {
+		return nil
+	}
+	raw, _ := json.Marshal(fields) // Decoded from JSON, so it always encodes
+	return raw
+}
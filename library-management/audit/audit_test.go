@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+	"library-management/models"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+	return gormDB, mock
+}
+
+func TestDiff(t *testing.T) {
+	before := models.Book{ID: 3, ISBN: "123", Title: "Dune", TotalCopies: 2}
+	after := before
+	after.Title = "Dune Messiah"
+
+	b, a, err := Diff(before, after)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Title":"Dune"}`, string(b))
+	assert.JSONEq(t, `{"Title":"Dune Messiah"}`, string(a))
+
+	// Creates and deletes keep the whole entity, but never its password or timestamps
+	b, a, err = Diff(nil, models.User{ID: 4, Name: "Ada", Password: "hash"})
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+	assert.Contains(t, string(a), `"Name":"Ada"`)
+	assert.NotContains(t, string(a), "hash")
+	assert.NotContains(t, string(a), "CreatedAt")
+
+	_, _, err = Diff("not an entity", nil)
+	assert.Error(t, err)
+}
+
+func TestRecord(t *testing.T) {
+	db, mock := newTestDB(t)
+	adminID := uint(9)
+	ctx := WithActor(context.Background(), Actor{ID: &adminID, Role: "admin", RequestID: "req-1", ClientIP: "192.0.2.1"})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_events" ("occurred_at","actor_id","actor_role","action","entity_type","entity_id","before","after","request_id","client_ip") VALUES`)).
+		WithArgs(sqlmock.AnyArg(), 9, "admin", "book.update", "book", "3", `{"Title":"Dune"}`, `{"Title":"Dune Messiah"}`, "req-1", "192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return Record(tx, "book.update", "book", 3, models.Book{Title: "Dune"}, models.Book{Title: "Dune Messiah"})
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordWithoutActor(t *testing.T) {
+	db, mock := newTestDB(t)
+
+	// Scheduled jobs run without a signed-in user; their changes are the system's
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_events"`)).
+		WithArgs(sqlmock.AnyArg(), nil, "system", "loan.overdue", "loan", "7", nil, sqlmock.AnyArg(), "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, Record(db, "loan.overdue", "loan", 7, nil, models.IssueRegistry{IssueStatus: "issued"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request.RemoteAddr = "192.0.2.1:4000"
+	c.Set("userID", uint(9))
+	c.Set("userRole", "admin")
+	c.Set("requestID", "req-1")
+
+	actor, ok := ActorFrom(Context(c))
+	assert.True(t, ok)
+	assert.Equal(t, uint(9), *actor.ID)
+	assert.Equal(t, "admin", actor.Role)
+	assert.Equal(t, "req-1", actor.RequestID)
+	assert.Equal(t, "192.0.2.1", actor.ClientIP)
+}
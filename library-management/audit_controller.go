@@ -0,0 +1,78 @@
+// 🧾 Audit Log
+package controllers
+
+import (
+	"library-management/pagination"
+	"library-management/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditEvents pages through audit events, most recent first, filtered by ?actor_id=,
+// ?entity_type=, ?entity_id= and a ?from= / ?to= time range in RFC 3339 - Only Owner
+func ListAuditEvents(auditLog services.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := pagination.Parse(c, services.AuditSort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter := services.AuditFilter{Page: req}
+
+		if actorID := c.Query("actor_id"); actorID != "" {
+			id, err := strconv.ParseUint(actorID, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor ID"})
+				return
+			}
+			filter.ActorID = uint(id)
+		}
+		filter.EntityType = c.Query("entity_type")
+		filter.EntityID = c.Query("entity_id")
+		for _, bound := range []struct {
+			param string
+			at    **time.Time
+		}{
+			{"from", &filter.From},
+			{"to", &filter.To},
+		} {
+			value := c.Query(bound.param)
+			if value == "" {
+				continue
+			}
+			at, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + bound.param + " time, use RFC 3339 such as 2025-01-31T00:00:00Z"})
+				return
+			}
+			*bound.at = &at
+		}
+
+		events, page, err := auditLog.ListEvents(c.Request.Context(), filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch audit events"})
+			return
+		}
+
+		formattedEvents := make([]gin.H, len(events))
+		for i, event := range events {
+			formattedEvents[i] = gin.H{
+				"id":          event.ID,
+				"occurred_at": formatUnixTime(&event.OccurredAt),
+				"actor_id":    event.ActorID,
+				"actor_role":  event.ActorRole,
+				"action":      event.Action,
+				"entity_type": event.EntityType,
+				"entity_id":   event.EntityID,
+				"before":      event.Before,
+				"after":       event.After,
+				"request_id":  event.RequestID,
+				"client_ip":   event.ClientIP,
+			}
+		}
+		c.JSON(http.StatusOK, pagination.Envelope(formattedEvents, page))
+	}
+}
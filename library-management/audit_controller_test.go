@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"fmt"
+	"library-management/services"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// expectAudit expects the audit event a handler appends inside its transaction
+func expectAudit(mock sqlmock.Sqlmock, action, entityType string, entityID interface{}) {
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_events"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), action, entityType, fmt.Sprint(entityID), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+func TestListAuditEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/audit", ListAuditEvents(services.NewAuditService(gormDB)))
+
+	t.Run("Filtered by actor, entity and time range", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audit_events" WHERE actor_id = $1 AND entity_type = $2 AND entity_id = $3 AND occurred_at >= $4 AND occurred_at <= $5 ORDER BY occurred_at DESC, id DESC LIMIT $6`)).
+			WithArgs(3, "book", "9", 1735689600, 1738281600, 21).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "occurred_at", "actor_id", "actor_role", "action", "entity_type", "entity_id", "before", "after", "request_id", "client_ip"}).
+				AddRow(5, 1736000000, 3, "admin", "book.update", "book", "9", `{"Title":"Dune"}`, `{"Title":"Dune Messiah"}`, "req-1", "192.0.2.1"))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/audit?actor_id=3&entity_type=book&entity_id=9&from=2025-01-01T00:00:00Z&to=2025-01-31T00:00:00Z", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"action":"book.update"`)
+		assert.Contains(t, w.Body.String(), `"before":{"Title":"Dune"}`)
+		assert.Contains(t, w.Body.String(), `"after":{"Title":"Dune Messiah"}`)
+		assert.Contains(t, w.Body.String(), `"page":{"limit":20,"sort":"-occurred_at"}`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid time range", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/audit?from=yesterday", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid from time")
+	})
+}
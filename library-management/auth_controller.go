@@ -1,16 +1,15 @@
 package controllers
 
 import (
-	"library-management/models"
-	"library-management/utils"
+	"errors"
+	"library-management/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-// ✅ Accept `db` as a parameter to break the dependency cycle
-func Login(db *gorm.DB) gin.HandlerFunc {
+// ✅ Accept the auth service as a parameter to break the dependency cycle
+func Login(auth services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
 			Email    string `json:"email" binding:"required"`
@@ -23,30 +22,17 @@ func Login(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var user models.User
-		// Adjust query for PostgreSQL
-		if err := db.Where("email = ? AND deleted_at IS NULL", input.Email).First(&user).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			}
-			return
-		}
-
-		// Directly compare passwords
-		if input.Password != user.Password {
+		// Generate access token and start a new refresh token family
+		pair, err := auth.Login(c.Request.Context(), input.Email, input.Password)
+		if errors.Is(err, services.ErrInvalidCredentials) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 			return
 		}
-
-		// Generate JWT token
-		token, err := utils.GenerateJWT(user.ID, user.Role)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not log in"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"token": token})
+		c.JSON(http.StatusOK, tokenResponse(pair))
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"errors"
 	"library-management/models"
+	"library-management/services"
+	"library-management/utils"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
@@ -13,6 +15,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -31,7 +34,20 @@ func TestLogin(t *testing.T) {
 	// Initialize Gin router
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
-	router.POST("/login", Login(gormDB))
+	router.POST("/login", Login(services.NewAuthService(gormDB)))
+
+	// Hash at minimum cost so the tests stay fast and no rehash is triggered
+	utils.SetPasswordHasher(utils.NewBcryptHasher(bcrypt.MinCost))
+	hashedPassword, err := utils.HashPassword("password123")
+	assert.NoError(t, err)
+
+	// Every successful login persists a new refresh token
+	expectRefreshTokenInsert := func() {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "refresh_tokens"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+	}
 
 	// Mock user data
 	mockUser := models.User{
@@ -52,11 +68,30 @@ func TestLogin(t *testing.T) {
 		{
 			name:  "Valid credentials",
 			input: `{"email": "testuser@example.com", "password": "password123"}`,
+			mockQuery: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE (email = $1 AND deleted_at IS NULL) AND "users"."deleted_at" IS NULL ORDER BY "users"."id" LIMIT $2`)).
+					WithArgs(mockUser.Email, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password", "role"}).
+						AddRow(mockUser.ID, mockUser.Email, hashedPassword, mockUser.Role))
+				expectRefreshTokenInsert()
+			},
+			expectedStatus: http.StatusOK,
+			expectedError:  "",
+		},
+		{
+			name:  "Legacy plaintext password is rehashed",
+			input: `{"email": "testuser@example.com", "password": "password123"}`,
 			mockQuery: func() {
 				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE (email = $1 AND deleted_at IS NULL) AND "users"."deleted_at" IS NULL ORDER BY "users"."id" LIMIT $2`)).
 					WithArgs(mockUser.Email, 1).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password", "role"}).
 						AddRow(mockUser.ID, mockUser.Email, mockUser.Password, mockUser.Role))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "password"=$1`)).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				expectRefreshTokenInsert()
 			},
 			expectedStatus: http.StatusOK,
 			expectedError:  "",
@@ -68,7 +103,7 @@ func TestLogin(t *testing.T) {
 				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE (email = $1 AND deleted_at IS NULL) AND "users"."deleted_at" IS NULL ORDER BY "users"."id" LIMIT $2`)).
 					WithArgs(mockUser.Email, 1).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password", "role"}).
-						AddRow(mockUser.ID, mockUser.Email, "password123", mockUser.Role))
+						AddRow(mockUser.ID, mockUser.Email, hashedPassword, mockUser.Role))
 			},
 			expectedStatus: http.StatusUnauthorized,
 			expectedError:  "Invalid credentials",
@@ -100,7 +135,7 @@ func TestLogin(t *testing.T) {
 					WillReturnError(errors.New("database error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedError:  "Database error",
+			expectedError:  "Could not log in",
 		},
 		// New Edge Cases
 		{
@@ -133,7 +168,8 @@ func TestLogin(t *testing.T) {
 					WithArgs(mockUser.Email, 1).
 					WillDelayFor(2 * time.Second). // Simulating a delay
 					WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password", "role"}).
-						AddRow(mockUser.ID, mockUser.Email, mockUser.Password, mockUser.Role))
+						AddRow(mockUser.ID, mockUser.Email, hashedPassword, mockUser.Role))
+				expectRefreshTokenInsert()
 			},
 			expectedStatus: http.StatusOK,
 			expectedError:  "",
@@ -1,15 +1,18 @@
 package controllers
 
 import (
+	"errors"
+	"library-management/audit"
+	"library-management/circulation"
 	"library-management/models"
+	"library-management/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-// AddBook adds a book or increments copies - Only Admin
-func AddBook(db *gorm.DB) gin.HandlerFunc {
+// AddBook adds a book or more copies of it - Only Admin
+func AddBook(books services.BookService, libraries services.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input models.Book
 
@@ -28,8 +31,7 @@ func AddBook(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		// Ensure user is an admin of the library
-		var admin models.UserLibrary
-		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&admin).Error; err != nil {
+		if member, err := libraries.IsMember(c.Request.Context(), userID.(uint), input.LibraryID); err != nil || !member {
 			c.JSON(http.StatusForbidden, gin.H{"error": "You can only add books to libraries you manage"})
 			return
 		}
@@ -40,35 +42,20 @@ func AddBook(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Check if book already exists in the library
-		var existingBook models.Book
-		if err := db.Where("isbn = ? AND library_id = ?", input.ISBN, input.LibraryID).First(&existingBook).Error; err == nil {
-			// Book already exists, update the total copies
-			existingBook.TotalCopies += input.TotalCopies
-			existingBook.AvailableCopies += input.TotalCopies
-
-			if err := db.Save(&existingBook).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update book copies"})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{"message": "Book copies updated successfully", "book": existingBook})
-			return
-		}
-
-		// New book → Insert into DB
-		input.AvailableCopies = input.TotalCopies
-		if err := db.Create(&input).Error; err != nil {
+		book, created, err := books.AddBook(audit.Context(c), input)
+		switch {
+		case err != nil:
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add book"})
-			return
+		case created:
+			c.JSON(http.StatusCreated, gin.H{"message": "Book added successfully", "book": book})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Book copies updated successfully", "book": book})
 		}
-
-		c.JSON(http.StatusCreated, gin.H{"message": "Book added successfully", "book": input})
 	}
 }
 
 // UpdateBook updates book details - Only Admin
-func UpdateBook(db *gorm.DB) gin.HandlerFunc {
+func UpdateBook(books services.BookService, libraries services.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		isbn := c.Param("isbn")
 		var input models.Book
@@ -90,32 +77,26 @@ func UpdateBook(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var admin models.UserLibrary
-		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&admin).Error; err != nil {
+		if member, err := libraries.IsMember(c.Request.Context(), userID.(uint), input.LibraryID); err != nil || !member {
 			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned as an admin for this library"})
 			return
 		}
 
-		var book models.Book
-		if err := db.Where("isbn = ? AND library_id = ?", isbn, input.LibraryID).First(&book).Error; err != nil {
+		book, err := books.UpdateBook(audit.Context(c), isbn, input.LibraryID, services.BookUpdate{
+			Title:       input.Title,
+			Authors:     input.Authors,
+			Publisher:   input.Publisher,
+			Version:     input.Version,
+			TotalCopies: input.TotalCopies,
+		})
+		switch {
+		case errors.Is(err, circulation.ErrBookNotFound):
 			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in the specified library"})
 			return
-		}
-
-		issuedCopies := book.TotalCopies - book.AvailableCopies
-		if input.TotalCopies < issuedCopies {
+		case errors.Is(err, services.ErrIssuedCopies):
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Total copies cannot be less than issued copies"})
 			return
-		}
-
-		book.Title = input.Title
-		book.Authors = input.Authors
-		book.Publisher = input.Publisher
-		book.Version = input.Version
-		book.TotalCopies = input.TotalCopies
-		book.AvailableCopies = input.TotalCopies - issuedCopies
-
-		if err := db.Save(&book).Error; err != nil {
+		case err != nil:
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update book"})
 			return
 		}
@@ -125,7 +106,7 @@ func UpdateBook(db *gorm.DB) gin.HandlerFunc {
 }
 
 // RemoveBook removes a book - Only Admin
-func RemoveBook(db *gorm.DB) gin.HandlerFunc {
+func RemoveBook(books services.BookService, libraries services.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		isbn := c.Param("isbn")
 		var input struct {
@@ -144,32 +125,25 @@ func RemoveBook(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var admin models.UserLibrary
-		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&admin).Error; err != nil {
+		if member, err := libraries.IsMember(c.Request.Context(), userID.(uint), input.LibraryID); err != nil || !member {
 			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned as an admin for this library"})
 			return
 		}
 
-		var book models.Book
-		if err := db.Where("isbn = ? AND library_id = ?", isbn, input.LibraryID).First(&book).Error; err != nil {
+		book, removed, err := books.RemoveBook(audit.Context(c), isbn, input.LibraryID)
+		switch {
+		case errors.Is(err, circulation.ErrBookNotFound):
 			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in the specified library"})
-			return
-		}
-
-		if book.TotalCopies > 1 {
-			book.TotalCopies--
-			book.AvailableCopies--
-			if err := db.Save(&book).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrement book copies"})
-				return
-			}
-			c.JSON(http.StatusOK, gin.H{"message": "Book copies decremented", "book": book})
-		} else {
-			if err := db.Delete(&book).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove book"})
-				return
-			}
+		case errors.Is(err, circulation.ErrNoCopiesAvailable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Every copy is on loan; nothing to remove"})
+		case errors.Is(err, circulation.ErrCopyUnavailable):
+			c.JSON(http.StatusConflict, gin.H{"error": "The last copy is on loan or set aside for a hold"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove book"})
+		case removed:
 			c.JSON(http.StatusOK, gin.H{"message": "Book removed from inventory"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Book copies decremented", "book": book})
 		}
 	}
 }
@@ -0,0 +1,95 @@
+package circulation
+
+import (
+	"fmt"
+	"library-management/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CopyInput describes a physical copy being added to the inventory
+type CopyInput struct {
+	Barcode       string `json:"barcode"`
+	Condition     string `json:"condition"`
+	ShelfLocation string `json:"shelf_location"`
+	AcquiredAt    int64  `json:"acquired_at"`
+}
+
+// CopyBarcode is the barcode generated for the n-th copy of a book
+func CopyBarcode(bookID uint, n int64) string {
+	return fmt.Sprintf("%06d-%03d", bookID, n)
+}
+
+// AddCopies adds copies to a locked book, generating barcodes for inputs without one,
+// sets them aside for waiting holds and refreshes the book's counters
+func (s *Service) AddCopies(tx *gorm.DB, book *models.Book, inputs ...CopyInput) ([]models.BookCopy, error) {
+	var existing int64
+	if err := tx.Unscoped().Model(&models.BookCopy{}).Where("book_id = ?", book.ID).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	copies := make([]models.BookCopy, len(inputs))
+	for i, in := range inputs {
+		copies[i] = models.BookCopy{
+			BookID:        book.ID,
+			Barcode:       in.Barcode,
+			Condition:     in.Condition,
+			ShelfLocation: in.ShelfLocation,
+			Status:        models.CopyAvailable,
+			AcquiredAt:    in.AcquiredAt,
+		}
+		if copies[i].Barcode == "" {
+			copies[i].Barcode = CopyBarcode(book.ID, existing+int64(i)+1)
+		}
+		if copies[i].Condition == "" {
+			copies[i].Condition = "good"
+		}
+		if copies[i].AcquiredAt == 0 {
+			copies[i].AcquiredAt = time.Now().Unix()
+		}
+	}
+
+	if len(copies) > 0 {
+		if err := tx.Create(&copies).Error; err != nil {
+			return nil, err
+		}
+		if err := s.fillHolds(tx, book, copies); err != nil {
+			return nil, err
+		}
+	}
+	return copies, SyncCopyCounts(tx, book)
+}
+
+// WithdrawCopies takes n available copies out of circulation, newest first
+func WithdrawCopies(tx *gorm.DB, book *models.Book, n int) error {
+	var ids []uint
+	if err := forUpdate(tx).Model(&models.BookCopy{}).
+		Where("book_id = ? AND status = ?", book.ID, models.CopyAvailable).
+		Order("id DESC").Limit(n).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) < n {
+		return ErrNoCopiesAvailable
+	}
+
+	if err := tx.Model(&models.BookCopy{}).Where("id IN ?", ids).Update("status", models.CopyWithdrawn).Error; err != nil {
+		return err
+	}
+	return SyncCopyCounts(tx, book)
+}
+
+// SyncCopyCounts derives the book's total and available counters from its copies;
+// lost and withdrawn copies no longer count towards the total
+func SyncCopyCounts(tx *gorm.DB, book *models.Book) error {
+	err := tx.Model(book).Updates(map[string]interface{}{
+		"total_copies": tx.Model(&models.BookCopy{}).Select("count(*)").
+			Where("book_id = ? AND status NOT IN ?", book.ID, []string{models.CopyLost, models.CopyWithdrawn}),
+		"available_copies": tx.Model(&models.BookCopy{}).Select("count(*)").
+			Where("book_id = ? AND status = ?", book.ID, models.CopyAvailable),
+	}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Select("total_copies", "available_copies").First(book, book.ID).Error
+}
@@ -0,0 +1,248 @@
+package circulation
+
+import (
+	"errors"
+	"library-management/audit"
+	"library-management/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrFineNotFound  = errors.New("fine not found")
+	ErrFineWaived    = errors.New("fine has already been waived")
+	ErrInvalidAmount = errors.New("amount must be positive")
+	ErrOverpayment   = errors.New("payment exceeds the outstanding balance")
+	ErrBalanceLimit  = errors.New("outstanding fines exceed the library's limit")
+)
+
+// OverdueFine works out the fine for a loan returned at returnedAt under policy.
+// Every started day past the due date counts; grace days are free and the total is capped.
+func OverdueFine(policy models.CirculationPolicy, dueDate, returnedAt int64) (days int, amountCents int64) {
+	if dueDate == 0 || returnedAt <= dueDate {
+		return 0, 0
+	}
+
+	const day = int64(24 * time.Hour / time.Second)
+	days = int((returnedAt-dueDate+day-1)/day) - policy.FineGraceDays
+	if days <= 0 {
+		return 0, 0
+	}
+
+	amountCents = int64(days) * policy.FinePerDayCents
+	if policy.MaxFineCents > 0 && amountCents > policy.MaxFineCents {
+		amountCents = policy.MaxFineCents
+	}
+	return days, amountCents
+}
+
+// Balance sums a reader's ledger with a library; positive means the reader owes money
+func Balance(db *gorm.DB, readerID, libraryID uint) (int64, error) {
+	var balance int64
+	err := db.Model(&models.LedgerEntry{}).
+		Where("reader_id = ? AND library_id = ?", readerID, libraryID).
+		Select("COALESCE(SUM(amount_cents), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// CheckBalance fails with ErrBalanceLimit when a reader owes the library more than its policy allows
+func (s *Service) CheckBalance(db *gorm.DB, readerID, libraryID uint) error {
+	policy, err := s.Policy(db, libraryID)
+	if err != nil {
+		return err
+	}
+	if policy.MaxBalanceCents == 0 {
+		return nil
+	}
+
+	balance, err := Balance(db, readerID, libraryID)
+	if err != nil {
+		return err
+	}
+	if balance > policy.MaxBalanceCents {
+		return ErrBalanceLimit
+	}
+	return nil
+}
+
+// RecordPayment credits a payment towards a reader's balance with a library
+func (s *Service) RecordPayment(readerID, libraryID uint, amountCents int64, note string, recordedByID uint) (*models.LedgerEntry, error) {
+	if amountCents <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	var entry models.LedgerEntry
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockAccount(tx, readerID); err != nil {
+			return err
+		}
+
+		balance, err := Balance(tx, readerID, libraryID)
+		if err != nil {
+			return err
+		}
+		if amountCents > balance {
+			return ErrOverpayment
+		}
+
+		entry = models.LedgerEntry{
+			ReaderID:     readerID,
+			LibraryID:    libraryID,
+			Kind:         models.LedgerPayment,
+			AmountCents:  -amountCents,
+			Note:         note,
+			RecordedByID: recordedByID,
+			RecordedAt:   time.Now().Unix(),
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, "payment.record", "ledger_entry", entry.ID, nil, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// WaiveFine cancels a fine, crediting whatever part of it is still owed
+func (s *Service) WaiveFine(fineID, waivedByID uint, reason string) (*models.Fine, error) {
+	var fine models.Fine
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&fine, fineID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrFineNotFound
+			}
+			return err
+		}
+		if err := lockAccount(tx, fine.ReaderID); err != nil {
+			return err
+		}
+		if err := forUpdate(tx).First(&fine, fineID).Error; err != nil {
+			return err
+		}
+		if fine.Status == models.FineWaived {
+			return ErrFineWaived
+		}
+
+		// Payments already made stay paid, so never credit more than the reader owes
+		balance, err := Balance(tx, fine.ReaderID, fine.LibraryID)
+		if err != nil {
+			return err
+		}
+		credit := fine.AmountCents
+		if balance < credit {
+			credit = balance
+		}
+		if credit > 0 {
+			entry := models.LedgerEntry{
+				ReaderID:     fine.ReaderID,
+				LibraryID:    fine.LibraryID,
+				FineID:       &fine.ID,
+				Kind:         models.LedgerWaiver,
+				AmountCents:  -credit,
+				Note:         reason,
+				RecordedByID: waivedByID,
+				RecordedAt:   time.Now().Unix(),
+			}
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+		}
+
+		before := fine
+		fine.Status = models.FineWaived
+		fine.WaivedByID = &waivedByID
+		fine.WaivedReason = reason
+		if err := tx.Save(&fine).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, "fine.waive", "fine", fine.ID, before, fine)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &fine, nil
+}
+
+// chargeFine brings a loan's fine up to date as of at: the first charge creates the
+// fine and later ones post only the increase. Waived fines stay waived.
+// It returns the fine, if any, and the amount charged now.
+func (s *Service) chargeFine(tx *gorm.DB, loan *models.IssueRegistry, at int64) (*models.Fine, int64, error) {
+	if loan.ExpectedReturnDate == 0 || at <= loan.ExpectedReturnDate {
+		return nil, 0, nil
+	}
+
+	policy, err := s.Policy(tx, loan.LibraryID)
+	if err != nil {
+		return nil, 0, err
+	}
+	days, amount := OverdueFine(policy, loan.ExpectedReturnDate, at)
+	if amount == 0 {
+		return nil, 0, nil
+	}
+
+	var fine models.Fine
+	err = forUpdate(tx).Where("issue_id = ?", loan.ID).First(&fine).Error
+	var increase int64
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		fine = models.Fine{
+			IssueID:     loan.ID,
+			ReaderID:    loan.ReaderID,
+			LibraryID:   loan.LibraryID,
+			DaysOverdue: days,
+			AmountCents: amount,
+			Status:      models.FineCharged,
+			AssessedAt:  at,
+		}
+		if err := tx.Create(&fine).Error; err != nil {
+			return nil, 0, err
+		}
+		if err := audit.Record(tx, "fine.charge", "fine", fine.ID, nil, fine); err != nil {
+			return nil, 0, err
+		}
+		increase = amount
+	case err != nil:
+		return nil, 0, err
+	case fine.Status == models.FineWaived || amount <= fine.AmountCents:
+		return &fine, 0, nil
+	default:
+		before := fine
+		increase = amount - fine.AmountCents
+		fine.DaysOverdue = days
+		fine.AmountCents = amount
+		fine.AssessedAt = at
+		if err := tx.Model(&fine).Updates(map[string]interface{}{
+			"days_overdue": days,
+			"amount_cents": amount,
+			"assessed_at":  at,
+		}).Error; err != nil {
+			return nil, 0, err
+		}
+		if err := audit.Record(tx, "fine.accrue", "fine", fine.ID, before, fine); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	entry := models.LedgerEntry{
+		ReaderID:     loan.ReaderID,
+		LibraryID:    loan.LibraryID,
+		FineID:       &fine.ID,
+		Kind:         models.LedgerFine,
+		AmountCents:  increase,
+		RecordedByID: loan.ReturnApproverID, // Zero while the loan is open and the scheduler accrues
+		RecordedAt:   at,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, 0, err
+	}
+	return &fine, increase, nil
+}
+
+// lockAccount serialises balance changes for a reader by locking their user row
+func lockAccount(tx *gorm.DB, readerID uint) error {
+	return forUpdate(tx).Select("id").First(&models.User{}, readerID).Error
+}
@@ -0,0 +1,127 @@
+package circulation
+
+import (
+	"library-management/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestOverdueFine(t *testing.T) {
+	policy := models.CirculationPolicy{FinePerDayCents: 25, MaxFineCents: 1000, FineGraceDays: 1}
+	day := int64(24 * time.Hour / time.Second)
+	due := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC).Unix()
+
+	tests := []struct {
+		name       string
+		returnedAt int64
+		days       int
+		amount     int64
+	}{
+		{"On time", due, 0, 0},
+		{"Within the grace day", due + day, 0, 0},
+		{"A started day counts", due + day + 1, 1, 25},
+		{"Ten days late", due + 10*day, 9, 225},
+		{"Capped", due + 100*day, 99, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			days, amount := OverdueFine(policy, due, tt.returnedAt)
+			assert.Equal(t, tt.days, days)
+			assert.Equal(t, tt.amount, amount)
+		})
+	}
+}
+
+func TestReturnChargesFine(t *testing.T) {
+	svc, mock := newTestService(t)
+	due := time.Now().AddDate(0, 0, -5).Add(time.Hour).Unix()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_copies" WHERE barcode = $1`)).
+		WithArgs("000003-001", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "barcode", "status"}).AddRow(11, 3, "000003-001", "issued"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE (copy_id = $1 AND issue_status = $2)`)).
+		WithArgs(11, "issued", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "copy_id", "reader_id", "issue_status", "expected_return_date"}).
+			AddRow(7, "123456789", 1, 11, 2, "issued", due))
+	mock.ExpectQuery(bookQuery).
+		WithArgs("123456789", 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "issue_registries" SET`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, "loan.return")
+	expectFulfilRequest(mock)
+	expectDefaultPolicy(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "fines" WHERE issue_id = $1 AND "fines"."deleted_at" IS NULL ORDER BY "fines"."id" LIMIT $2 FOR UPDATE`)).
+		WithArgs(7, 1).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "fines"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 7, 2, 1, 4, 100, "charged", sqlmock.AnyArg(), nil, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	expectAudit(mock, "fine.charge")
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "ledger_entries"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, 1, 3, "fine", 100, "", 9, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "holds"`)).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_copies" SET "status"=$1`)).
+		WithArgs("available", sqlmock.AnyArg(), 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSyncCounts(mock, 1)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	_, fine, err := svc.ReturnCopy("000003-001", 9, "")
+	assert.NoError(t, err)
+	if assert.NotNil(t, fine) {
+		assert.Equal(t, 4, fine.DaysOverdue)
+		assert.Equal(t, int64(100), fine.AmountCents)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordPayment(t *testing.T) {
+	expectBalance := func(mock sqlmock.Sqlmock, balance int64) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id" FROM "users" WHERE "users"."id" = $1`)).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(amount_cents), 0) FROM "ledger_entries" WHERE (reader_id = $1 AND library_id = $2)`)).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(balance))
+	}
+
+	t.Run("Payment is credited", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		expectBalance(mock, 300)
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "ledger_entries"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, 1, nil, "payment", -200, "cash", 9, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		expectAudit(mock, "payment.record")
+		mock.ExpectCommit()
+
+		entry, err := svc.RecordPayment(2, 1, 200, "cash", 9)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(-200), entry.AmountCents)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Overpayment is refused", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		expectBalance(mock, 100)
+		mock.ExpectRollback()
+
+		_, err := svc.RecordPayment(2, 1, 200, "", 9)
+		assert.ErrorIs(t, err, ErrOverpayment)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,279 @@
+package circulation
+
+import (
+	"errors"
+	"library-management/audit"
+	"library-management/models"
+	"library-management/notify"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCopiesAvailable = errors.New("copies are available, request an issue instead")
+	ErrHoldExists      = errors.New("reader already has an active hold on this title")
+	ErrHoldNotFound    = errors.New("hold not found")
+	ErrHoldClosed      = errors.New("hold is no longer active")
+)
+
+// PlaceHold queues a reader for a title that has no copy on the shelf
+func (s *Service) PlaceHold(isbn string, libraryID, readerID uint) (*models.Hold, error) {
+	var hold models.Hold
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var book models.Book
+		err := forUpdate(tx).Where("isbn = ? AND library_id = ?", isbn, libraryID).First(&book).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrBookNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if book.AvailableCopies > 0 {
+			return ErrCopiesAvailable
+		}
+
+		var active int64
+		if err := tx.Model(&models.Hold{}).
+			Where("isbn = ? AND library_id = ? AND reader_id = ? AND status IN ?", isbn, libraryID, readerID, []string{models.HoldWaiting, models.HoldReady}).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrHoldExists
+		}
+
+		hold = models.Hold{
+			ISBN:      isbn,
+			LibraryID: libraryID,
+			ReaderID:  readerID,
+			Status:    models.HoldWaiting,
+			PlacedAt:  time.Now().Unix(),
+		}
+		if err := tx.Create(&hold).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, "hold.place", "hold", hold.ID, nil, hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// CancelHold withdraws a reader's active hold; a copy already set aside passes to the next reader
+func (s *Service) CancelHold(holdID, readerID uint) (*models.Hold, error) {
+	var hold models.Hold
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND reader_id = ?", holdID, readerID).First(&hold).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrHoldNotFound
+			}
+			return err
+		}
+		before := hold
+		if err := s.releaseHold(tx, &hold, models.HoldCancelled, nil); err != nil {
+			return err
+		}
+		return audit.Record(tx, "hold.cancel", "hold", hold.ID, before, hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// ExpireHolds closes ready holds whose pickup window ended before now and
+// passes their copies on; it returns how many holds expired
+func (s *Service) ExpireHolds(now time.Time) (int, error) {
+	var overdue []models.Hold
+	if err := s.db.Where("status = ? AND pickup_by < ?", models.HoldReady, now.Unix()).Find(&overdue).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range overdue {
+		hold := &overdue[i]
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			before := *hold
+			if err := s.releaseHold(tx, hold, models.HoldExpired, func(h *models.Hold) bool {
+				return h.Status == models.HoldReady && h.PickupBy != nil && *h.PickupBy < now.Unix()
+			}); err != nil {
+				return err
+			}
+			return audit.Record(tx, "hold.expire", "hold", hold.ID, before, *hold)
+		})
+		if errors.Is(err, ErrHoldClosed) {
+			continue // Collected or cancelled since the scan
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// FillHolds sets aside available copies of a book for waiting readers, oldest hold first
+func (s *Service) FillHolds(bookID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var book models.Book
+		if err := forUpdate(tx).First(&book, bookID).Error; err != nil {
+			return err
+		}
+
+		var available []models.BookCopy
+		if err := forUpdate(tx).Where("book_id = ? AND status = ?", book.ID, models.CopyAvailable).Order("id").Find(&available).Error; err != nil {
+			return err
+		}
+		if err := s.fillHolds(tx, &book, available); err != nil {
+			return err
+		}
+		return SyncCopyCounts(tx, &book)
+	})
+}
+
+// ShelveCopy returns a copy of a locked book to circulation, setting it aside for
+// the next waiting hold if there is one; the caller syncs the counters afterwards
+func (s *Service) ShelveCopy(tx *gorm.DB, book *models.Book, copyID uint) error {
+	return s.shelveCopy(tx, book, copyID)
+}
+
+// QueuePosition reports a waiting hold's 1-based place in its title's queue
+func QueuePosition(db *gorm.DB, hold *models.Hold) (int64, error) {
+	var ahead int64
+	err := db.Model(&models.Hold{}).
+		Where("isbn = ? AND library_id = ? AND status = ?", hold.ISBN, hold.LibraryID, models.HoldWaiting).
+		Where("placed_at < ? OR (placed_at = ? AND id < ?)", hold.PlacedAt, hold.PlacedAt, hold.ID).
+		Count(&ahead).Error
+	return ahead + 1, err
+}
+
+// QueuePositions reports the 1-based queue places of the waiting holds among
+// holds, keyed by hold ID, reading every title's queue in one query
+func QueuePositions(db *gorm.DB, holds []models.Hold) (map[uint]int64, error) {
+	var ids, libraryIDs []uint
+	var isbns []string
+	for _, hold := range holds {
+		if hold.Status == models.HoldWaiting {
+			ids = append(ids, hold.ID)
+			isbns = append(isbns, hold.ISBN)
+			libraryIDs = append(libraryIDs, hold.LibraryID)
+		}
+	}
+	positions := make(map[uint]int64, len(ids))
+	if len(ids) == 0 {
+		return positions, nil
+	}
+
+	queues := db.Model(&models.Hold{}).
+		Select("id, ROW_NUMBER() OVER (PARTITION BY isbn, library_id ORDER BY placed_at, id) AS position").
+		Where("status = ? AND isbn IN (?) AND library_id IN (?)", models.HoldWaiting, isbns, libraryIDs)
+	var rows []struct {
+		ID       uint
+		Position int64
+	}
+	if err := db.Table("(?) AS queues", queues).Where("id IN (?)", ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		positions[row.ID] = row.Position
+	}
+	return positions, nil
+}
+
+// releaseHold closes an active hold with the given status, re-checking it under
+// lock with stillDue, and passes any copy set aside for it to the next reader
+func (s *Service) releaseHold(tx *gorm.DB, hold *models.Hold, status string, stillDue func(*models.Hold) bool) error {
+	// Lock order matches issue and return: book first, then the hold
+	var book models.Book
+	if err := forUpdate(tx).Where("isbn = ? AND library_id = ?", hold.ISBN, hold.LibraryID).First(&book).Error; err != nil {
+		return err
+	}
+	if err := forUpdate(tx).First(hold, hold.ID).Error; err != nil {
+		return err
+	}
+	if hold.Status != models.HoldWaiting && hold.Status != models.HoldReady {
+		return ErrHoldClosed
+	}
+	if stillDue != nil && !stillDue(hold) {
+		return ErrHoldClosed
+	}
+
+	wasReady := hold.Status == models.HoldReady && hold.CopyID != nil
+	if err := closeHold(tx, hold, status); err != nil {
+		return err
+	}
+	if !wasReady {
+		return nil
+	}
+
+	if err := s.shelveCopy(tx, &book, *hold.CopyID); err != nil {
+		return err
+	}
+	return SyncCopyCounts(tx, &book)
+}
+
+// shelveCopy puts a copy back into circulation: it is set aside for the oldest
+// waiting hold on the title, or made available when nobody is waiting.
+// The caller holds the book lock and syncs the counters afterwards.
+func (s *Service) shelveCopy(tx *gorm.DB, book *models.Book, copyID uint) error {
+	var next models.Hold
+	err := forUpdate(tx).Where("isbn = ? AND library_id = ? AND status = ?", book.ISBN, book.LibraryID, models.HoldWaiting).
+		Order("placed_at, id").First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Model(&models.BookCopy{}).Where("id = ?", copyID).Update("status", models.CopyAvailable).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.BookCopy{}).Where("id = ?", copyID).Update("status", models.CopyOnHold).Error; err != nil {
+		return err
+	}
+	policy, err := s.Policy(tx, book.LibraryID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	readyAt := now.Unix()
+	pickupBy := now.AddDate(0, 0, policy.HoldPickupDays).Unix()
+	next.Status = models.HoldReady
+	next.CopyID = &copyID
+	next.ReadyAt = &readyAt
+	next.PickupBy = &pickupBy
+	if err := tx.Save(&next).Error; err != nil {
+		return err
+	}
+	return s.notify(tx, next.ReaderID, notify.EventHoldReady, map[string]interface{}{
+		"Title":    book.Title,
+		"PickupBy": formatDate(pickupBy),
+	})
+}
+
+// fillHolds hands the given available copies to waiting holds until either runs out
+func (s *Service) fillHolds(tx *gorm.DB, book *models.Book, copies []models.BookCopy) error {
+	var waiting int64
+	if err := tx.Model(&models.Hold{}).
+		Where("isbn = ? AND library_id = ? AND status = ?", book.ISBN, book.LibraryID, models.HoldWaiting).
+		Count(&waiting).Error; err != nil {
+		return err
+	}
+
+	for i := 0; i < len(copies) && int64(i) < waiting; i++ {
+		if err := s.shelveCopy(tx, book, copies[i].ID); err != nil {
+			return err
+		}
+		copies[i].Status = models.CopyOnHold // Every hold counted is waiting, and the book lock keeps it so
+	}
+	return nil
+}
+
+// closeHold moves a hold to a final status
+func closeHold(tx *gorm.DB, hold *models.Hold, status string) error {
+	closedAt := time.Now().Unix()
+	hold.Status = status
+	hold.ClosedAt = &closedAt
+	return tx.Save(hold).Error
+}
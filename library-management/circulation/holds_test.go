@@ -0,0 +1,142 @@
+package circulation
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var holdColumns = []string{"id", "isbn", "library_id", "reader_id", "status", "placed_at", "copy_id", "pickup_by"}
+
+func TestPlaceHold(t *testing.T) {
+	t.Run("Queues the reader", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "available_copies"}).AddRow(3, "123456789", 1, 0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "holds" WHERE (isbn = $1 AND library_id = $2 AND reader_id = $3 AND status IN ($4,$5))`)).
+			WithArgs("123456789", 1, 2, "waiting", "ready").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "holds"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		expectAudit(mock, "hold.place")
+		mock.ExpectCommit()
+
+		hold, err := svc.PlaceHold("123456789", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "waiting", hold.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Copies on the shelf", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "available_copies"}).AddRow(3, "123456789", 1, 2))
+		mock.ExpectRollback()
+
+		_, err := svc.PlaceHold("123456789", 1, 2)
+		assert.ErrorIs(t, err, ErrCopiesAvailable)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestReturnFillsNextHold(t *testing.T) {
+	svc, mock := newTestService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_copies" WHERE barcode = $1`)).
+		WithArgs("000003-001", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "barcode", "status"}).AddRow(11, 3, "000003-001", "issued"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE (copy_id = $1 AND issue_status = $2)`)).
+		WithArgs(11, "issued", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "copy_id", "reader_id", "issue_status"}).AddRow(7, "123456789", 1, 11, 2, "issued"))
+	mock.ExpectQuery(bookQuery).
+		WithArgs("123456789", 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "issue_registries" SET`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, "loan.return")
+	expectFulfilRequest(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "holds" WHERE (isbn = $1 AND library_id = $2 AND status = $3) AND "holds"."deleted_at" IS NULL ORDER BY placed_at, id,"holds"."id" LIMIT $4 FOR UPDATE`)).
+		WithArgs("123456789", 1, "waiting", 1).
+		WillReturnRows(sqlmock.NewRows(holdColumns).AddRow(4, "123456789", 1, 5, "waiting", time.Now().Unix(), nil, nil))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_copies" SET "status"=$1,"updated_at"=$2 WHERE id = $3`)).
+		WithArgs("on_hold", sqlmock.AnyArg(), 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectDefaultPolicy(mock)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "holds" SET`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSyncCounts(mock, 0)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	_, _, err := svc.ReturnCopy("000003-001", 9, "")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExpireHolds(t *testing.T) {
+	t.Run("Collected since the scan", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		past := time.Now().Add(-time.Hour).Unix()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "holds" WHERE (status = $1 AND pickup_by < $2)`)).
+			WithArgs("ready", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows(holdColumns).AddRow(4, "123456789", 1, 5, "ready", past, 11, past))
+		mock.ExpectBegin()
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "holds" WHERE "holds"."id" = $1`)).
+			WithArgs(4, 4, 1).
+			WillReturnRows(sqlmock.NewRows(holdColumns).AddRow(4, "123456789", 1, 5, "fulfilled", past, 11, past))
+		mock.ExpectRollback()
+
+		expired, err := svc.ExpireHolds(time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, expired)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Uncollected copy passes on", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		past := time.Now().Add(-time.Hour).Unix()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "holds" WHERE (status = $1 AND pickup_by < $2)`)).
+			WithArgs("ready", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows(holdColumns).AddRow(4, "123456789", 1, 5, "ready", past, 11, past))
+		mock.ExpectBegin()
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "holds" WHERE "holds"."id" = $1`)).
+			WithArgs(4, 4, 1).
+			WillReturnRows(sqlmock.NewRows(holdColumns).AddRow(4, "123456789", 1, 5, "ready", past, 11, past))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "holds" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "holds" WHERE (isbn = $1 AND library_id = $2 AND status = $3)`)).
+			WithArgs("123456789", 1, "waiting", 1).
+			WillReturnError(gorm.ErrRecordNotFound)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_copies" SET "status"=$1,"updated_at"=$2 WHERE id = $3`)).
+			WithArgs("available", sqlmock.AnyArg(), 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectSyncCounts(mock, 1)
+		expectAudit(mock, "hold.expire")
+		mock.ExpectCommit()
+
+		expired, err := svc.ExpireHolds(time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,82 @@
+package circulation
+
+import (
+	"library-management/models"
+	"library-management/notify"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RemindDue tells readers about loans falling due within the reminder window,
+// once per due date; it returns how many reminders were queued
+func (s *Service) RemindDue(now time.Time) (int, error) {
+	if s.notifier == nil || s.dueReminderDays == 0 {
+		return 0, nil
+	}
+
+	var loans []models.IssueRegistry
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Stamping and reading the loans in one statement keeps concurrent runs from reminding twice
+		if err := tx.Model(&loans).Clauses(clause.Returning{}).
+			Where("issue_status = ? AND due_reminder_at IS NULL AND expected_return_date >= ? AND expected_return_date < ?",
+				"issued", now.Unix(), now.AddDate(0, 0, s.dueReminderDays).Unix()).
+			Update("due_reminder_at", now.Unix()).Error; err != nil {
+			return err
+		}
+		for i := range loans {
+			if err := s.notifyLoan(tx, &loans[i], notify.EventLoanDueSoon); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(loans), nil
+}
+
+// notify queues a message for a reader when the service has a notifier
+func (s *Service) notify(tx *gorm.DB, readerID uint, event string, data map[string]interface{}) error {
+	if s.notifier == nil {
+		return nil
+	}
+	return s.notifier.Notify(tx, readerID, event, data)
+}
+
+// notifyLoan queues a message about a loan, naming its book and due date
+func (s *Service) notifyLoan(tx *gorm.DB, loan *models.IssueRegistry, event string) error {
+	if s.notifier == nil {
+		return nil
+	}
+
+	var book models.Book
+	if err := tx.Select("title").Where("isbn = ? AND library_id = ?", loan.ISBN, loan.LibraryID).First(&book).Error; err != nil {
+		return err
+	}
+	return s.notifier.Notify(tx, loan.ReaderID, event, map[string]interface{}{
+		"Title":   book.Title,
+		"DueDate": formatDate(loan.ExpectedReturnDate),
+	})
+}
+
+// notifyRequest queues a message about a request, naming its book
+func (s *Service) notifyRequest(tx *gorm.DB, request *models.RequestEvent, event string, data map[string]interface{}) error {
+	if s.notifier == nil {
+		return nil
+	}
+
+	var book models.Book
+	if err := tx.Select("title").Where("isbn = ? AND library_id = ?", request.BookID, request.LibraryID).First(&book).Error; err != nil {
+		return err
+	}
+	data["Title"] = book.Title
+	return s.notifier.Notify(tx, request.ReaderID, event, data)
+}
+
+// formatDate renders a Unix timestamp as a calendar date for messages
+func formatDate(ts int64) string {
+	return time.Unix(ts, 0).Format("Mon 2 Jan 2006")
+}
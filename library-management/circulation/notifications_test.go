@@ -0,0 +1,53 @@
+package circulation
+
+import (
+	"library-management/notify"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemindDue(t *testing.T) {
+	t.Run("Without a notifier", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		reminded, err := svc.RemindDue(time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, reminded)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Queues one reminder per loan", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		svc.WithNotifier(notify.New(svc.db, 5, notify.NewInboxChannel(svc.db)))
+		now := time.Now()
+		due := now.Add(36 * time.Hour)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`UPDATE "issue_registries" SET "due_reminder_at"=$1,"updated_at"=$2 WHERE (issue_status = $3 AND due_reminder_at IS NULL AND expected_return_date >= $4 AND expected_return_date < $5) AND "issue_registries"."deleted_at" IS NULL RETURNING *`)).
+			WithArgs(now.Unix(), sqlmock.AnyArg(), "issued", now.Unix(), now.AddDate(0, 0, 2).Unix()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id", "issue_status", "expected_return_date"}).
+				AddRow(7, "123456789", 1, 2, "issued", due.Unix()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "title" FROM "books" WHERE (isbn = $1 AND library_id = $2)`)).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"title"}).AddRow("Dune"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","name","email","contact" FROM "users"`)).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "contact"}).AddRow(2, "Ada", "ada@example.com", ""))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "notification_preferences" WHERE user_id = $1`)).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "channel", "enabled"}))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "notifications"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, notify.EventLoanDueSoon, "Dune is due on "+formatDate(due.Unix()), sqlmock.AnyArg(), nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		reminded, err := svc.RemindDue(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, reminded)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
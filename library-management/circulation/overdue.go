@@ -0,0 +1,108 @@
+package circulation
+
+import (
+	"library-management/audit"
+	"library-management/models"
+	"library-management/notify"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MarkOverdue flags open loans whose due date passed before now and tells their
+// readers; it returns how many were flagged
+func (s *Service) MarkOverdue(now time.Time) (int, error) {
+	var loans []models.IssueRegistry
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&loans).Clauses(clause.Returning{}).
+			Where("issue_status = ? AND overdue_at IS NULL AND expected_return_date > 0 AND expected_return_date < ?", "issued", now.Unix()).
+			Update("overdue_at", now.Unix()).Error; err != nil {
+			return err
+		}
+		for i := range loans {
+			before := loans[i]
+			before.OverdueAt = nil
+			if err := audit.Record(tx, "loan.overdue", "loan", loans[i].ID, before, loans[i]); err != nil {
+				return err
+			}
+			if err := s.notifyLoan(tx, &loans[i], notify.EventLoanOverdue); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(loans), nil
+}
+
+// AccrueFines brings the fines on open overdue loans up to date as of now, so
+// balances grow day by day rather than only at return; it returns how many fines changed
+func (s *Service) AccrueFines(now time.Time) (int, error) {
+	var ids []uint
+	if err := s.db.Model(&models.IssueRegistry{}).
+		Where("issue_status = ? AND expected_return_date > 0 AND expected_return_date < ?", "issued", now.Unix()).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	accrued := 0
+	for _, id := range ids {
+		var charged int64
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			// The loan lock orders this against a return closing the same loan
+			var loan models.IssueRegistry
+			if err := forUpdate(tx).First(&loan, id).Error; err != nil {
+				return err
+			}
+			if loan.IssueStatus != "issued" {
+				return nil // Returned since the scan; the return charged the final fine
+			}
+
+			var err error
+			_, charged, err = s.chargeFine(tx, &loan, now.Unix())
+			return err
+		})
+		if err != nil {
+			return accrued, err
+		}
+		if charged > 0 {
+			accrued++
+		}
+	}
+	return accrued, nil
+}
+
+// ExpireRequests closes issue requests left unanswered for longer than the
+// configured expiry; it returns how many expired
+func (s *Service) ExpireRequests(now time.Time) (int, error) {
+	if s.requestExpiryDays == 0 {
+		return 0, nil
+	}
+
+	cutoff := now.AddDate(0, 0, -s.requestExpiryDays).Unix()
+	var requests []models.RequestEvent
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&requests).Clauses(clause.Returning{}).
+			Where("request_type = ? AND status IN ? AND request_date < ?", "issue", transitionFrom(models.RequestExpired), cutoff).
+			Updates(map[string]interface{}{"status": models.RequestExpired, "closed_at": now.Unix()}).Error; err != nil {
+			return err
+		}
+		for i := range requests {
+			// Only pending requests can expire
+			before := requests[i]
+			before.Status = models.RequestPending
+			before.ClosedAt = nil
+			if err := audit.Record(tx, "request.expire", "request", requests[i].ID, before, requests[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(requests), nil
+}
@@ -0,0 +1,81 @@
+package circulation
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkOverdue(t *testing.T) {
+	svc, mock := newTestService(t)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE "issue_registries" SET "overdue_at"=$1,"updated_at"=$2 WHERE (issue_status = $3 AND overdue_at IS NULL AND expected_return_date > 0 AND expected_return_date < $4) AND "issue_registries"."deleted_at" IS NULL RETURNING *`)).
+		WithArgs(now.Unix(), sqlmock.AnyArg(), "issued", now.Unix()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id", "issue_status"}).
+			AddRow(7, "123456789", 1, 2, "issued").
+			AddRow(8, "987654321", 1, 3, "issued"))
+	expectAudit(mock, "loan.overdue")
+	expectAudit(mock, "loan.overdue")
+	mock.ExpectCommit()
+
+	marked, err := svc.MarkOverdue(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, marked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAccrueFines(t *testing.T) {
+	svc, mock := newTestService(t)
+	now := time.Now()
+	due := now.AddDate(0, 0, -4).Add(time.Hour).Unix() // Four started days, one of them grace
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id" FROM "issue_registries" WHERE (issue_status = $1 AND expected_return_date > 0 AND expected_return_date < $2)`)).
+		WithArgs("issued", now.Unix()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE "issue_registries"."id" = $1 AND "issue_registries"."deleted_at" IS NULL ORDER BY "issue_registries"."id" LIMIT $2 FOR UPDATE`)).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id", "issue_status", "expected_return_date"}).
+			AddRow(7, "123456789", 1, 2, "issued", due))
+	expectDefaultPolicy(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "fines" WHERE issue_id = $1`)).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "issue_id", "reader_id", "library_id", "days_overdue", "amount_cents", "status"}).
+			AddRow(3, 7, 2, 1, 2, 50, "charged"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "fines" SET "amount_cents"=$1,"assessed_at"=$2,"days_overdue"=$3,"updated_at"=$4 WHERE`)).
+		WithArgs(75, now.Unix(), 3, sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, "fine.accrue")
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "ledger_entries"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, 1, 3, "fine", 25, "", 0, now.Unix()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectCommit()
+
+	accrued, err := svc.AccrueFines(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, accrued)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExpireRequests(t *testing.T) {
+	svc, mock := newTestService(t)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE "request_events" SET "closed_at"=$1,"status"=$2,"updated_at"=$3 WHERE (request_type = $4 AND status IN ($5) AND request_date < $6) AND "request_events"."deleted_at" IS NULL RETURNING *`)).
+		WithArgs(now.Unix(), "expired", sqlmock.AnyArg(), "issue", "pending", now.AddDate(0, 0, -7).Unix()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id", "request_type", "status", "closed_at"}).
+			AddRow(5, "123456789", 1, 2, "issue", "expired", now.Unix()))
+	expectAudit(mock, "request.expire")
+	mock.ExpectCommit()
+
+	expired, err := svc.ExpireRequests(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
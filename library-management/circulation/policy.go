@@ -0,0 +1,57 @@
+package circulation
+
+import (
+	"errors"
+	"library-management/config"
+	"library-management/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrLoanLimit is returned when a reader already has as many loans as the library allows
+var ErrLoanLimit = errors.New("reader has reached the loan limit for this library")
+
+// DefaultPolicy turns the configured defaults into a policy for libraries without their own
+func DefaultPolicy(cfg config.CirculationConfig) models.CirculationPolicy {
+	return models.CirculationPolicy{
+		LoanPeriodDays:  cfg.LoanPeriodDays,
+		MaxLoans:        cfg.MaxLoans,
+		MaxRenewals:     cfg.MaxRenewals,
+		HoldPickupDays:  cfg.HoldPickupDays,
+		FinePerDayCents: int64(cfg.FinePerDayCents),
+		MaxFineCents:    int64(cfg.MaxFineCents),
+		FineGraceDays:   cfg.FineGraceDays,
+		MaxBalanceCents: int64(cfg.MaxBalanceCents),
+	}
+}
+
+// Policy returns the rules in force for a library. The result has no ID when the
+// library has no policy of its own and the defaults apply.
+func (s *Service) Policy(db *gorm.DB, libraryID uint) (models.CirculationPolicy, error) {
+	var policy models.CirculationPolicy
+	err := db.Where("library_id = ?", libraryID).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		policy = s.defaults
+		policy.LibraryID = libraryID
+		return policy, nil
+	}
+	return policy, err
+}
+
+// checkLoanLimit fails with ErrLoanLimit when the reader cannot borrow another book from the library
+func checkLoanLimit(tx *gorm.DB, policy models.CirculationPolicy, readerID uint) error {
+	if policy.MaxLoans == 0 {
+		return nil
+	}
+
+	var open int64
+	if err := tx.Model(&models.IssueRegistry{}).
+		Where("reader_id = ? AND library_id = ? AND issue_status = ?", readerID, policy.LibraryID, "issued").
+		Count(&open).Error; err != nil {
+		return err
+	}
+	if open >= int64(policy.MaxLoans) {
+		return ErrLoanLimit
+	}
+	return nil
+}
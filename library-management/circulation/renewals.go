@@ -0,0 +1,103 @@
+package circulation
+
+import (
+	"errors"
+	"library-management/audit"
+	"library-management/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLoanNotFound = errors.New("loan not found")
+	ErrLoanClosed   = errors.New("loan has already been returned")
+	ErrRenewalLimit = errors.New("maximum number of renewals reached")
+	ErrHoldsWaiting = errors.New("other readers are waiting for this title")
+	ErrLoanOverdue  = errors.New("overdue loans cannot be renewed")
+)
+
+// Renew pushes an open loan's due date forward by one loan period and records the change
+func (s *Service) Renew(issueID, renewedByID uint) (*models.IssueRegistry, error) {
+	var loan models.IssueRegistry
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&loan, issueID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrLoanNotFound
+			}
+			return err
+		}
+
+		// Lock the book before the loan, like issue and return, so a hold placed
+		// concurrently is either seen here or placed after the renewal
+		var book models.Book
+		if err := forUpdate(tx).Where("isbn = ? AND library_id = ?", loan.ISBN, loan.LibraryID).First(&book).Error; err != nil {
+			return err
+		}
+		if err := forUpdate(tx).First(&loan, issueID).Error; err != nil {
+			return err
+		}
+
+		if loan.IssueStatus != "issued" {
+			return ErrLoanClosed
+		}
+		// Fines accrue against the due date, so it may only move while the loan is current
+		if loan.ExpectedReturnDate < time.Now().Unix() {
+			return ErrLoanOverdue
+		}
+		policy, err := s.Policy(tx, loan.LibraryID)
+		if err != nil {
+			return err
+		}
+		if loan.RenewalCount >= policy.MaxRenewals {
+			return ErrRenewalLimit
+		}
+
+		var holds int64
+		if err := tx.Model(&models.Hold{}).
+			Where("isbn = ? AND library_id = ? AND status IN ?", loan.ISBN, loan.LibraryID, []string{models.HoldWaiting, models.HoldReady}).
+			Count(&holds).Error; err != nil {
+			return err
+		}
+		if holds > 0 {
+			return ErrHoldsWaiting
+		}
+
+		now := time.Now()
+		renewal := models.LoanRenewal{
+			IssueID:         loan.ID,
+			RenewedByID:     renewedByID,
+			PreviousDueDate: loan.ExpectedReturnDate,
+			NewDueDate:      time.Unix(loan.ExpectedReturnDate, 0).AddDate(0, 0, policy.LoanPeriodDays).Unix(),
+			RenewedAt:       now.Unix(),
+		}
+		if err := tx.Create(&renewal).Error; err != nil {
+			return err
+		}
+
+		before := loan
+		loan.ExpectedReturnDate = renewal.NewDueDate
+		loan.RenewalCount++
+		loan.DueReminderAt = nil // Remind again before the new due date
+		if err := tx.Model(&loan).Updates(map[string]interface{}{
+			"expected_return_date": loan.ExpectedReturnDate,
+			"renewal_count":        loan.RenewalCount,
+			"due_reminder_at":      nil,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, "loan.renew", "loan", loan.ID, before, loan)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// RenewalsLeft reports how many more times a loan may be renewed under policy
+func RenewalsLeft(policy models.CirculationPolicy, loan *models.IssueRegistry) int {
+	if left := policy.MaxRenewals - loan.RenewalCount; left > 0 {
+		return left
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package circulation
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenew(t *testing.T) {
+	loanColumns := []string{"id", "isbn", "library_id", "reader_id", "issue_status", "expected_return_date", "renewal_count"}
+	due := time.Now().AddDate(0, 0, 3).Unix()
+
+	expectLoanDue := func(mock sqlmock.Sqlmock, status string, renewals int, due int64) {
+		rows := func() *sqlmock.Rows {
+			return sqlmock.NewRows(loanColumns).AddRow(7, "123456789", 1, 2, status, due, renewals)
+		}
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE "issue_registries"."id" = $1 AND "issue_registries"."deleted_at" IS NULL ORDER BY "issue_registries"."id" LIMIT $2`)).
+			WithArgs(7, 1).
+			WillReturnRows(rows())
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE "issue_registries"."id" = $1 AND "issue_registries"."deleted_at" IS NULL AND "issue_registries"."id" = $2 ORDER BY "issue_registries"."id" LIMIT $3 FOR UPDATE`)).
+			WithArgs(7, 7, 1).
+			WillReturnRows(rows())
+		if status == "issued" && due > time.Now().Unix() {
+			expectDefaultPolicy(mock)
+		}
+	}
+	expectLoan := func(mock sqlmock.Sqlmock, status string, renewals int) {
+		expectLoanDue(mock, status, renewals, due)
+	}
+	expectHolds := func(mock sqlmock.Sqlmock, n int) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "holds" WHERE (isbn = $1 AND library_id = $2 AND status IN ($3,$4))`)).
+			WithArgs("123456789", 1, "waiting", "ready").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(n))
+	}
+
+	t.Run("Extends the due date by one loan period", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		expectLoan(mock, "issued", 1)
+		expectHolds(mock, 0)
+		newDue := time.Unix(due, 0).AddDate(0, 0, 14).Unix()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "loan_renewals"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 7, 9, due, newDue, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "issue_registries" SET "due_reminder_at"=$1,"expected_return_date"=$2,"renewal_count"=$3,"updated_at"=$4 WHERE`)).
+			WithArgs(nil, newDue, 2, sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, "loan.renew")
+		mock.ExpectCommit()
+
+		loan, err := svc.Renew(7, 9)
+		assert.NoError(t, err)
+		assert.Equal(t, newDue, loan.ExpectedReturnDate)
+		assert.Equal(t, 0, RenewalsLeft(svc.defaults, loan))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Renewal limit reached", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		expectLoan(mock, "issued", 2)
+		mock.ExpectRollback()
+
+		_, err := svc.Renew(7, 9)
+		assert.ErrorIs(t, err, ErrRenewalLimit)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Another reader is waiting", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		expectLoan(mock, "issued", 0)
+		expectHolds(mock, 1)
+		mock.ExpectRollback()
+
+		_, err := svc.Renew(7, 9)
+		assert.ErrorIs(t, err, ErrHoldsWaiting)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Returned loan", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		expectLoan(mock, "returned", 0)
+		mock.ExpectRollback()
+
+		_, err := svc.Renew(7, 9)
+		assert.ErrorIs(t, err, ErrLoanClosed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Overdue loan", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		expectLoanDue(mock, "issued", 0, time.Now().AddDate(0, 0, -1).Unix())
+		mock.ExpectRollback()
+
+		_, err := svc.Renew(7, 9)
+		assert.ErrorIs(t, err, ErrLoanOverdue)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
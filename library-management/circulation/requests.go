@@ -0,0 +1,134 @@
+package circulation
+
+import (
+	"errors"
+	"fmt"
+	"library-management/audit"
+	"library-management/models"
+	"library-management/notify"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRequestClosed     = errors.New("request is no longer pending")
+	ErrInvalidTransition = errors.New("invalid request status change")
+	ErrReasonRequired    = errors.New("a reason is required")
+)
+
+// requestTransitions lists the statuses each request status may move to
+var requestTransitions = map[string][]string{
+	models.RequestPending:  {models.RequestApproved, models.RequestRejected, models.RequestCancelled, models.RequestExpired},
+	models.RequestApproved: {models.RequestFulfilled},
+}
+
+// CanTransition reports whether a request may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, next := range requestTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionFrom lists the statuses a request may be in to move to the given one,
+// for bulk updates that cannot check each row in Go
+func transitionFrom(to string) []string {
+	var from []string
+	for status := range requestTransitions {
+		if CanTransition(status, to) {
+			from = append(from, status)
+		}
+	}
+	sort.Strings(from)
+	return from
+}
+
+// Transition moves a request to a new status, refusing changes the state machine does not allow
+func Transition(request *models.RequestEvent, to string) error {
+	if CanTransition(request.Status, to) {
+		request.Status = to
+		return nil
+	}
+
+	switch {
+	case request.Status == models.RequestApproved && to == models.RequestApproved:
+		return ErrAlreadyApproved
+	case request.Status != models.RequestPending:
+		return ErrRequestClosed
+	default:
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, request.Status, to)
+	}
+}
+
+// RejectRequest turns down a pending request, recording who rejected it and why, and tells the reader
+func (s *Service) RejectRequest(requestID, adminID uint, reason string) (*models.RequestEvent, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	var request models.RequestEvent
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := forUpdate(tx).First(&request, requestID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRequestNotFound
+			}
+			return err
+		}
+		before := request
+		if err := Transition(&request, models.RequestRejected); err != nil {
+			return err
+		}
+
+		closedAt := time.Now().Unix()
+		request.RejectionReason = reason
+		request.RejectedByID = &adminID
+		request.ClosedAt = &closedAt
+		if err := tx.Save(&request).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, "request.reject", "request", request.ID, before, request); err != nil {
+			return err
+		}
+
+		if request.RequestType != "issue" {
+			return nil
+		}
+		return s.notifyRequest(tx, &request, notify.EventIssueDisapproved, map[string]interface{}{"Reason": reason})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// CancelRequest withdraws one of a reader's pending requests
+func (s *Service) CancelRequest(requestID, readerID uint) (*models.RequestEvent, error) {
+	var request models.RequestEvent
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := forUpdate(tx).Where("id = ? AND reader_id = ?", requestID, readerID).First(&request).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRequestNotFound
+			}
+			return err
+		}
+		before := request
+		if err := Transition(&request, models.RequestCancelled); err != nil {
+			return err
+		}
+
+		closedAt := time.Now().Unix()
+		request.ClosedAt = &closedAt
+		if err := tx.Save(&request).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, "request.cancel", "request", request.ID, before, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
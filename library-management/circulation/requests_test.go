@@ -0,0 +1,122 @@
+package circulation
+
+import (
+	"library-management/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, CanTransition(models.RequestPending, models.RequestApproved))
+	assert.True(t, CanTransition(models.RequestPending, models.RequestRejected))
+	assert.True(t, CanTransition(models.RequestApproved, models.RequestFulfilled))
+	assert.False(t, CanTransition(models.RequestApproved, models.RequestRejected))
+	assert.False(t, CanTransition(models.RequestRejected, models.RequestApproved))
+	assert.False(t, CanTransition(models.RequestExpired, models.RequestCancelled))
+
+	assert.Equal(t, []string{models.RequestPending}, transitionFrom(models.RequestExpired))
+	assert.Equal(t, []string{models.RequestApproved}, transitionFrom(models.RequestFulfilled))
+}
+
+func TestRejectRequest(t *testing.T) {
+	requestColumns := []string{"id", "book_id", "library_id", "reader_id", "request_type", "request_date", "status"}
+	requestQuery := regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE "request_events"."id" = $1 AND "request_events"."deleted_at" IS NULL ORDER BY "request_events"."id" LIMIT $2 FOR UPDATE`)
+
+	t.Run("Records the reason and the admin", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "issue", time.Now().Unix(), "pending"))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "123456789", 1, 2, sqlmock.AnyArg(), nil, nil, "issue", nil, "rejected", "Reference copy only", 9, sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, "request.reject")
+		mock.ExpectCommit()
+
+		request, err := svc.RejectRequest(1, 9, "Reference copy only")
+		assert.NoError(t, err)
+		assert.Equal(t, models.RequestRejected, request.Status)
+		assert.Equal(t, uint(9), *request.RejectedByID)
+		assert.NotNil(t, request.ClosedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Already approved", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "issue", time.Now().Unix(), "approved"))
+		mock.ExpectRollback()
+
+		_, err := svc.RejectRequest(1, 9, "Too late")
+		assert.ErrorIs(t, err, ErrRequestClosed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Reason is required", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		_, err := svc.RejectRequest(1, 9, "")
+		assert.ErrorIs(t, err, ErrReasonRequired)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCancelRequest(t *testing.T) {
+	requestColumns := []string{"id", "book_id", "library_id", "reader_id", "request_type", "request_date", "status"}
+	requestQuery := regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE (id = $1 AND reader_id = $2) AND "request_events"."deleted_at" IS NULL ORDER BY "request_events"."id" LIMIT $3 FOR UPDATE`)
+
+	t.Run("Pending request is withdrawn", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 2, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "issue", time.Now().Unix(), "pending"))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, "request.cancel")
+		mock.ExpectCommit()
+
+		request, err := svc.CancelRequest(1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, models.RequestCancelled, request.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Someone else's request", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 3, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns))
+		mock.ExpectRollback()
+
+		_, err := svc.CancelRequest(1, 3)
+		assert.ErrorIs(t, err, ErrRequestNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Approved request", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 2, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "issue", time.Now().Unix(), "approved"))
+		mock.ExpectRollback()
+
+		_, err := svc.CancelRequest(1, 2)
+		assert.ErrorIs(t, err, ErrRequestClosed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,387 @@
+// Package circulation issues and receives physical book copies. Every change to
+// a loan, the copy it covers and the book's derived counters happens in one
+// transaction with the book row locked, so concurrent approvals cannot oversell a title.
+package circulation
+
+import (
+	"context"
+	"errors"
+	"library-management/audit"
+	"library-management/config"
+	"library-management/models"
+	"library-management/notify"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRequestNotFound   = errors.New("request not found")
+	ErrNotIssueRequest   = errors.New("not an issue request")
+	ErrNotReturnRequest  = errors.New("not a return request")
+	ErrAlreadyApproved   = errors.New("request is already approved")
+	ErrBookNotFound      = errors.New("book not found in this library")
+	ErrNoCopiesAvailable = errors.New("no available copies to issue")
+	ErrAlreadyReturned   = errors.New("book has already been returned")
+	ErrCopyNotFound      = errors.New("copy not found")
+	ErrCopyUnavailable   = errors.New("copy is not available")
+	ErrCopyMismatch      = errors.New("copy does not belong to this book")
+	ErrNoActiveLoan      = errors.New("copy is not on loan")
+)
+
+// IssueInput identifies the book, and optionally the exact copy, to lend
+type IssueInput struct {
+	ISBN       string
+	LibraryID  uint
+	Barcode    string // Any available copy when empty
+	ReaderID   uint
+	ApproverID uint
+}
+
+// Service runs circulation transactions against the database
+type Service struct {
+	db                *gorm.DB
+	defaults          models.CirculationPolicy
+	requestExpiryDays int
+	dueReminderDays   int
+	notifier          *notify.Notifier
+}
+
+// NewService returns a Service that applies cfg to libraries without their own policy
+func NewService(db *gorm.DB, cfg config.CirculationConfig) *Service {
+	return &Service{
+		db:                db,
+		defaults:          DefaultPolicy(cfg),
+		requestExpiryDays: cfg.RequestExpiryDays,
+		dueReminderDays:   cfg.DueReminderDays,
+	}
+}
+
+// WithNotifier makes the service tell readers about approvals, due dates and holds
+func (s *Service) WithNotifier(n *notify.Notifier) *Service {
+	s.notifier = n
+	return s
+}
+
+// WithContext returns a copy of the service whose transactions run under ctx,
+// which attributes their changes to the audit actor it carries
+func (s *Service) WithContext(ctx context.Context) *Service {
+	scoped := *s
+	scoped.db = s.db.WithContext(ctx)
+	return &scoped
+}
+
+// forUpdate locks the selected rows until the transaction ends
+func forUpdate(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// Issue lends a copy of a book directly to a reader
+func (s *Service) Issue(in IssueInput) (*models.IssueRegistry, error) {
+	var issue *models.IssueRegistry
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		issue, err = s.issue(tx, in)
+		return err
+	})
+	return issue, err
+}
+
+// ApproveIssue approves a pending issue request and lends the book to its reader,
+// using the copy with the given barcode or any available one
+func (s *Service) ApproveIssue(requestID, approverID uint, barcode string) (*models.IssueRegistry, error) {
+	var issue *models.IssueRegistry
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		request, err := lockPendingRequest(tx, requestID, "issue")
+		if err != nil {
+			return err
+		}
+
+		issue, err = s.issue(tx, IssueInput{
+			ISBN:       request.BookID,
+			LibraryID:  request.LibraryID,
+			Barcode:    barcode,
+			ReaderID:   request.ReaderID,
+			ApproverID: approverID,
+		})
+		if err != nil {
+			return err
+		}
+
+		request.IssueID = &issue.ID
+		if err := approve(tx, request, approverID, issue.IssueDate); err != nil {
+			return err
+		}
+		return s.notifyLoan(tx, issue, notify.EventIssueApproved)
+	})
+	return issue, err
+}
+
+// ApproveReturn approves a pending return request, closes the loan and puts the
+// copy back on the shelf; condition records damage noticed at the desk.
+// The fine is nil unless the book came back late.
+func (s *Service) ApproveReturn(requestID, approverID uint, condition string) (*models.IssueRegistry, *models.Fine, error) {
+	var issue *models.IssueRegistry
+	var fine *models.Fine
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		request, err := lockPendingRequest(tx, requestID, "return")
+		if err != nil {
+			return err
+		}
+		if request.IssueID == nil {
+			return ErrNotReturnRequest
+		}
+
+		var loan models.IssueRegistry
+		if err := forUpdate(tx).First(&loan, *request.IssueID).Error; err != nil {
+			return err
+		}
+		fine, err = s.closeLoan(tx, &loan, approverID, condition)
+		if err != nil {
+			return err
+		}
+		issue = &loan
+
+		return approve(tx, request, approverID, loan.ReturnDate)
+	})
+	return issue, fine, err
+}
+
+// ReturnCopy checks in a copy scanned at the desk, approving any pending return request for it.
+// The fine is nil unless the book came back late.
+func (s *Service) ReturnCopy(barcode string, approverID uint, condition string) (*models.IssueRegistry, *models.Fine, error) {
+	var issue *models.IssueRegistry
+	var fine *models.Fine
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var bookCopy models.BookCopy
+		if err := tx.Where("barcode = ?", barcode).First(&bookCopy).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCopyNotFound
+			}
+			return err
+		}
+
+		var loan models.IssueRegistry
+		err := forUpdate(tx).Where("copy_id = ? AND issue_status = ?", bookCopy.ID, "issued").First(&loan).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNoActiveLoan
+		}
+		if err != nil {
+			return err
+		}
+		fine, err = s.closeLoan(tx, &loan, approverID, condition)
+		if err != nil {
+			return err
+		}
+		issue = &loan
+
+		return tx.Model(&models.RequestEvent{}).
+			Where("issue_id = ? AND request_type = ? AND status IN ?", loan.ID, "return", transitionFrom(models.RequestApproved)).
+			Updates(map[string]interface{}{"status": models.RequestApproved, "approval_date": loan.ReturnDate, "approver_id": approverID}).Error
+	})
+	return issue, fine, err
+}
+
+// issue takes one available copy of the book and records the loan; tx must be a transaction
+func (s *Service) issue(tx *gorm.DB, in IssueInput) (*models.IssueRegistry, error) {
+	// The book row is locked first so counters are recomputed from a settled set of copies
+	var book models.Book
+	err := forUpdate(tx).Where("isbn = ? AND library_id = ?", in.ISBN, in.LibraryID).First(&book).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := s.Policy(tx, book.LibraryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLoanLimit(tx, policy, in.ReaderID); err != nil {
+		return nil, err
+	}
+
+	// A loan fulfils the reader's hold on the title; a ready hold hands over the copy set aside
+	var hold models.Hold
+	err = forUpdate(tx).Where("isbn = ? AND library_id = ? AND reader_id = ? AND status IN ?", book.ISBN, book.LibraryID, in.ReaderID, []string{models.HoldWaiting, models.HoldReady}).
+		First(&hold).Error
+	hasHold := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var bookCopy models.BookCopy
+	switch {
+	case in.Barcode != "":
+		err = forUpdate(tx).Where("barcode = ?", in.Barcode).First(&bookCopy).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCopyNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if bookCopy.BookID != book.ID {
+			return nil, ErrCopyMismatch
+		}
+		heldForReader := hasHold && hold.CopyID != nil && *hold.CopyID == bookCopy.ID
+		if bookCopy.Status != models.CopyAvailable && !heldForReader {
+			return nil, ErrCopyUnavailable
+		}
+	case hasHold && hold.CopyID != nil:
+		if err := forUpdate(tx).First(&bookCopy, *hold.CopyID).Error; err != nil {
+			return nil, err
+		}
+	default:
+		err = forUpdate(tx).Where("book_id = ? AND status = ?", book.ID, models.CopyAvailable).Order("id").First(&bookCopy).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoCopiesAvailable
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Model(&bookCopy).Update("status", models.CopyIssued).Error; err != nil {
+		return nil, err
+	}
+
+	if hasHold {
+		if err := closeHold(tx, &hold, models.HoldFulfilled); err != nil {
+			return nil, err
+		}
+		// The reader took a different copy, so the one set aside goes to the next in line
+		if hold.CopyID != nil && *hold.CopyID != bookCopy.ID {
+			if err := s.shelveCopy(tx, &book, *hold.CopyID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := SyncCopyCounts(tx, &book); err != nil {
+		return nil, err
+	}
+
+	issueDate := time.Now()
+	issue := models.IssueRegistry{
+		ISBN:               book.ISBN,
+		LibraryID:          book.LibraryID,
+		CopyID:             &bookCopy.ID,
+		ReaderID:           in.ReaderID,
+		IssueApproverID:    in.ApproverID,
+		IssueStatus:        "issued",
+		IssueDate:          issueDate.Unix(),
+		ExpectedReturnDate: issueDate.AddDate(0, 0, policy.LoanPeriodDays).Unix(),
+	}
+	if err := tx.Create(&issue).Error; err != nil {
+		return nil, err
+	}
+	if err := audit.Record(tx, "loan.issue", "loan", issue.ID, nil, issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// closeLoan marks a locked loan returned, charges any overdue fine and shelves
+// its copy, or restocks the legacy counter for loans that predate copy tracking
+func (s *Service) closeLoan(tx *gorm.DB, loan *models.IssueRegistry, approverID uint, condition string) (*models.Fine, error) {
+	if loan.IssueStatus == "returned" {
+		return nil, ErrAlreadyReturned
+	}
+
+	var book models.Book
+	if err := forUpdate(tx).Where("isbn = ? AND library_id = ?", loan.ISBN, loan.LibraryID).First(&book).Error; err != nil {
+		return nil, err
+	}
+
+	before := *loan
+	loan.IssueStatus = "returned"
+	loan.ReturnDate = time.Now().Unix()
+	loan.ReturnApproverID = approverID
+	if err := tx.Save(loan).Error; err != nil {
+		return nil, err
+	}
+	if err := audit.Record(tx, "loan.return", "loan", loan.ID, before, loan); err != nil {
+		return nil, err
+	}
+
+	// The issue request that opened the loan is complete
+	if err := tx.Model(&models.RequestEvent{}).
+		Where("issue_id = ? AND request_type = ? AND status IN ?", loan.ID, "issue", transitionFrom(models.RequestFulfilled)).
+		Update("status", models.RequestFulfilled).Error; err != nil {
+		return nil, err
+	}
+
+	fine, _, err := s.chargeFine(tx, loan, loan.ReturnDate)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.restock(tx, &book, loan, condition); err != nil {
+		return nil, err
+	}
+	return fine, nil
+}
+
+// restock returns a closed loan's copy to circulation, or marks it damaged
+func (s *Service) restock(tx *gorm.DB, book *models.Book, loan *models.IssueRegistry, condition string) error {
+	if loan.CopyID == nil {
+		return tx.Model(book).
+			Where("available_copies < total_copies").
+			Update("available_copies", gorm.Expr("available_copies + 1")).Error
+	}
+
+	if condition != "" {
+		updates := map[string]interface{}{"condition": condition}
+		if condition == models.CopyDamaged {
+			updates["status"] = models.CopyDamaged
+		}
+		if err := tx.Model(&models.BookCopy{}).Where("id = ?", *loan.CopyID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if condition == models.CopyDamaged {
+			return SyncCopyCounts(tx, book)
+		}
+	}
+	if err := s.shelveCopy(tx, book, *loan.CopyID); err != nil {
+		return err
+	}
+	return SyncCopyCounts(tx, book)
+}
+
+// lockPendingRequest loads a pending request of the given type and locks it
+func lockPendingRequest(tx *gorm.DB, requestID uint, requestType string) (*models.RequestEvent, error) {
+	var request models.RequestEvent
+	err := forUpdate(tx).First(&request, requestID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if request.RequestType != requestType {
+		if requestType == "issue" {
+			return nil, ErrNotIssueRequest
+		}
+		return nil, ErrNotReturnRequest
+	}
+	if !CanTransition(request.Status, models.RequestApproved) {
+		return nil, Transition(&request, models.RequestApproved)
+	}
+	return &request, nil
+}
+
+// approve stamps the request as approved by approverID
+func approve(tx *gorm.DB, request *models.RequestEvent, approverID uint, at int64) error {
+	before := *request
+	if err := Transition(request, models.RequestApproved); err != nil {
+		return err
+	}
+	request.ApprovalDate = &at
+	request.ApproverID = &approverID
+	if err := tx.Save(request).Error; err != nil {
+		return err
+	}
+	return audit.Record(tx, "request.approve", "request", request.ID, before, request)
+}
@@ -0,0 +1,324 @@
+package circulation
+
+import (
+	"library-management/config"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func newTestService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+	return NewService(gormDB, config.CirculationConfig{
+		LoanPeriodDays:    14,
+		MaxLoans:          5,
+		HoldPickupDays:    3,
+		MaxRenewals:       2,
+		FinePerDayCents:   25,
+		MaxFineCents:      1000,
+		FineGraceDays:     1,
+		MaxBalanceCents:   500,
+		RequestExpiryDays: 7,
+		DueReminderDays:   2,
+	}), mock
+}
+
+var (
+	bookQuery = regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL ORDER BY "books"."id" LIMIT $3 FOR UPDATE`)
+	copyQuery = regexp.QuoteMeta(`SELECT * FROM "book_copies" WHERE (book_id = $1 AND status = $2) AND "book_copies"."deleted_at" IS NULL ORDER BY id,"book_copies"."id" LIMIT $3 FOR UPDATE`)
+)
+
+// expectDefaultPolicy expects library 1 to have no policy of its own
+func expectDefaultPolicy(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "circulation_policies" WHERE library_id = $1`)).
+		WithArgs(1, 1).
+		WillReturnError(gorm.ErrRecordNotFound)
+}
+
+// expectOpenLoans expects reader 2's open loans in library 1 to be counted
+func expectOpenLoans(mock sqlmock.Sqlmock, n int) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "issue_registries" WHERE (reader_id = $1 AND library_id = $2 AND issue_status = $3)`)).
+		WithArgs(2, 1, "issued").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(n))
+}
+
+// expectNoHold expects the loan policy checks to pass and the reader to have no active hold on the title
+func expectNoHold(mock sqlmock.Sqlmock) {
+	expectDefaultPolicy(mock)
+	expectOpenLoans(mock, 0)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "holds" WHERE (isbn = $1 AND library_id = $2 AND reader_id = $3 AND status IN ($4,$5))`)).
+		WithArgs("123456789", 1, 2, "waiting", "ready", 1).
+		WillReturnError(gorm.ErrRecordNotFound)
+}
+
+// expectFulfilRequest expects the issue request behind loan 7 to be marked fulfilled on return
+func expectFulfilRequest(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET "status"=$1,"updated_at"=$2 WHERE (issue_id = $3 AND request_type = $4 AND status IN ($5))`)).
+		WithArgs("fulfilled", sqlmock.AnyArg(), 7, "issue", "approved").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectCheckout expects an available copy of book 3 to be taken and the loan recorded
+func expectCheckout(mock sqlmock.Sqlmock) {
+	expectNoHold(mock)
+	mock.ExpectQuery(copyQuery).
+		WithArgs(3, "available", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "barcode", "status"}).AddRow(11, 3, "000003-001", "available"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_copies" SET "status"=$1,"updated_at"=$2 WHERE`)).
+		WithArgs("issued", sqlmock.AnyArg(), 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSyncCounts(mock, 0)
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "issue_registries"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	expectAudit(mock, "loan.issue")
+}
+
+// expectSyncCounts expects book 3's counters to be recomputed from its copies
+func expectSyncCounts(mock sqlmock.Sqlmock, available int) {
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "available_copies"=(SELECT count(*) FROM "book_copies" WHERE (book_id = $1 AND status = $2)`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "total_copies","available_copies" FROM "books"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"total_copies", "available_copies"}).AddRow(1, available))
+}
+
+// expectAudit expects an event for the given action; these tests run without an actor
+func expectAudit(mock sqlmock.Sqlmock, action string) {
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_events"`)).
+		WithArgs(sqlmock.AnyArg(), nil, "system", action, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+func TestIssue(t *testing.T) {
+	in := IssueInput{ISBN: "123456789", LibraryID: 1, ReaderID: 2, ApproverID: 9}
+
+	t.Run("Locks the book and takes a copy", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "available_copies"}).AddRow(3, "123456789", 1, 1))
+		expectCheckout(mock)
+		mock.ExpectCommit()
+
+		issue, err := svc.Issue(in)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(7), issue.ID)
+		assert.Equal(t, "issued", issue.IssueStatus)
+		assert.Equal(t, uint(1), issue.LibraryID)
+		assert.Equal(t, uint(11), *issue.CopyID)
+		assert.Equal(t, int64(14*24*time.Hour/time.Second), issue.ExpectedReturnDate-issue.IssueDate)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No copies left", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "available_copies"}).AddRow(3, "123456789", 1, 0))
+		expectNoHold(mock)
+		mock.ExpectQuery(copyQuery).
+			WithArgs(3, "available", 1).
+			WillReturnError(gorm.ErrRecordNotFound)
+		mock.ExpectRollback()
+
+		_, err := svc.Issue(in)
+		assert.ErrorIs(t, err, ErrNoCopiesAvailable)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown book", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnError(gorm.ErrRecordNotFound)
+		mock.ExpectRollback()
+
+		_, err := svc.Issue(in)
+		assert.ErrorIs(t, err, ErrBookNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Scanned copy already on loan", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+		expectNoHold(mock)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_copies" WHERE barcode = $1 AND "book_copies"."deleted_at" IS NULL ORDER BY "book_copies"."id" LIMIT $2 FOR UPDATE`)).
+			WithArgs("000003-001", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "barcode", "status"}).AddRow(11, 3, "000003-001", "issued"))
+		mock.ExpectRollback()
+
+		scanned := in
+		scanned.Barcode = "000003-001"
+		_, err := svc.Issue(scanned)
+		assert.ErrorIs(t, err, ErrCopyUnavailable)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Library policy caps concurrent loans", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "circulation_policies" WHERE library_id = $1`)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "library_id", "loan_period_days", "max_loans"}).AddRow(4, 1, 7, 1))
+		expectOpenLoans(mock, 1)
+		mock.ExpectRollback()
+
+		_, err := svc.Issue(in)
+		assert.ErrorIs(t, err, ErrLoanLimit)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Scanned copy of another book", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+		expectNoHold(mock)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_copies" WHERE barcode = $1`)).
+			WithArgs("000004-001", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "barcode", "status"}).AddRow(12, 4, "000004-001", "available"))
+		mock.ExpectRollback()
+
+		scanned := in
+		scanned.Barcode = "000004-001"
+		_, err := svc.Issue(scanned)
+		assert.ErrorIs(t, err, ErrCopyMismatch)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestApproveIssue(t *testing.T) {
+	requestColumns := []string{"id", "book_id", "library_id", "reader_id", "request_type", "request_date", "approval_date", "approver_id", "status"}
+	requestQuery := regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE "request_events"."id" = $1 AND "request_events"."deleted_at" IS NULL ORDER BY "request_events"."id" LIMIT $2 FOR UPDATE`)
+
+	t.Run("Approved concurrently by another admin", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "issue", time.Now().Unix(), time.Now().Unix(), 4, "approved"))
+		mock.ExpectRollback()
+
+		_, err := svc.ApproveIssue(1, 9, "")
+		assert.ErrorIs(t, err, ErrAlreadyApproved)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Return request", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "return", time.Now().Unix(), nil, nil, "pending"))
+		mock.ExpectRollback()
+
+		_, err := svc.ApproveIssue(1, 9, "")
+		assert.ErrorIs(t, err, ErrNotIssueRequest)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Issues the book and stamps the request", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "issue", time.Now().Unix(), nil, nil, "pending"))
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "available_copies"}).AddRow(3, "123456789", 1, 2))
+		expectCheckout(mock)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "123456789", 1, 2, sqlmock.AnyArg(), sqlmock.AnyArg(), 9, "issue", 7, "approved", "", nil, nil, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, "request.approve")
+		mock.ExpectCommit()
+
+		issue, err := svc.ApproveIssue(1, 9, "")
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), issue.ReaderID)
+		assert.Equal(t, uint(9), issue.IssueApproverID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestReturnCopy(t *testing.T) {
+	loanColumns := []string{"id", "isbn", "library_id", "copy_id", "reader_id", "issue_status"}
+
+	t.Run("Damaged copy is kept off the shelf", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_copies" WHERE barcode = $1`)).
+			WithArgs("000003-001", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "barcode", "status"}).AddRow(11, 3, "000003-001", "issued"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE (copy_id = $1 AND issue_status = $2)`)).
+			WithArgs(11, "issued", 1).
+			WillReturnRows(sqlmock.NewRows(loanColumns).AddRow(7, "123456789", 1, 11, 2, "issued"))
+		mock.ExpectQuery(bookQuery).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "issue_registries" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, "loan.return")
+		expectFulfilRequest(mock)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_copies" SET "condition"=$1,"status"=$2,"updated_at"=$3 WHERE id = $4`)).
+			WithArgs("damaged", "damaged", sqlmock.AnyArg(), 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectSyncCounts(mock, 0)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET "approval_date"=$1,"approver_id"=$2,"status"=$3,"updated_at"=$4 WHERE (issue_id = $5 AND request_type = $6 AND status IN ($7))`)).
+			WithArgs(sqlmock.AnyArg(), 9, "approved", sqlmock.AnyArg(), 7, "return", "pending").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		issue, fine, err := svc.ReturnCopy("000003-001", 9, "damaged")
+		assert.NoError(t, err)
+		assert.Equal(t, "returned", issue.IssueStatus)
+		assert.Equal(t, uint(9), issue.ReturnApproverID)
+		assert.Nil(t, fine)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Copy not on loan", func(t *testing.T) {
+		svc, mock := newTestService(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_copies" WHERE barcode = $1`)).
+			WithArgs("000003-002", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "barcode", "status"}).AddRow(12, 3, "000003-002", "available"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE (copy_id = $1 AND issue_status = $2)`)).
+			WithArgs(12, "issued", 1).
+			WillReturnError(gorm.ErrRecordNotFound)
+		mock.ExpectRollback()
+
+		_, _, err := svc.ReturnCopy("000003-002", 9, "")
+		assert.ErrorIs(t, err, ErrNoActiveLoan)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"library-management/models"
+	"library-management/storage"
+	"library-management/utils"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB returns an empty in-memory database with the full schema
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	utils.SetPasswordHasher(utils.NewBcryptHasher(bcrypt.MinCost))
+
+	db, err := storage.Open(storage.SQLite, storage.Memory)
+	assert.NoError(t, err)
+	db.Logger = logger.Discard
+	assert.NoError(t, storage.PrepareSchema(context.Background(), db))
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, db *gorm.DB)
+		command string
+		args    []string
+		err     string
+		check   func(t *testing.T, db *gorm.DB)
+	}{
+		{
+			name:    "Create owner",
+			command: "create-owner",
+			args:    []string{"-name", "Ada", "-email", "ada@example.com", "-password", "password123"},
+			check: func(t *testing.T, db *gorm.DB) {
+				var owner models.User
+				assert.NoError(t, db.Where("email = ?", "ada@example.com").First(&owner).Error)
+				assert.Equal(t, "owner", owner.Role)
+				match, _ := utils.CheckPassword(owner.Password, "password123")
+				assert.True(t, match)
+			},
+		},
+		{
+			name:    "Create owner needs an email",
+			command: "create-owner",
+			args:    []string{"-name", "Ada", "-password", "password123"},
+			err:     "-email is required",
+		},
+		{
+			name:    "Create owner rejects a short password",
+			command: "create-owner",
+			args:    []string{"-name", "Ada", "-email", "ada@example.com", "-password", "short"},
+			err:     "password must be at least 8 characters long",
+		},
+		{
+			name: "Reset password revokes sessions",
+			setup: func(t *testing.T, db *gorm.DB) {
+				user, err := createAccount(db, "Ada", "ada@example.com", "", "password123", "user")
+				assert.NoError(t, err)
+				assert.NoError(t, db.Create(&models.RefreshToken{UserID: user.ID, TokenHash: "hash", FamilyID: "family", ExpiresAt: 1 << 40}).Error)
+			},
+			command: "reset-password",
+			args:    []string{"-email", "ada@example.com", "-password", "new-password"},
+			check: func(t *testing.T, db *gorm.DB) {
+				var user models.User
+				assert.NoError(t, db.Where("email = ?", "ada@example.com").First(&user).Error)
+				match, _ := utils.CheckPassword(user.Password, "new-password")
+				assert.True(t, match)
+
+				var token models.RefreshToken
+				assert.NoError(t, db.Where("user_id = ?", user.ID).First(&token).Error)
+				assert.NotNil(t, token.RevokedAt)
+			},
+		},
+		{
+			name:    "Reset password of an unknown user",
+			command: "reset-password",
+			args:    []string{"-email", "nobody@example.com", "-password", "new-password"},
+			err:     "user nobody@example.com: record not found",
+		},
+		{
+			name:    "Create library",
+			command: "create-library",
+			args:    []string{"-name", "Central"},
+			check: func(t *testing.T, db *gorm.DB) {
+				var library models.Library
+				assert.NoError(t, db.Where("name = ?", "Central").First(&library).Error)
+			},
+		},
+		{
+			name: "Assign admin",
+			setup: func(t *testing.T, db *gorm.DB) {
+				_, err := createAccount(db, "Grace", "grace@example.com", "", "password123", "admin")
+				assert.NoError(t, err)
+				assert.NoError(t, db.Create(&models.Library{Name: "Central"}).Error)
+			},
+			command: "assign-admin",
+			args:    []string{"-email", "grace@example.com", "-library-id", "1"},
+			check: func(t *testing.T, db *gorm.DB) {
+				var count int64
+				assert.NoError(t, db.Model(&models.UserLibrary{}).Where("library_id = ?", 1).Count(&count).Error)
+				assert.Equal(t, int64(1), count)
+			},
+		},
+		{
+			name: "Assign admin refuses readers",
+			setup: func(t *testing.T, db *gorm.DB) {
+				_, err := createAccount(db, "Ada", "ada@example.com", "", "password123", "user")
+				assert.NoError(t, err)
+			},
+			command: "assign-admin",
+			args:    []string{"-email", "ada@example.com", "-library-id", "1"},
+			err:     `user ada@example.com has role "user", expected admin`,
+		},
+		{
+			name:    "Assign admin needs a library",
+			command: "assign-admin",
+			args:    []string{"-email", "grace@example.com"},
+			err:     "-library-id is required",
+		},
+		{
+			name: "Seed demo is idempotent",
+			setup: func(t *testing.T, db *gorm.DB) {
+				assert.NoError(t, seedDemo(db, nil))
+			},
+			command: "seed-demo",
+			check: func(t *testing.T, db *gorm.DB) {
+				var users, books, copies int64
+				assert.NoError(t, db.Model(&models.User{}).Count(&users).Error)
+				assert.NoError(t, db.Model(&models.Book{}).Count(&books).Error)
+				assert.NoError(t, db.Model(&models.BookCopy{}).Count(&copies).Error)
+				assert.Equal(t, int64(3), users)
+				assert.Equal(t, int64(3), books)
+				assert.Equal(t, int64(6), copies)
+
+				var reader models.User
+				assert.NoError(t, db.Where("email = ?", "reader@demo.local").First(&reader).Error)
+				match, _ := utils.CheckPassword(reader.Password, "password123")
+				assert.True(t, match)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			if tt.setup != nil {
+				tt.setup(t, db)
+			}
+
+			err := commands[tt.command].run(db, tt.args)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			tt.check(t, db)
+		})
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  string
+		stdin string
+		want  string
+		err   string
+	}{
+		{"From the flag", "password123", "", "password123", ""},
+		{"From stdin", "", "password123\r\n", "password123", ""},
+		{"Last line without newline", "", "password123", "password123", ""},
+		{"Too short", "", "short\n", "", "password must be at least 8 characters long"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readPassword(tt.flag, strings.NewReader(tt.stdin))
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
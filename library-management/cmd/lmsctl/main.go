@@ -0,0 +1,122 @@
+// Command lmsctl administers a library management instance from the shell:
+// bootstrapping the first owner, resetting passwords, managing libraries and
+// running migrations. It shares models, configuration and password hashing with the server.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"library-management/config"
+	"library-management/storage"
+	"library-management/utils"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// command is one lmsctl subcommand
+type command struct {
+	summary string
+	run     func(db *gorm.DB, args []string) error
+}
+
+var commands = map[string]command{
+	"create-owner":   {"create an owner account", createOwner},
+	"reset-password": {"set a new password for a user and revoke their sessions", resetPassword},
+	"create-library": {"create a library", createLibrary},
+	"assign-admin":   {"give an admin access to a library", assignAdmin},
+	"migrate":        {"apply or roll back schema migrations (up, down [N], status)", migrate},
+	"seed-demo":      {"load a demo library with accounts and books", seedDemo},
+}
+
+func main() {
+	log.SetFlags(0)
+
+	configPath := flag.String("config", os.Getenv("LMS_CONFIG"), "path to a YAML or TOML config file")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "lmsctl: unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	utils.SetPasswordHasher(utils.NewBcryptHasher(cfg.Passwords.BcryptCost))
+
+	db, err := config.ConnectDatabase(cfg.Database)
+	if err != nil {
+		log.Fatalf("Database initialization failed: %v", err)
+	}
+
+	if err := cmd.run(db, flag.Args()[1:]); err != nil {
+		log.Fatalf("%s: %v", flag.Arg(0), err)
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage: lmsctl [-config file] <command> [flags]")
+	fmt.Fprintln(out, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-16s %s\n", name, commands[name].summary)
+	}
+
+	fmt.Fprintln(out, "\nRun `lmsctl <command> -h` for command flags.")
+	flag.PrintDefaults()
+}
+
+func migrate(db *gorm.DB, args []string) error {
+	return storage.Migrate(context.Background(), db, args, os.Stdout)
+}
+
+// readPassword returns the -password flag value or reads one line from stdin,
+// so passwords need not end up in shell history
+func readPassword(flagValue string, in io.Reader) (string, error) {
+	password := flagValue
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if len(password) < 8 {
+		return "", errors.New("password must be at least 8 characters long")
+	}
+	return password, nil
+}
+
+// required reports the first empty flag value as an error
+func required(fs *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if fs.Lookup(name).Value.String() == "" {
+			return fmt.Errorf("-%s is required", name)
+		}
+	}
+	return nil
+}
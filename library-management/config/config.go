@@ -1,49 +1,24 @@
 package config
 
 import (
-	"library-management/models"
-	"log"
+	"library-management/storage"
+	"log/slog"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-// DB holds the database connection instance
-var DB *gorm.DB
-
-// ConnectDatabase initializes the database connection
-func ConnectDatabase(isTest bool) (*gorm.DB, error) {
-	if isTest {
-		// Mock database for unit testing
-		return DB, nil // We just return the mock DB here in tests
-	}
-
-	// Actual database connection for normal use
-	dsn := "host=localhost user=postgres password=postgres dbname=library_management sslmode=disable"
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// ConnectDatabase opens the configured database and checks that it answers
+func ConnectDatabase(cfg DatabaseConfig) (*gorm.DB, error) {
+	database, err := storage.Open(cfg.Driver, cfg.DSN)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
 		return nil, err
 	}
 
 	// Perform a simple query to test the connection
-	database.Exec("SELECT 1")
-
-	// Auto-migrate database tables
-	err = database.AutoMigrate(
-		&models.Library{},
-		&models.User{},
-		&models.Book{},
-		&models.RequestEvent{},
-		&models.IssueRegistry{},
-		&models.UserLibrary{},
-	)
-	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+	if err := database.Exec("SELECT 1").Error; err != nil {
 		return nil, err
 	}
 
-	DB = database
-	log.Println("Database connected and migrated successfully!")
-	return DB, nil
+	slog.Info("Database connected successfully", "driver", database.Dialector.Name())
+	return database, nil
 }
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"library-management/utils"
+	"os"
+)
+
+// KeyConfig describes one JWT key. HS256 keys use Secret; RS256 and EdDSA keys
+// read a PEM file holding a private key (signing) or a public key (verify only).
+type KeyConfig struct {
+	ID        string `yaml:"id" toml:"id" json:"id"`
+	Algorithm string `yaml:"algorithm" toml:"algorithm" json:"algorithm"`
+	Secret    string `yaml:"secret" toml:"secret" json:"secret"`
+	KeyFile   string `yaml:"key_file" toml:"key_file" json:"key_file"`
+}
+
+// Configured reports whether a signing key was provided.
+// Without one the built-in development key stays active.
+func (c JWTConfig) Configured() bool {
+	return c.Current.Secret != "" || c.Current.KeyFile != ""
+}
+
+// KeySet builds the utils.KeySet described by the configuration
+func (c JWTConfig) KeySet() (*utils.KeySet, error) {
+	current, err := c.Current.key()
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make([]*utils.SigningKey, 0, len(c.Previous))
+	for _, kc := range c.Previous {
+		key, err := kc.key()
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+	return utils.NewKeySet(current, previous...)
+}
+
+func (kc KeyConfig) algorithm() string {
+	if kc.Algorithm == "" {
+		return utils.AlgHS256
+	}
+	return kc.Algorithm
+}
+
+func (kc KeyConfig) validate(current bool) error {
+	name := "jwt.previous key " + kc.ID
+	if current {
+		name = "jwt.current"
+	}
+
+	if kc.ID == "" {
+		return fmt.Errorf("%s: id is required", name)
+	}
+	switch kc.algorithm() {
+	case utils.AlgHS256:
+		if kc.Secret == "" {
+			return fmt.Errorf("%s: HS256 requires secret", name)
+		}
+	case utils.AlgRS256, utils.AlgEdDSA:
+		if kc.KeyFile == "" {
+			return fmt.Errorf("%s: %s requires key_file", name, kc.Algorithm)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported algorithm %q", name, kc.Algorithm)
+	}
+	return nil
+}
+
+func (kc KeyConfig) key() (*utils.SigningKey, error) {
+	if kc.algorithm() == utils.AlgHS256 {
+		return utils.NewHMACKey(kc.ID, []byte(kc.Secret))
+	}
+
+	pemData, err := os.ReadFile(kc.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", kc.ID, err)
+	}
+	return utils.ParsePEMKey(kc.ID, kc.Algorithm, pemData)
+}
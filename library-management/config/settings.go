@@ -0,0 +1,358 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete server configuration
+type Config struct {
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Database    DatabaseConfig    `yaml:"database" toml:"database"`
+	JWT         JWTConfig         `yaml:"jwt" toml:"jwt"`
+	Passwords   PasswordConfig    `yaml:"passwords" toml:"passwords"`
+	Circulation CirculationConfig `yaml:"circulation" toml:"circulation"`
+	Scheduler   SchedulerConfig   `yaml:"scheduler" toml:"scheduler"`
+	Notify      NotifyConfig      `yaml:"notify" toml:"notify"`
+	Search      SearchConfig      `yaml:"search" toml:"search"`
+	Log         LogConfig         `yaml:"log" toml:"log"`
+}
+
+// ServerConfig controls the HTTP listener
+type ServerConfig struct {
+	ListenAddr  string   `yaml:"listen_addr" toml:"listen_addr"`
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins"` // "*" allows any origin
+	// TrustedProxies are the IPs or CIDRs whose X-Forwarded-For is believed;
+	// none by default, so a client cannot forge its audited IP
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+// DatabaseConfig holds the database connection settings
+type DatabaseConfig struct {
+	Driver string `yaml:"driver" toml:"driver"` // postgres or sqlite
+	DSN    string `yaml:"dsn" toml:"dsn"`       // For sqlite a file path, or ":memory:"
+}
+
+// JWTConfig holds token lifetimes, the signing key and previous keys still accepted during rotation
+type JWTConfig struct {
+	AccessTokenTTLMinutes int         `yaml:"access_token_ttl_minutes" toml:"access_token_ttl_minutes"`
+	RefreshTokenTTLDays   int         `yaml:"refresh_token_ttl_days" toml:"refresh_token_ttl_days"`
+	Current               KeyConfig   `yaml:"current" toml:"current"`
+	Previous              []KeyConfig `yaml:"previous" toml:"previous"`
+	// AllowInsecureDevKey lets an instance without keys sign tokens with a
+	// well-known development secret; never set it in production
+	AllowInsecureDevKey bool `yaml:"allow_insecure_dev_key" toml:"allow_insecure_dev_key"`
+}
+
+// PasswordConfig controls password hashing
+type PasswordConfig struct {
+	BcryptCost int `yaml:"bcrypt_cost" toml:"bcrypt_cost"`
+}
+
+// CirculationConfig holds the circulation rules for libraries without their own policy
+type CirculationConfig struct {
+	LoanPeriodDays    int `yaml:"loan_period_days" toml:"loan_period_days"`
+	MaxLoans          int `yaml:"max_loans" toml:"max_loans"`               // Concurrent loans per reader, 0 for no limit
+	HoldPickupDays    int `yaml:"hold_pickup_days" toml:"hold_pickup_days"` // How long a ready hold waits for its reader
+	MaxRenewals       int `yaml:"max_renewals" toml:"max_renewals"`         // Renewals allowed per loan, 0 disables renewal
+	FinePerDayCents   int `yaml:"fine_per_day_cents" toml:"fine_per_day_cents"`
+	MaxFineCents      int `yaml:"max_fine_cents" toml:"max_fine_cents"`           // Cap on the fine for one loan, 0 for no cap
+	FineGraceDays     int `yaml:"fine_grace_days" toml:"fine_grace_days"`         // Overdue days not charged
+	MaxBalanceCents   int `yaml:"max_balance_cents" toml:"max_balance_cents"`     // Readers owing more may not request books, 0 for no limit
+	RequestExpiryDays int `yaml:"request_expiry_days" toml:"request_expiry_days"` // Unanswered issue requests expire after this long, 0 keeps them
+	DueReminderDays   int `yaml:"due_reminder_days" toml:"due_reminder_days"`     // Readers are reminded this long before a due date, 0 disables
+}
+
+// SchedulerConfig controls the background jobs run alongside the server
+type SchedulerConfig struct {
+	Enabled         bool `yaml:"enabled" toml:"enabled"`
+	IntervalMinutes int  `yaml:"interval_minutes" toml:"interval_minutes"` // How often each job runs
+}
+
+// NotifyConfig configures notification delivery. The in-app inbox is always on;
+// email and SMS are enabled by setting their server or webhook.
+type NotifyConfig struct {
+	SMTP            SMTPConfig `yaml:"smtp" toml:"smtp"`
+	SMSWebhookURL   string     `yaml:"sms_webhook_url" toml:"sms_webhook_url"`   // Receives {"to", "body"} as JSON
+	DeliverySeconds int        `yaml:"delivery_seconds" toml:"delivery_seconds"` // How often the outbox is drained
+	MaxAttempts     int        `yaml:"max_attempts" toml:"max_attempts"`         // Delivery attempts before a message is marked failed
+}
+
+// SMTPConfig holds the outgoing mail server settings
+type SMTPConfig struct {
+	Addr     string `yaml:"addr" toml:"addr"` // host:port
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+	From     string `yaml:"from" toml:"from"`
+}
+
+// SearchConfig tunes typo-tolerant search and autocomplete
+type SearchConfig struct {
+	Similarity      float64 `yaml:"similarity" toml:"similarity"`             // 0 to 1; how much of a misspelt word must match a title or author
+	SuggestionLimit int     `yaml:"suggestion_limit" toml:"suggestion_limit"` // Most completions and corrections returned at once
+}
+
+// LogConfig controls logging
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level"` // debug, info, warn or error
+}
+
+// Default returns the configuration used when no file or environment overrides are given
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			ListenAddr: ":8080",
+		},
+		Database: DatabaseConfig{
+			Driver: "postgres",
+			DSN:    "host=localhost user=postgres password=postgres dbname=library_management sslmode=disable",
+		},
+		JWT: JWTConfig{
+			AccessTokenTTLMinutes: 15,
+			RefreshTokenTTLDays:   30,
+		},
+		Passwords: PasswordConfig{
+			BcryptCost: 10,
+		},
+		Circulation: CirculationConfig{
+			LoanPeriodDays:    14,
+			MaxLoans:          5,
+			HoldPickupDays:    3,
+			MaxRenewals:       2,
+			FinePerDayCents:   25,
+			MaxFineCents:      1000,
+			FineGraceDays:     1,
+			MaxBalanceCents:   500,
+			RequestExpiryDays: 7,
+			DueReminderDays:   2,
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:         true,
+			IntervalMinutes: 10,
+		},
+		Notify: NotifyConfig{
+			SMTP: SMTPConfig{
+				From: "library@localhost",
+			},
+			DeliverySeconds: 60,
+			MaxAttempts:     5,
+		},
+		Search: SearchConfig{
+			Similarity:      0.5,
+			SuggestionLimit: 10,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+	}
+}
+
+// Load reads the configuration file at path (YAML or TOML, chosen by extension),
+// applies LMS_* environment overrides and validates the result.
+// An empty path skips the file and uses defaults plus environment.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			err = yaml.Unmarshal(data, cfg)
+		case ".toml":
+			err = toml.Unmarshal(data, cfg)
+		default:
+			return nil, fmt.Errorf("config file %s: unsupported format, use .yaml or .toml", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides file values with environment variables
+func (c *Config) applyEnv() error {
+	setString := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	setInt := func(name string, dst *int) error {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", name, v)
+		}
+		*dst = n
+		return nil
+	}
+	setBool := func(name string, dst *bool) error {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a boolean", name, v)
+		}
+		*dst = b
+		return nil
+	}
+
+	setString("LMS_LISTEN_ADDR", &c.Server.ListenAddr)
+	if v, ok := os.LookupEnv("LMS_CORS_ORIGINS"); ok {
+		c.Server.CORSOrigins = splitList(v)
+	}
+	if v, ok := os.LookupEnv("LMS_TRUSTED_PROXIES"); ok {
+		c.Server.TrustedProxies = splitList(v)
+	}
+	setString("LMS_DATABASE_DRIVER", &c.Database.Driver)
+	setString("LMS_DATABASE_DSN", &c.Database.DSN)
+	setString("LMS_SMTP_ADDR", &c.Notify.SMTP.Addr)
+	setString("LMS_SMTP_USERNAME", &c.Notify.SMTP.Username)
+	setString("LMS_SMTP_PASSWORD", &c.Notify.SMTP.Password)
+	setString("LMS_SMTP_FROM", &c.Notify.SMTP.From)
+	setString("LMS_SMS_WEBHOOK_URL", &c.Notify.SMSWebhookURL)
+	if err := setBool("LMS_SCHEDULER_ENABLED", &c.Scheduler.Enabled); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv("LMS_SEARCH_SIMILARITY"); ok {
+		similarity, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("LMS_SEARCH_SIMILARITY: %q is not a number", v)
+		}
+		c.Search.Similarity = similarity
+	}
+	setString("LMS_LOG_LEVEL", &c.Log.Level)
+	setString("LMS_JWT_KEY_ID", &c.JWT.Current.ID)
+	setString("LMS_JWT_ALGORITHM", &c.JWT.Current.Algorithm)
+	setString("LMS_JWT_SECRET", &c.JWT.Current.Secret)
+	setString("LMS_JWT_KEY_FILE", &c.JWT.Current.KeyFile)
+	if v, ok := os.LookupEnv("LMS_JWT_PREVIOUS_KEYS"); ok {
+		if err := json.Unmarshal([]byte(v), &c.JWT.Previous); err != nil {
+			return fmt.Errorf("LMS_JWT_PREVIOUS_KEYS: %w", err)
+		}
+	}
+	if err := setBool("LMS_JWT_ALLOW_INSECURE_DEV_KEY", &c.JWT.AllowInsecureDevKey); err != nil {
+		return err
+	}
+
+	for name, dst := range map[string]*int{
+		"LMS_JWT_ACCESS_TTL_MINUTES":     &c.JWT.AccessTokenTTLMinutes,
+		"LMS_JWT_REFRESH_TTL_DAYS":       &c.JWT.RefreshTokenTTLDays,
+		"LMS_BCRYPT_COST":                &c.Passwords.BcryptCost,
+		"LMS_LOAN_PERIOD_DAYS":           &c.Circulation.LoanPeriodDays,
+		"LMS_HOLD_PICKUP_DAYS":           &c.Circulation.HoldPickupDays,
+		"LMS_MAX_RENEWALS":               &c.Circulation.MaxRenewals,
+		"LMS_MAX_LOANS":                  &c.Circulation.MaxLoans,
+		"LMS_FINE_PER_DAY_CENTS":         &c.Circulation.FinePerDayCents,
+		"LMS_MAX_FINE_CENTS":             &c.Circulation.MaxFineCents,
+		"LMS_FINE_GRACE_DAYS":            &c.Circulation.FineGraceDays,
+		"LMS_MAX_BALANCE_CENTS":          &c.Circulation.MaxBalanceCents,
+		"LMS_REQUEST_EXPIRY_DAYS":        &c.Circulation.RequestExpiryDays,
+		"LMS_DUE_REMINDER_DAYS":          &c.Circulation.DueReminderDays,
+		"LMS_SCHEDULER_INTERVAL_MINUTES": &c.Scheduler.IntervalMinutes,
+		"LMS_NOTIFY_DELIVERY_SECONDS":    &c.Notify.DeliverySeconds,
+		"LMS_NOTIFY_MAX_ATTEMPTS":        &c.Notify.MaxAttempts,
+		"LMS_SEARCH_SUGGESTION_LIMIT":    &c.Search.SuggestionLimit,
+	} {
+		if err := setInt(name, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.ListenAddr != "", "server.listen_addr is required")
+	check(c.Database.Driver == "postgres" || c.Database.Driver == "sqlite", "database.driver %q must be postgres or sqlite", c.Database.Driver)
+	check(c.Database.DSN != "", "database.dsn is required")
+	check(c.JWT.AccessTokenTTLMinutes > 0, "jwt.access_token_ttl_minutes must be positive")
+	check(c.JWT.RefreshTokenTTLDays > 0, "jwt.refresh_token_ttl_days must be positive")
+	check(c.Passwords.BcryptCost >= 4 && c.Passwords.BcryptCost <= 31, "passwords.bcrypt_cost must be between 4 and 31")
+	check(c.Circulation.LoanPeriodDays > 0, "circulation.loan_period_days must be positive")
+	check(c.Circulation.HoldPickupDays > 0, "circulation.hold_pickup_days must be positive")
+	check(c.Circulation.MaxRenewals >= 0, "circulation.max_renewals must not be negative")
+	check(c.Circulation.MaxLoans >= 0, "circulation.max_loans must not be negative")
+	check(c.Circulation.FinePerDayCents >= 0, "circulation.fine_per_day_cents must not be negative")
+	check(c.Circulation.MaxFineCents >= 0, "circulation.max_fine_cents must not be negative")
+	check(c.Circulation.FineGraceDays >= 0, "circulation.fine_grace_days must not be negative")
+	check(c.Circulation.MaxBalanceCents >= 0, "circulation.max_balance_cents must not be negative")
+	check(c.Circulation.RequestExpiryDays >= 0, "circulation.request_expiry_days must not be negative")
+	check(c.Circulation.DueReminderDays >= 0, "circulation.due_reminder_days must not be negative")
+	check(c.Scheduler.IntervalMinutes > 0, "scheduler.interval_minutes must be positive")
+	check(c.Notify.DeliverySeconds > 0, "notify.delivery_seconds must be positive")
+	check(c.Notify.MaxAttempts > 0, "notify.max_attempts must be positive")
+	check(c.Search.Similarity > 0 && c.Search.Similarity <= 1, "search.similarity must be greater than 0 and at most 1")
+	check(c.Search.SuggestionLimit > 0, "search.suggestion_limit must be positive")
+	check(c.Notify.SMTP.Addr == "" || c.Notify.SMTP.From != "", "notify.smtp.from is required when notify.smtp.addr is set")
+	if c.Notify.SMTP.From != "" {
+		_, err := mail.ParseAddress(c.Notify.SMTP.From)
+		check(err == nil, "notify.smtp.from %q is not an email address", c.Notify.SMTP.From)
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "server.trusted_proxies: %q is not an IP or CIDR", proxy)
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level %q must be one of debug, info, warn, error", c.Log.Level))
+	}
+
+	if c.JWT.Configured() {
+		for i, kc := range append([]KeyConfig{c.JWT.Current}, c.JWT.Previous...) {
+			if err := kc.validate(i == 0); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	} else if !c.JWT.AllowInsecureDevKey {
+		errs = append(errs, errors.New("jwt.current is required; set jwt.allow_insecure_dev_key to run with the development key"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("LMS_JWT_ALLOW_INSECURE_DEV_KEY", "true")
+	cfg, err := Load("")
+	assert.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.Server.ListenAddr)
+	assert.Equal(t, 14, cfg.Circulation.LoanPeriodDays)
+	assert.Equal(t, 3, cfg.Circulation.HoldPickupDays)
+	assert.Equal(t, 2, cfg.Circulation.MaxRenewals)
+	assert.True(t, cfg.Scheduler.Enabled)
+	assert.False(t, cfg.JWT.Configured())
+	assert.True(t, cfg.JWT.AllowInsecureDevKey)
+}
+
+func TestLoadFileWithEnvOverrides(t *testing.T) {
+	t.Run("YAML", func(t *testing.T) {
+		path := writeConfigFile(t, "lms.yaml", `
+server:
+  listen_addr: ":9090"
+  cors_origins: ["https://lms.example.com"]
+database:
+  dsn: "host=db"
+circulation:
+  loan_period_days: 21
+`)
+		t.Setenv("LMS_DATABASE_DSN", "host=override")
+		t.Setenv("LMS_JWT_ALLOW_INSECURE_DEV_KEY", "1")
+
+		cfg, err := Load(path)
+		assert.NoError(t, err)
+		assert.Equal(t, ":9090", cfg.Server.ListenAddr)
+		assert.Equal(t, []string{"https://lms.example.com"}, cfg.Server.CORSOrigins)
+		assert.Equal(t, "host=override", cfg.Database.DSN)
+		assert.Equal(t, 21, cfg.Circulation.LoanPeriodDays)
+	})
+
+	t.Run("TOML", func(t *testing.T) {
+		path := writeConfigFile(t, "lms.toml", `
+[server]
+listen_addr = ":7070"
+
+[jwt.current]
+id = "k1"
+secret = "s3cret"
+`)
+		t.Setenv("LMS_CORS_ORIGINS", "https://a.example.com, https://b.example.com")
+		t.Setenv("LMS_TRUSTED_PROXIES", "10.0.0.1,192.168.0.0/16")
+
+		cfg, err := Load(path)
+		assert.NoError(t, err)
+		assert.Equal(t, ":7070", cfg.Server.ListenAddr)
+		assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.Server.CORSOrigins)
+		assert.Equal(t, []string{"10.0.0.1", "192.168.0.0/16"}, cfg.Server.TrustedProxies)
+		assert.True(t, cfg.JWT.Configured())
+
+		_, err = cfg.JWT.KeySet()
+		assert.NoError(t, err)
+	})
+}
+
+func TestLoadValidation(t *testing.T) {
+	t.Run("Unsupported file format", func(t *testing.T) {
+		_, err := Load(writeConfigFile(t, "lms.json", `{}`))
+		assert.ErrorContains(t, err, "unsupported format")
+	})
+
+	t.Run("Invalid values are all reported", func(t *testing.T) {
+		path := writeConfigFile(t, "lms.yaml", `
+database:
+  driver: "mysql"
+circulation:
+  loan_period_days: 0
+search:
+  similarity: 1.5
+log:
+  level: "verbose"
+server:
+  trusted_proxies: ["10.0.0.0/8", "proxy.local"]
+notify:
+  smtp:
+    from: "library"
+jwt:
+  current:
+    secret: "no-id"
+`)
+		_, err := Load(path)
+		assert.ErrorContains(t, err, `database.driver "mysql" must be postgres or sqlite`)
+		assert.ErrorContains(t, err, "circulation.loan_period_days must be positive")
+		assert.ErrorContains(t, err, "search.similarity must be greater than 0 and at most 1")
+		assert.ErrorContains(t, err, `log.level "verbose"`)
+		assert.ErrorContains(t, err, `server.trusted_proxies: "proxy.local" is not an IP or CIDR`)
+		assert.ErrorContains(t, err, `notify.smtp.from "library" is not an email address`)
+		assert.ErrorContains(t, err, "jwt.current: id is required")
+	})
+
+	t.Run("No JWT key", func(t *testing.T) {
+		_, err := Load("")
+		assert.ErrorContains(t, err, "jwt.current is required")
+
+		t.Setenv("LMS_JWT_ALLOW_INSECURE_DEV_KEY", "yes")
+		_, err = Load("")
+		assert.ErrorContains(t, err, "LMS_JWT_ALLOW_INSECURE_DEV_KEY")
+	})
+
+	t.Run("Non-numeric environment override", func(t *testing.T) {
+		t.Setenv("LMS_LOAN_PERIOD_DAYS", "two weeks")
+		_, err := Load("")
+		assert.ErrorContains(t, err, "LMS_LOAN_PERIOD_DAYS")
+	})
+}
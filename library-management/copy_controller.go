@@ -0,0 +1,151 @@
+// 🏷️ Copy Inventory
+package controllers
+
+import (
+	"errors"
+	"library-management/audit"
+	"library-management/circulation"
+	"library-management/pagination"
+	"library-management/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AddCopy adds one barcoded copy of a book - Only Admin
+func AddCopy(books services.BookService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		isbn := c.Param("isbn")
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		var input struct {
+			LibraryID uint `json:"library_id" binding:"required"`
+			circulation.CopyInput
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if member, err := libraries.IsMember(c.Request.Context(), userID.(uint), input.LibraryID); err != nil || !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned as an admin for this library"})
+			return
+		}
+
+		added, err := books.AddCopy(audit.Context(c), isbn, input.LibraryID, input.CopyInput)
+		if errors.Is(err, circulation.ErrBookNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in the specified library"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add copy"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Copy added successfully", "copy": added})
+	}
+}
+
+// ListCopies pages through the copies of a book held by a library - Only Admin
+func ListCopies(books services.BookService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		isbn := c.Param("isbn")
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		if c.Query("library_id") == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Library ID is required"})
+			return
+		}
+		libraryID, err := strconv.ParseUint(c.Query("library_id"), 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+			return
+		}
+
+		req, err := pagination.Parse(c, services.CopySort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if member, err := libraries.IsMember(c.Request.Context(), userID.(uint), uint(libraryID)); err != nil || !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned as an admin for this library"})
+			return
+		}
+
+		book, copies, page, err := books.ListCopies(c.Request.Context(), isbn, uint(libraryID), req)
+		if errors.Is(err, circulation.ErrBookNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in the specified library"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch copies"})
+			return
+		}
+
+		reply := pagination.Envelope(copies, page)
+		reply["book"] = book
+		c.JSON(http.StatusOK, reply)
+	}
+}
+
+// UpdateCopy changes a copy's condition, shelf location or status - Only Admin
+func UpdateCopy(books services.BookService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		barcode := c.Param("barcode")
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		var input struct {
+			Condition     *string `json:"condition"`
+			ShelfLocation *string `json:"shelf_location"`
+			Status        string  `json:"status" binding:"omitempty,oneof=available damaged lost withdrawn"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		_, book, err := books.GetCopy(c.Request.Context(), barcode)
+		if errors.Is(err, circulation.ErrBookNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Copy not found"})
+			return
+		}
+
+		if member, err := libraries.IsMember(c.Request.Context(), userID.(uint), book.LibraryID); err != nil || !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned as an admin for this library"})
+			return
+		}
+
+		bookCopy, book, err := books.UpdateCopy(audit.Context(c), barcode, services.CopyUpdate(input))
+		if errors.Is(err, circulation.ErrCopyUnavailable) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Copy is on loan or set aside for a hold"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update copy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Copy updated successfully", "copy": bookCopy, "book": book})
+	}
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"bytes"
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/services"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestAddCopy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/book/:isbn/copies", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		AddCopy(services.NewBookService(gormDB, circulation.NewService(gormDB, config.Default().Circulation), config.Default().Search), services.NewLibraryService(gormDB))(c)
+	})
+
+	adminQuery := regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)
+
+	t.Run("Adds a copy with the scanned barcode", func(t *testing.T) {
+		mock.ExpectQuery(adminQuery).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL ORDER BY "books"."id" LIMIT $3 FOR UPDATE`)).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "book_copies" WHERE book_id = $1`)).
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "book_copies"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 3, "SHELF-42", "good", "A-3", "available", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "holds" WHERE (isbn = $1 AND library_id = $2 AND status = $3)`)).
+			WithArgs("123456789", 1, "waiting").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "total_copies","available_copies" FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"total_copies", "available_copies"}).AddRow(3, 3))
+		expectAudit(mock, "copy.create", "copy", 12)
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPost, "/book/123456789/copies", bytes.NewBufferString(`{"library_id":1,"barcode":"SHELF-42","shelf_location":"A-3"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"barcode":"SHELF-42"`)
+	})
+
+	t.Run("Library not managed by admin", func(t *testing.T) {
+		mock.ExpectQuery(adminQuery).
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		req := httptest.NewRequest(http.MethodPost, "/book/123456789/copies", bytes.NewBufferString(`{"library_id":2}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateCopy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/copy/:barcode", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		UpdateCopy(services.NewBookService(gormDB, circulation.NewService(gormDB, config.Default().Circulation), config.Default().Search), services.NewLibraryService(gormDB))(c)
+	})
+
+	t.Run("Copy on loan cannot be marked lost", func(t *testing.T) {
+		expectCopy := func() {
+			mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_copies" WHERE barcode = $1`)).
+				WithArgs("000003-001", 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "barcode", "status"}).AddRow(11, 3, "000003-001", "issued"))
+			mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."id" = $1`)).
+				WithArgs(3, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+		}
+		expectCopy()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		expectCopy()
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."id" = $1`)).
+			WithArgs(3, 3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_copies" WHERE "book_copies"."id" = $1`)).
+			WithArgs(11, 11, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "barcode", "status"}).AddRow(11, 3, "000003-001", "issued"))
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest(http.MethodPut, "/copy/000003-001", bytes.NewBufferString(`{"status":"lost"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "Copy is on loan")
+	})
+
+	t.Run("Issued is not a settable status", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/copy/000003-001", bytes.NewBufferString(`{"status":"issued"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,420 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"library-management/models"
+	"library-management/routes"
+	"library-management/scheduler"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// record is the part of a row in a response body the scenarios follow
+type record struct {
+	ID uint `json:"ID"`
+}
+
+func TestIssueReturnFine(t *testing.T) {
+	h := New(t)
+	library := h.Library()
+	admin := h.Admin(library)
+	reader := h.Reader(library)
+	book := h.Book(library, "The Go Programming Language", 1)
+	loanBody := map[string]interface{}{"isbn": book.ISBN, "libraryid": library.ID}
+
+	// The reader asks for the book and the admin lends it
+	var request struct{ Request record }
+	reader.Post("/api/issue", loanBody).Expect(http.StatusCreated).Decode(&request)
+
+	var loan struct{ Issue record }
+	admin.Put(fmt.Sprintf("/api/issue/approve/%d", request.Request.ID), map[string]string{}).
+		Expect(http.StatusOK).Decode(&loan)
+
+	var loans struct {
+		Loans []struct {
+			ID      uint `json:"id"`
+			Overdue bool `json:"overdue"`
+		} `json:"data"`
+	}
+	reader.Get("/api/me/loans?status=current").Expect(http.StatusOK).Decode(&loans)
+	if assert.Len(t, loans.Loans, 1) {
+		assert.Equal(t, loan.Issue.ID, loans.Loans[0].ID)
+		assert.False(t, loans.Loans[0].Overdue)
+	}
+
+	// The only copy is out, so a second reader cannot ask for it
+	h.Reader(library).Post("/api/issue", loanBody).Expect(http.StatusBadRequest)
+
+	// Five days and an hour late: six started days, less the grace day
+	h.Backdate(loan.Issue.ID, 5)
+	var ret struct{ Request record }
+	reader.Post("/api/return", loanBody).Expect(http.StatusCreated).Decode(&ret)
+
+	var returned struct {
+		Fine struct {
+			DaysOverdue int   `json:"days_overdue"`
+			AmountCents int64 `json:"amount_cents"`
+		} `json:"fine"`
+	}
+	admin.Put(fmt.Sprintf("/api/return/approve/%d", ret.Request.ID), map[string]string{"condition": "good"}).
+		Expect(http.StatusOK).Decode(&returned)
+	wantDays := 6 - h.Config.Circulation.FineGraceDays
+	assert.Equal(t, wantDays, returned.Fine.DaysOverdue)
+	assert.Equal(t, int64(wantDays*h.Config.Circulation.FinePerDayCents), returned.Fine.AmountCents)
+
+	// The fine shows on the reader's balance until they pay it
+	var fines struct {
+		Balances []struct {
+			LibraryID    uint  `json:"library_id"`
+			BalanceCents int64 `json:"balance_cents"`
+		} `json:"balances"`
+	}
+	reader.Get("/api/fines").Expect(http.StatusOK).Decode(&fines)
+	if assert.Len(t, fines.Balances, 1) {
+		assert.Equal(t, returned.Fine.AmountCents, fines.Balances[0].BalanceCents)
+	}
+
+	admin.Post(fmt.Sprintf("/api/readers/%d/payments", reader.User.ID), map[string]interface{}{
+		"library_id":   library.ID,
+		"amount_cents": returned.Fine.AmountCents,
+	}).Expect(http.StatusCreated)
+
+	reader.Get("/api/fines").Expect(http.StatusOK).Decode(&fines)
+	if assert.Len(t, fines.Balances, 1) {
+		assert.Zero(t, fines.Balances[0].BalanceCents)
+	}
+	reader.Get("/api/me/loans?status=past").Expect(http.StatusOK).Decode(&loans)
+	assert.Len(t, loans.Loans, 1)
+}
+
+func TestIssueRequestsByLibrary(t *testing.T) {
+	h := New(t)
+	library, branch, other := h.Library(), h.Library(), h.Library()
+	admin := h.Admin(library, branch)
+	reader := h.Reader(library, other)
+
+	// The same title is held by all three libraries
+	book := h.Book(library, "Middlemarch", 1)
+	for _, l := range []uint{branch.ID, other.ID} {
+		copy := book
+		copy.ID, copy.LibraryID = 0, l
+		if err := h.DB.Omit("CreatedAt", "UpdatedAt", "DeletedAt").Create(&copy).Error; err != nil {
+			t.Fatalf("add copy: %v", err)
+		}
+	}
+	for _, l := range []uint{library.ID, other.ID} {
+		reader.Post("/api/issue", map[string]interface{}{"isbn": book.ISBN, "libraryid": l}).Expect(http.StatusCreated)
+	}
+
+	// Only the request made in the admin's library is listed, once
+	var requests struct {
+		Data []struct {
+			ID uint `json:"id"`
+		} `json:"data"`
+		Page struct {
+			Total int64 `json:"total"`
+		} `json:"page"`
+	}
+	admin.Get("/api/issues?total=true").Expect(http.StatusOK).Decode(&requests)
+	assert.Len(t, requests.Data, 1)
+	assert.Equal(t, int64(1), requests.Page.Total)
+}
+
+func TestSearchAvailability(t *testing.T) {
+	h := New(t)
+	library := h.Library()
+	admin := h.Admin(library)
+	reader := h.Reader(library)
+	book := h.Book(library, "Persuasion", 1)
+	loanBody := map[string]interface{}{"isbn": book.ISBN, "libraryid": library.ID}
+
+	var request struct{ Request record }
+	reader.Post("/api/issue", loanBody).Expect(http.StatusCreated).Decode(&request)
+	var loan struct {
+		Issue struct {
+			ExpectedReturnDate int64 `json:"expected_return_date"`
+		}
+	}
+	admin.Put(fmt.Sprintf("/api/issue/approve/%d", request.Request.ID), map[string]string{}).
+		Expect(http.StatusOK).Decode(&loan)
+	h.Reader(library).Post("/api/holds", loanBody).Expect(http.StatusCreated)
+
+	// Another reader learns when the copy is due back and who is queued for it
+	var found struct {
+		Data []struct {
+			NextAvailable string `json:"next_available_date"`
+			HoldsWaiting  int64  `json:"holds_waiting"`
+		} `json:"data"`
+	}
+	h.Reader(library).Get("/api/books/search?title=Persuasion").Expect(http.StatusOK).Decode(&found)
+	if assert.Len(t, found.Data, 1) {
+		assert.Equal(t, time.Unix(loan.Issue.ExpectedReturnDate, 0).Format("2006-01-02 15:04:05"), found.Data[0].NextAvailable)
+		assert.Equal(t, int64(1), found.Data[0].HoldsWaiting)
+	}
+}
+
+func TestRoleGroups(t *testing.T) {
+	h := New(t)
+	library := h.Library()
+	other := h.Library()
+	admin := h.Admin(library)
+	reader := h.Reader(library)
+	book := h.Book(library, "Refactoring", 2)
+
+	var request struct{ Request record }
+	reader.Post("/api/issue", map[string]interface{}{"isbn": book.ISBN, "libraryid": library.ID}).
+		Expect(http.StatusCreated).Decode(&request)
+	approve := fmt.Sprintf("/api/issue/approve/%d", request.Request.ID)
+
+	tests := []struct {
+		name   string
+		client *Client
+		method string
+		path   string
+		body   interface{}
+		code   int
+	}{
+		{"Health is public", h.Anonymous(), http.MethodGet, "/api/health", nil, http.StatusOK},
+		{"Library list is public", h.Anonymous(), http.MethodGet, "/libraries", nil, http.StatusOK},
+		{"No token", h.Anonymous(), http.MethodGet, "/api/me/loans", nil, http.StatusUnauthorized},
+		{"Reader cannot add books", reader, http.MethodPost, "/api/book", map[string]interface{}{"isbn": "1", "title": "T", "total_copies": 1, "libraryid": library.ID}, http.StatusForbidden},
+		{"Admin cannot search as a reader", admin, http.MethodGet, "/api/books/search?title=refactoring", nil, http.StatusForbidden},
+		{"Admin cannot create libraries", admin, http.MethodPost, "/api/library", map[string]string{"name": "Annex"}, http.StatusForbidden},
+		{"Owner creates libraries", h.Owner(), http.MethodPost, "/api/library", map[string]string{"name": "Annex"}, http.StatusCreated},
+		{"Reader searches their library", reader, http.MethodGet, "/api/books/search?title=refactoring", nil, http.StatusOK},
+		{"Reader cannot approve", reader, http.MethodPut, approve, map[string]string{}, http.StatusForbidden},
+		{"Admin of another library cannot approve", h.Admin(other), http.MethodPut, approve, map[string]string{}, http.StatusForbidden},
+		{"Admin approves in their library", admin, http.MethodPut, approve, map[string]string{}, http.StatusOK},
+		{"Every role sees its own data", admin, http.MethodGet, "/api/me/requests", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.client.Do(tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.code, res.Code, "%s", res.Body)
+		})
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	h := New(t)
+	library := h.Library()
+	reader := h.Reader(library)
+	// Every harness book is published by Harness Press
+	compilers := h.Book(library, "Compilers", 1)
+	harness := h.Book(library, "Harness the Wind", 1)
+	h.Book(h.Library(), "Harnessing Go", 1)
+
+	var found struct {
+		Books []struct {
+			ISBN     string  `json:"isbn"`
+			Rank     float64 `json:"rank"`
+			Headline string  `json:"headline"`
+		} `json:"data"`
+	}
+	reader.Get("/api/books/search?q=harness").Expect(http.StatusOK).Decode(&found)
+	if assert.Len(t, found.Books, 2) {
+		assert.Equal(t, harness.ISBN, found.Books[0].ISBN)
+		assert.Greater(t, found.Books[0].Rank, found.Books[1].Rank)
+		assert.Equal(t, "Compilers / "+compilers.Authors+" / <b>Harness</b> Press", found.Books[1].Headline)
+	}
+
+	reader.Get(`/api/books/search?q="the+wind"+auth*`).Expect(http.StatusOK).Decode(&found)
+	if assert.Len(t, found.Books, 1) {
+		assert.Equal(t, harness.ISBN, found.Books[0].ISBN)
+	}
+}
+
+func TestTypoTolerance(t *testing.T) {
+	h := New(t)
+	library := h.Library()
+	reader := h.Reader(library)
+	book := h.Book(library, "The Hobbit", 1)
+	if err := h.DB.Model(&book).Update("authors", "J.R.R. Tolkien").Error; err != nil {
+		t.Fatalf("set authors: %v", err)
+	}
+
+	type suggestion struct {
+		Text string `json:"text"`
+		Kind string `json:"kind"`
+	}
+	var found struct {
+		Books []struct {
+			ISBN string `json:"isbn"`
+		} `json:"data"`
+		DidYouMean []suggestion `json:"did_you_mean"`
+	}
+	reader.Get("/api/books/search?author=Tolkein").Expect(http.StatusOK).Decode(&found)
+	assert.Empty(t, found.Books)
+	assert.Equal(t, []suggestion{{"J.R.R. Tolkien", "author"}}, found.DidYouMean)
+
+	found.DidYouMean = nil
+	reader.Get("/api/books/search?q=Tolkein&fuzzy=true").Expect(http.StatusOK).Decode(&found)
+	if assert.Len(t, found.Books, 1) {
+		assert.Equal(t, book.ISBN, found.Books[0].ISBN)
+	}
+	assert.Nil(t, found.DidYouMean)
+
+	var completed struct {
+		Suggestions []suggestion `json:"suggestions"`
+	}
+	reader.Get("/api/books/suggest?prefix=hob").Expect(http.StatusOK).Decode(&completed)
+	assert.Equal(t, []suggestion{{"The Hobbit", "title"}}, completed.Suggestions)
+}
+
+func TestPaging(t *testing.T) {
+	h := New(t)
+	library := h.Library()
+	reader := h.Reader(library)
+	var want []string
+	for _, title := range []string{"Emma", "Beloved", "Dune", "Atonement", "Carrie"} {
+		h.Book(library, title, 1)
+		want = append(want, title)
+	}
+	sort.Strings(want)
+
+	type page struct {
+		Data []struct {
+			Title string `json:"title"`
+		} `json:"data"`
+		Page struct {
+			NextCursor string `json:"next_cursor"`
+			Total      *int64 `json:"total"`
+		} `json:"page"`
+	}
+
+	// Following the cursors visits every book once, in title order
+	var titles []string
+	path := "/api/books/search?limit=2&total=true"
+	for pages := 0; path != "" && pages < 5; pages++ {
+		var p page
+		reader.Get(path).Expect(http.StatusOK).Decode(&p)
+		if assert.NotNil(t, p.Page.Total) {
+			assert.Equal(t, int64(5), *p.Page.Total)
+		}
+		for _, book := range p.Data {
+			titles = append(titles, book.Title)
+		}
+		path = ""
+		if p.Page.NextCursor != "" {
+			path = "/api/books/search?limit=2&total=true&cursor=" + p.Page.NextCursor
+		}
+	}
+	assert.Equal(t, want, titles)
+
+	// Ranked results page in memory on SQLite, and offsets skip rows
+	var p page
+	reader.Get("/api/books/search?q=harness&sort=-title&limit=2&offset=1").Expect(http.StatusOK).Decode(&p)
+	if assert.Len(t, p.Data, 2) {
+		assert.Equal(t, want[3], p.Data[0].Title)
+		assert.Equal(t, want[2], p.Data[1].Title)
+	}
+	assert.NotEmpty(t, p.Page.NextCursor)
+
+	reader.Get("/api/books/search?limit=500").Expect(http.StatusBadRequest)
+	reader.Get("/api/books/search?sort=rank").Expect(http.StatusBadRequest)
+	reader.Get("/api/books/search?cursor=bogus").Expect(http.StatusBadRequest)
+}
+
+func TestLoginAndLogout(t *testing.T) {
+	h := New(t)
+	reader := h.Reader(h.Library())
+
+	h.Anonymous().Post("/auth/login", map[string]string{"email": reader.User.Email, "password": "wrong"}).
+		Expect(http.StatusUnauthorized)
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	h.Anonymous().Post("/auth/login", map[string]string{"email": reader.User.Email, "password": Password}).
+		Expect(http.StatusOK).Decode(&login)
+
+	session := &Client{h: h, User: reader.User, Token: login.Token}
+	session.Get("/api/me/loans").Expect(http.StatusOK)
+	session.Post("/auth/logout", nil).Expect(http.StatusOK)
+	session.Get("/api/me/loans").Expect(http.StatusUnauthorized)
+
+	// Other sessions of the same user are unaffected
+	reader.Get("/api/me/loans").Expect(http.StatusOK)
+}
+
+func TestAuditedClientIP(t *testing.T) {
+	h := New(t)
+	owner := h.Owner()
+
+	createLibrary := func(name string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/library", strings.NewReader(`{"name": "`+name+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+owner.Token)
+		req.Header.Set("X-Forwarded-For", "203.0.113.9")
+		req.RemoteAddr = "10.0.0.2:4000"
+		w := httptest.NewRecorder()
+		h.Router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code, "%s", w.Body)
+	}
+	clientIP := func() string {
+		var events struct {
+			Data []struct {
+				ClientIP string `json:"client_ip"`
+			} `json:"data"`
+		}
+		owner.Get("/api/audit?entity_type=library&limit=1").Expect(http.StatusOK).Decode(&events)
+		assert.Len(t, events.Data, 1)
+		return events.Data[0].ClientIP
+	}
+
+	// By default no proxy is trusted, so the header cannot forge the address
+	createLibrary("Direct")
+	assert.Equal(t, "10.0.0.2", clientIP())
+
+	h.Config.Server.TrustedProxies = []string{"10.0.0.0/8"}
+	h.Router = routes.SetupRouter(h.Config, h.DB)
+	createLibrary("Proxied")
+	assert.Equal(t, "203.0.113.9", clientIP())
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	h := New(t)
+	library := h.Library()
+	owner := h.Owner()
+	admin := h.Admin(library)
+	bystander := h.Admin(library)
+
+	var login struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	h.Anonymous().Post("/auth/login", map[string]string{"email": admin.User.Email, "password": Password}).
+		Expect(http.StatusOK).Decode(&login)
+	session := &Client{h: h, User: admin.User, Token: login.Token}
+	session.Get("/api/me/requests").Expect(http.StatusOK)
+
+	admin.Post(fmt.Sprintf("/api/users/%d/revoke-tokens", bystander.User.ID), nil).Expect(http.StatusForbidden)
+	owner.Post("/api/users/999/revoke-tokens", nil).Expect(http.StatusNotFound)
+	owner.Post(fmt.Sprintf("/api/users/%d/revoke-tokens", admin.User.ID), nil).Expect(http.StatusOK)
+
+	// Every session of the user is closed, other users keep theirs
+	session.Get("/api/me/requests").Expect(http.StatusUnauthorized)
+	admin.Get("/api/me/requests").Expect(http.StatusUnauthorized)
+	h.Anonymous().Post("/auth/refresh", map[string]string{"refresh_token": login.RefreshToken}).Expect(http.StatusUnauthorized)
+	bystander.Get("/api/me/requests").Expect(http.StatusOK)
+
+	// Revoking again only moves the cutoff
+	owner.Post(fmt.Sprintf("/api/users/%d/revoke-tokens", admin.User.ID), nil).Expect(http.StatusOK)
+
+	// The row goes once every token it blocks has expired
+	job := scheduler.TokenJobs(h.DB, time.Minute)[0]
+	purged, err := job.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+	assert.NoError(t, h.DB.Model(&models.RevokedToken{}).Where("user_id = ?", admin.User.ID).Update("expires_at", time.Now().Add(-time.Minute).Unix()).Error)
+	purged, err = job.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+}
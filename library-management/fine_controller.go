@@ -0,0 +1,147 @@
+// 💰 Fines and Payments
+package controllers
+
+import (
+	"library-management/audit"
+	"library-management/pagination"
+	"library-management/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListFines shows the signed-in reader's balance with each library and a page of their fines
+func ListFines(circ services.CirculationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		req, err := pagination.Parse(c, services.FineSort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		balances, err := circ.Balances(c.Request.Context(), userID.(uint))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch balance"})
+			return
+		}
+
+		fines, page, err := circ.ListFines(c.Request.Context(), userID.(uint), req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch fines"})
+			return
+		}
+
+		formattedFines := make([]gin.H, len(fines))
+		for i, fine := range fines {
+			formattedFines[i] = gin.H{
+				"id":            fine.ID,
+				"issue_id":      fine.IssueID,
+				"library_id":    fine.LibraryID,
+				"days_overdue":  fine.DaysOverdue,
+				"amount_cents":  fine.AmountCents,
+				"status":        fine.Status,
+				"assessed_at":   formatUnixTime(&fine.AssessedAt),
+				"waived_reason": fine.WaivedReason,
+			}
+		}
+		reply := pagination.Envelope(formattedFines, page)
+		reply["balances"] = balances
+		c.JSON(http.StatusOK, reply)
+	}
+}
+
+// RecordPayment lets an admin credit a reader's payment towards their balance with the library
+func RecordPayment(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		readerID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reader ID"})
+			return
+		}
+
+		var input struct {
+			LibraryID   uint   `json:"library_id" binding:"required"`
+			AmountCents int64  `json:"amount_cents" binding:"required,gt=0"`
+			Note        string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if member, err := libraries.IsMember(c.Request.Context(), adminID.(uint), input.LibraryID); err != nil || !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only record payments for your assigned library"})
+			return
+		}
+
+		entry, err := circ.RecordPayment(audit.Context(c), uint(readerID), input.LibraryID, input.AmountCents, input.Note, adminID.(uint))
+		if err != nil {
+			respondCirculationError(c, err, "Could not record payment")
+			return
+		}
+
+		balance, err := circ.Balance(c.Request.Context(), uint(readerID), input.LibraryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch balance"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Payment recorded", "entry": entry, "balance_cents": balance})
+	}
+}
+
+// WaiveFine lets an admin cancel a fine, giving a reason
+func WaiveFine(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		var input struct {
+			Reason string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		fineID, err := strconv.ParseUint(c.Param("id"), 10, 0)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Fine not found"})
+			return
+		}
+		fine, err := circ.GetFine(c.Request.Context(), uint(fineID))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Fine not found"})
+			return
+		}
+
+		if member, err := libraries.IsMember(c.Request.Context(), adminID.(uint), fine.LibraryID); err != nil || !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only waive fines for your assigned library"})
+			return
+		}
+
+		waived, err := circ.WaiveFine(audit.Context(c), fine.ID, adminID.(uint), input.Reason)
+		if err != nil {
+			respondCirculationError(c, err, "Could not waive fine")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Fine waived", "fine": waived})
+	}
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"bytes"
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/services"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestListFines(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/fines", func(c *gin.Context) {
+		c.Set("userID", uint(2))
+		ListFines(services.NewCirculationService(gormDB, nil, nil))(c)
+	})
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT library_id, SUM(amount_cents) AS balance_cents FROM "ledger_entries" WHERE reader_id = $1 AND "ledger_entries"."deleted_at" IS NULL GROUP BY "library_id"`)).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"library_id", "balance_cents"}).AddRow(1, 150))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "fines" WHERE reader_id = $1 AND "fines"."deleted_at" IS NULL ORDER BY assessed_at DESC, id DESC LIMIT $2`)).
+		WithArgs(2, 21).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "issue_id", "library_id", "days_overdue", "amount_cents", "status", "assessed_at"}).
+			AddRow(3, 7, 1, 6, 150, "charged", 1767268800))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fines", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"balance_cents":150`)
+	assert.Contains(t, w.Body.String(), `"days_overdue":6`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWaiveFine(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/fines/:id/waive", func(c *gin.Context) {
+		c.Set("userID", uint(9))
+		WaiveFine(services.NewCirculationService(gormDB, circulation.NewService(gormDB, config.Default().Circulation), nil), services.NewLibraryService(gormDB))(c)
+	})
+
+	fineQuery := regexp.QuoteMeta(`SELECT * FROM "fines" WHERE "fines"."id" = $1`)
+	fineRows := func(status string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "issue_id", "reader_id", "library_id", "amount_cents", "status"}).AddRow(3, 7, 2, 1, 150, status)
+	}
+	waive := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/fines/3/waive", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Reason is required", func(t *testing.T) {
+		w := waive(`{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Waiver credits what is still owed", func(t *testing.T) {
+		mock.ExpectQuery(fineQuery).WithArgs(3, 1).WillReturnRows(fineRows("charged"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(9, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectQuery(fineQuery).WithArgs(3, 1).WillReturnRows(fineRows("charged"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id" FROM "users" WHERE "users"."id" = $1`)).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery(fineQuery).WithArgs(3, 3, 1).WillReturnRows(fineRows("charged"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(amount_cents), 0) FROM "ledger_entries"`)).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(100))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "ledger_entries"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, 1, 3, "waiver", -100, "Book was in the returns bin", 9, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "fines" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, "fine.waive", "fine", 3)
+		mock.ExpectCommit()
+
+		w := waive(`{"reason": "Book was in the returns bin"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"waived"`)
+	})
+
+	t.Run("Already waived", func(t *testing.T) {
+		mock.ExpectQuery(fineQuery).WithArgs(3, 1).WillReturnRows(fineRows("waived"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(9, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectQuery(fineQuery).WithArgs(3, 1).WillReturnRows(fineRows("waived"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id" FROM "users"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery(fineQuery).WithArgs(3, 3, 1).WillReturnRows(fineRows("waived"))
+		mock.ExpectRollback()
+
+		w := waive(`{"reason": "duplicate"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,137 @@
+// 📌 Holds
+package controllers
+
+import (
+	"errors"
+	"library-management/audit"
+	"library-management/circulation"
+	"library-management/models"
+	"library-management/pagination"
+	"library-management/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlaceHold lets users join the queue for a title with no copy on the shelf
+func PlaceHold(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			BookID    string `json:"isbn" binding:"required"`
+			LibraryID uint   `json:"libraryid" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		if member, err := libraries.IsMember(c.Request.Context(), userID.(uint), input.LibraryID); err != nil || !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only place holds in libraries you are registered in"})
+			return
+		}
+
+		hold, err := circ.PlaceHold(audit.Context(c), userID.(uint), input.BookID, input.LibraryID)
+		switch {
+		case errors.Is(err, circulation.ErrBookNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in the specified library"})
+			return
+		case errors.Is(err, circulation.ErrCopiesAvailable):
+			c.JSON(http.StatusConflict, gin.H{"error": "Copies are available; request an issue instead"})
+			return
+		case errors.Is(err, circulation.ErrHoldExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "You already have an active hold on this book"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not place hold"})
+			return
+		}
+
+		position, err := circ.QueuePosition(c.Request.Context(), hold)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch queue position"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Hold placed", "hold": hold, "position": position})
+	}
+}
+
+// ListHolds pages through the user's holds, newest first, with queue positions for waiting ones
+func ListHolds(circ services.CirculationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		req, err := pagination.Parse(c, services.HoldSort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		holds, page, err := circ.ListHolds(c.Request.Context(), userID.(uint), req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch holds"})
+			return
+		}
+
+		formattedHolds := make([]gin.H, len(holds))
+		for i, hold := range holds {
+			formattedHolds[i] = gin.H{
+				"id":         hold.ID,
+				"isbn":       hold.ISBN,
+				"library_id": hold.LibraryID,
+				"status":     hold.Status,
+				"placed_at":  formatUnixTime(&hold.PlacedAt),
+				"pickup_by":  formatUnixTime(hold.PickupBy),
+			}
+			if hold.Status == models.HoldWaiting {
+				formattedHolds[i]["position"] = hold.Position
+			}
+		}
+
+		c.JSON(http.StatusOK, pagination.Envelope(formattedHolds, page))
+	}
+}
+
+// CancelHold lets users withdraw one of their active holds
+func CancelHold(circ services.CirculationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		holdID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hold ID"})
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		_, err = circ.CancelHold(audit.Context(c), uint(holdID), userID.(uint))
+		switch {
+		case errors.Is(err, circulation.ErrHoldNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hold not found"})
+			return
+		case errors.Is(err, circulation.ErrHoldClosed):
+			c.JSON(http.StatusConflict, gin.H{"error": "Hold is no longer active"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not cancel hold"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Hold cancelled"})
+	}
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/models"
+	"library-management/services"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestPlaceHold(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/holds", func(c *gin.Context) {
+		c.Set("userID", uint(2))
+		PlaceHold(services.NewCirculationService(gormDB, circulation.NewService(gormDB, config.Default().Circulation), nil), services.NewLibraryService(gormDB))(c)
+	})
+
+	registered := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	}
+	bookQuery := regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2)`)
+
+	tests := []struct {
+		name           string
+		input          string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:  "Hold placed with queue position",
+			input: `{"isbn": "123456789", "libraryid": 1}`,
+			mockSetup: func() {
+				registered()
+				mock.ExpectBegin()
+				mock.ExpectQuery(bookQuery).
+					WithArgs("123456789", 1, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "available_copies"}).AddRow(3, "123456789", 1, 0))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "holds"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "holds"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+				expectAudit(mock, "hold.place", "hold", 4)
+				mock.ExpectCommit()
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "holds" WHERE (isbn = $1 AND library_id = $2 AND status = $3) AND (placed_at < $4 OR (placed_at = $5 AND id < $6))`)).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `"position":3`,
+		},
+		{
+			name:  "Copies available",
+			input: `{"isbn": "123456789", "libraryid": 1}`,
+			mockSetup: func() {
+				registered()
+				mock.ExpectBegin()
+				mock.ExpectQuery(bookQuery).
+					WithArgs("123456789", 1, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "available_copies"}).AddRow(3, "123456789", 1, 1))
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "request an issue instead",
+		},
+		{
+			name:  "Not registered in library",
+			input: `{"isbn": "123456789", "libraryid": 1}`,
+			mockSetup: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+					WithArgs(2, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   "registered in",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodPost, "/holds", bytes.NewBufferString(tt.input))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListHolds(t *testing.T) {
+	store, libID, adminID, readerID := newStore(t)
+	now := time.Now().Unix()
+	pickupBy := now + 3600
+	assert.NoError(t, store.db.Create([]models.Hold{
+		{ISBN: "111", LibraryID: libID, ReaderID: adminID, Status: "waiting", PlacedAt: now - 120},
+		{ISBN: "111", LibraryID: libID, ReaderID: readerID, Status: "waiting", PlacedAt: now - 60},
+		{ISBN: "222", LibraryID: libID, ReaderID: readerID, Status: "waiting", PlacedAt: now - 30},
+		{ISBN: "333", LibraryID: libID, ReaderID: readerID, Status: "ready", PlacedAt: now, PickupBy: &pickupBy},
+	}).Error)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/holds", asUser(ListHolds(store)))
+
+	w := serve(r, http.MethodGet, "/holds", readerID, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data []struct {
+			ISBN     string `json:"isbn"`
+			Status   string `json:"status"`
+			Position *int64 `json:"position"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	if assert.Len(t, body.Data, 3) {
+		// Newest first; each title has its own queue
+		assert.Equal(t, "ready", body.Data[0].Status)
+		assert.Nil(t, body.Data[0].Position)
+		assert.Equal(t, int64(1), *body.Data[1].Position)
+		assert.Equal(t, "111", body.Data[2].ISBN)
+		assert.Equal(t, int64(2), *body.Data[2].Position)
+	}
+}
+
+func TestCancelHold(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.DELETE("/holds/:id", func(c *gin.Context) {
+		c.Set("userID", uint(2))
+		CancelHold(services.NewCirculationService(gormDB, circulation.NewService(gormDB, config.Default().Circulation), nil))(c)
+	})
+
+	t.Run("Someone else's hold", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "holds" WHERE (id = $1 AND reader_id = $2)`)).
+			WithArgs(4, 2, 1).
+			WillReturnError(gorm.ErrRecordNotFound)
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest(http.MethodDelete, "/holds/4", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid hold ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/holds/abc", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,17 +2,22 @@
 package controllers
 
 import (
+	"errors"
+	"library-management/audit"
+	"library-management/circulation"
 	"library-management/models"
+	"library-management/pagination"
+	"library-management/services"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
-	"gorm.io/gorm"
-
 	"github.com/gin-gonic/gin"
 )
 
-// ListIssueRequests retrieves all issue requests for admin's libraries
-func ListIssueRequests(db *gorm.DB) gin.HandlerFunc {
+// ListIssueRequests retrieves a page of the issue requests for admin's libraries, optionally filtered by ?status=
+func ListIssueRequests(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		adminID, exists := c.Get("userID")
 		if !exists {
@@ -20,8 +25,14 @@ func ListIssueRequests(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var adminLibraryIDs []uint
-		if err := db.Table("user_libraries").Where("user_id = ?", adminID).Pluck("library_id", &adminLibraryIDs).Error; err != nil {
+		req, err := pagination.Parse(c, services.RequestSort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		adminLibraryIDs, err := libraries.LibraryIDs(c.Request.Context(), adminID.(uint))
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch admin libraries"})
 			return
 		}
@@ -31,10 +42,12 @@ func ListIssueRequests(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var requests []models.RequestEvent
-		if err := db.Joins("JOIN books ON request_events.book_id = books.isbn").
-			Where("books.library_id IN (?)", adminLibraryIDs).
-			Find(&requests).Error; err != nil {
+		requests, page, err := circ.ListRequests(c.Request.Context(), services.RequestFilter{
+			LibraryIDs: adminLibraryIDs,
+			Status:     c.Query("status"),
+			Page:       req,
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch issue requests"})
 			return
 		}
@@ -49,86 +62,106 @@ func ListIssueRequests(db *gorm.DB) gin.HandlerFunc {
 				"request_date":  formatUnixTime(&request.RequestDate),
 				"approval_date": formatUnixTime(request.ApprovalDate),
 				"approver_id":   request.ApproverID,
+				"status":        request.Status,
+			}
+			if request.Status == models.RequestRejected {
+				formattedRequests[i]["rejection_reason"] = request.RejectionReason
+				formattedRequests[i]["rejected_by_id"] = request.RejectedByID
 			}
 		}
-		c.JSON(http.StatusOK, gin.H{"requests": formattedRequests})
+		c.JSON(http.StatusOK, pagination.Envelope(formattedRequests, page))
 	}
 }
 
-// ApproveIssue allows an admin to approve a book issue request
-func ApproveIssue(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		requestID := c.Param("id")
-		var request models.RequestEvent
+// libraryRequest loads the request named by the :id parameter and checks the admin
+// serves its library, responding and returning nil when either fails
+func libraryRequest(c *gin.Context, circ services.CirculationService, libraries services.LibraryService, forbidden string) (*models.RequestEvent, uint) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Issue request not found"})
+		return nil, 0
+	}
 
-		if err := db.First(&request, requestID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Issue request not found"})
-			return
-		}
+	request, err := circ.GetRequest(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Issue request not found"})
+		return nil, 0
+	}
 
-		adminID, exists := c.Get("userID")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
-			return
-		}
+	adminID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+		return nil, 0
+	}
 
-		var book models.Book
-		if err := db.Where("isbn = ?", request.BookID).First(&book).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
-			return
-		}
+	if member, err := libraries.IsMember(c.Request.Context(), adminID.(uint), request.LibraryID); err != nil || !member {
+		c.JSON(http.StatusForbidden, gin.H{"error": forbidden})
+		return nil, 0
+	}
+	return request, adminID.(uint)
+}
 
-		var count int64
-		if err := db.Table("user_libraries").Where("user_id = ? AND library_id = ?", adminID, book.LibraryID).Count(&count).Error; err != nil || count == 0 {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You can only approve requests for books in your assigned library"})
+// ApproveIssue allows an admin to approve a book issue request and lend the book
+func ApproveIssue(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, adminID := libraryRequest(c, circ, libraries, "You can only approve requests for books in your assigned library")
+		if request == nil {
 			return
 		}
 
-		if request.ApprovalDate != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Request is already approved"})
-			return
+		// Admins may scan the copy they hand over; otherwise any available copy is used
+		var input struct {
+			Barcode string `json:"barcode"`
 		}
-
-		if book.AvailableCopies == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No available copies to issue"})
-			return
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+				return
+			}
 		}
 
-		now := time.Now().Unix()
-		request.ApprovalDate = &now
-		request.ApproverID = new(uint)
-		*request.ApproverID = adminID.(uint)
-
-		if err := db.Save(&request).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not approve request"})
+		issue, err := circ.ApproveIssue(audit.Context(c), request.ID, adminID, input.Barcode)
+		if err != nil {
+			respondCirculationError(c, err, "Could not approve request")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Issue request approved"})
+		c.JSON(http.StatusOK, gin.H{"message": "Issue request approved", "issue": issue})
 	}
 }
 
-// DisapproveIssue allows an admin to reject an issue request
-func DisapproveIssue(db *gorm.DB) gin.HandlerFunc {
+// DisapproveIssue allows an admin to reject an issue request with a reason; the reader is told why
+func DisapproveIssue(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requestID := c.Param("id")
-		var request models.RequestEvent
+		request, adminID := libraryRequest(c, circ, libraries, "You can only disapprove requests for books in your assigned library")
+		if request == nil {
+			return
+		}
 
-		if err := db.First(&request, requestID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Issue request not found"})
+		var input struct {
+			Reason string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Reason) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to disapprove a request"})
+			return
+		}
+		if request.RequestType != "issue" {
+			respondCirculationError(c, circulation.ErrNotIssueRequest, "Could not disapprove request")
 			return
 		}
 
-		if err := db.Delete(&request).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not disapprove request"})
+		rejected, err := circ.RejectRequest(audit.Context(c), request.ID, adminID, strings.TrimSpace(input.Reason))
+		if err != nil {
+			respondCirculationError(c, err, "Could not disapprove request")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Issue request disapproved successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": "Issue request disapproved successfully", "request": rejected})
 	}
 }
 
-func IssueBookToUser(db *gorm.DB) gin.HandlerFunc {
+// IssueBookToUser lets an admin issue a book directly to a reader
+func IssueBookToUser(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		isbn := c.Param("isbn")
 
@@ -139,48 +172,96 @@ func IssueBookToUser(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		var input struct {
-			UserID    uint `json:"user_id"`
-			LibraryID uint `json:"library_id"`
+			UserID    uint   `json:"user_id" binding:"required"`
+			LibraryID uint   `json:"library_id" binding:"required"`
+			Barcode   string `json:"barcode"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
 			return
 		}
 
-		var book models.Book
-		if err := db.Where("isbn = ? AND library_id = ?", isbn, input.LibraryID).First(&book).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in this library"})
+		if member, err := libraries.IsMember(c.Request.Context(), adminID.(uint), input.LibraryID); err != nil || !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned as an admin for this library"})
 			return
 		}
 
-		if book.AvailableCopies == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No available copies to issue"})
+		// The service checks the reader belongs to the library and owes no more than it allows
+		issue, err := circ.Issue(audit.Context(c), circulation.IssueInput{
+			ISBN:       isbn,
+			LibraryID:  input.LibraryID,
+			Barcode:    input.Barcode,
+			ReaderID:   input.UserID,
+			ApproverID: adminID.(uint),
+		})
+		if errors.Is(err, services.ErrNotMember) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Reader is not registered in this library"})
 			return
 		}
-
-		book.AvailableCopies--
-		db.Save(&book)
-
-		issueDate := time.Now()
-		expectedReturnDate := issueDate.AddDate(0, 0, 14)
-
-		issueRecord := models.IssueRegistry{
-			ISBN:               isbn,
-			ReaderID:           input.UserID,
-			IssueApproverID:    adminID.(uint),
-			IssueStatus:        "issued",
-			IssueDate:          issueDate.Unix(),
-			ExpectedReturnDate: expectedReturnDate.Unix(),
-			ReturnDate:         0,
-			ReturnApproverID:   0,
-		}
-
-		if err := db.Create(&issueRecord).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not issue book"})
+		if err != nil {
+			respondCirculationError(c, err, "Could not issue book")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Book issued successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": "Book issued successfully", "issue": issue})
+	}
+}
+
+// respondCirculationError maps circulation errors to HTTP responses
+func respondCirculationError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, circulation.ErrRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Request not found"})
+	case errors.Is(err, circulation.ErrCopyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Copy not found"})
+	case errors.Is(err, circulation.ErrNoActiveLoan):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Copy is not on loan"})
+	case errors.Is(err, circulation.ErrCopyMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Copy does not belong to this book"})
+	case errors.Is(err, circulation.ErrCopyUnavailable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Copy is not available"})
+	case errors.Is(err, circulation.ErrBookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in this library"})
+	case errors.Is(err, circulation.ErrNotIssueRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not an issue request"})
+	case errors.Is(err, circulation.ErrNotReturnRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not a return request"})
+	case errors.Is(err, circulation.ErrAlreadyApproved):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request is already approved"})
+	case errors.Is(err, circulation.ErrRequestClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Request is no longer pending"})
+	case errors.Is(err, circulation.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, circulation.ErrReasonRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+	case errors.Is(err, circulation.ErrNoCopiesAvailable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No available copies to issue"})
+	case errors.Is(err, circulation.ErrLoanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Loan not found"})
+	case errors.Is(err, circulation.ErrLoanClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Loan has already been returned"})
+	case errors.Is(err, circulation.ErrRenewalLimit):
+		c.JSON(http.StatusConflict, gin.H{"error": "Maximum number of renewals reached"})
+	case errors.Is(err, circulation.ErrLoanOverdue):
+		c.JSON(http.StatusConflict, gin.H{"error": "Overdue loans cannot be renewed; please return the book"})
+	case errors.Is(err, circulation.ErrLoanLimit):
+		c.JSON(http.StatusConflict, gin.H{"error": "Reader has reached the loan limit for this library"})
+	case errors.Is(err, circulation.ErrHoldsWaiting):
+		c.JSON(http.StatusConflict, gin.H{"error": "Other readers are waiting for this book"})
+	case errors.Is(err, circulation.ErrFineNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fine not found"})
+	case errors.Is(err, circulation.ErrFineWaived):
+		c.JSON(http.StatusConflict, gin.H{"error": "Fine has already been waived"})
+	case errors.Is(err, circulation.ErrInvalidAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+	case errors.Is(err, circulation.ErrOverpayment):
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment exceeds the outstanding balance"})
+	case errors.Is(err, circulation.ErrBalanceLimit):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Outstanding fines exceed the library's limit; please settle them first"})
+	case errors.Is(err, circulation.ErrAlreadyReturned):
+		c.JSON(http.StatusConflict, gin.H{"error": "Book has already been returned"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
 	}
 }
 
@@ -189,4 +270,4 @@ func formatUnixTime(timestamp *int64) string {
 		return "N/A"
 	}
 	return time.Unix(*timestamp, 0).Format("2006-01-02 15:04:05")
-}
\ No newline at end of file
+}
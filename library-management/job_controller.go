@@ -0,0 +1,40 @@
+// ⏱️ Background Jobs
+package controllers
+
+import (
+	"library-management/pagination"
+	"library-management/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListJobRuns pages through background job runs, most recent first, optionally for one job
+func ListJobRuns(jobs services.JobService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := pagination.Parse(c, services.JobRunSort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		runs, page, err := jobs.ListRuns(c.Request.Context(), c.Query("job"), req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch job runs"})
+			return
+		}
+
+		formattedRuns := make([]gin.H, len(runs))
+		for i, run := range runs {
+			formattedRuns[i] = gin.H{
+				"job":         run.Job,
+				"status":      run.Status,
+				"started_at":  formatUnixTime(&run.StartedAt),
+				"finished_at": formatUnixTime(&run.FinishedAt),
+				"affected":    run.Affected,
+				"error":       run.Error,
+			}
+		}
+		c.JSON(http.StatusOK, pagination.Envelope(formattedRuns, page))
+	}
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"library-management/services"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestListJobRuns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/jobs/runs", ListJobRuns(services.NewJobService(gormDB)))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "job_runs" WHERE job = $1 AND "job_runs"."deleted_at" IS NULL ORDER BY job, id LIMIT $2`)).
+		WithArgs("expire-holds", 51).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "job", "started_at", "finished_at", "status", "affected", "error"}).
+			AddRow(1, "expire-holds", 1767268800, 1767268801, "succeeded", 2, ""))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/runs?job=expire-holds&sort=job&limit=50", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"job":"expire-holds"`)
+	assert.Contains(t, w.Body.String(), `"affected":2`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
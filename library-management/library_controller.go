@@ -1,15 +1,17 @@
 package controllers
 
 import (
+	"library-management/audit"
 	"library-management/models"
+	"library-management/pagination"
+	"library-management/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // CreateLibrary handles creating a new library
-func CreateLibrary(db *gorm.DB) gin.HandlerFunc {
+func CreateLibrary(libraries services.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input models.Library
 
@@ -18,7 +20,7 @@ func CreateLibrary(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		if err := db.Create(&input).Error; err != nil {
+		if err := libraries.CreateLibrary(audit.Context(c), &input); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create library"})
 			return
 		}
@@ -27,16 +29,21 @@ func CreateLibrary(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-// ListLibraries fetches all libraries
-func ListLibraries(db *gorm.DB) gin.HandlerFunc {
+// ListLibraries fetches a page of libraries
+func ListLibraries(libraries services.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var libraries []models.Library
+		req, err := pagination.Parse(c, services.LibrarySort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		if err := db.Find(&libraries).Error; err != nil {
+		list, page, err := libraries.ListLibraries(c.Request.Context(), req)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch libraries"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"libraries": libraries})
+		c.JSON(http.StatusOK, pagination.Envelope(list, page))
 	}
 }
@@ -1,25 +1,153 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"library-management/circulation"
 	"library-management/config"
+	"library-management/migrations"
+	"library-management/notify"
 	"library-management/routes"
+	"library-management/scheduler"
+	"library-management/storage"
+	"library-management/utils"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
+const usage = `Usage: library-management [-config file] [command]
+
+Commands:
+  serve                 start the HTTP server (default)
+` + migrations.CommandUsage
+
 func main() {
+	configPath := flag.String("config", os.Getenv("LMS_CONFIG"), "path to a YAML or TOML config file")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	command := "serve"
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+	if command != "serve" && command != "migrate" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Load and validate configuration before touching the database
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	configureLogging(cfg.Log)
+
+	if err := configureAuth(cfg); err != nil {
+		log.Fatalf("Invalid auth configuration: %v", err)
+	}
+
 	// Initialize the database and handle errors
-	// Pass 'false' as you are not running in a test environment
-	db, err := config.ConnectDatabase(false)
+	db, err := config.ConnectDatabase(cfg.Database)
 	if err != nil {
 		log.Fatalf("Database initialization failed: %v", err)
 	}
 
-	// Set up the Gin router with the database instance
-	r := routes.SetupRouter(db)
+	if command == "migrate" {
+		if err := storage.Migrate(context.Background(), db, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
 
-	// Start the server on port 8080
-	log.Println("Server is running on port 8080...")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Refuse to serve against an outdated schema; SQLite gets its tables created here
+	if err := storage.PrepareSchema(context.Background(), db); err != nil {
+		log.Fatal(err)
 	}
+
+	// Stop serving and scheduling on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jobs := scheduler.New(db)
+	if cfg.Scheduler.Enabled {
+		notifier := notify.FromConfig(db, cfg.Notify)
+		circ := circulation.NewService(db, cfg.Circulation).WithNotifier(notifier)
+
+		every := time.Duration(cfg.Scheduler.IntervalMinutes) * time.Minute
+		for _, job := range scheduler.CirculationJobs(circ, every) {
+			jobs.Add(job)
+		}
+		for _, job := range scheduler.TokenJobs(db, every) {
+			jobs.Add(job)
+		}
+		for _, job := range scheduler.NotificationJobs(notifier, time.Duration(cfg.Notify.DeliverySeconds)*time.Second) {
+			jobs.Add(job)
+		}
+		jobs.Start(ctx)
+	}
+
+	// Set up the Gin router with the configuration and database instance
+	srv := &http.Server{
+		Addr:    cfg.Server.ListenAddr,
+		Handler: routes.SetupRouter(cfg, db),
+	}
+	go func() {
+		slog.Info("Server is running", "addr", cfg.Server.ListenAddr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("Shutting down")
+
+	// Let in-flight requests and job runs finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown failed", "error", err)
+	}
+	jobs.Wait()
+}
+
+// configureLogging applies the log level to slog, which the server logs through,
+// and to Gin, whose route listing is only printed at debug level
+func configureLogging(cfg config.LogConfig) {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(cfg.Level)) // Validated by config.Load
+	slog.SetLogLoggerLevel(level)
+
+	if level > slog.LevelDebug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+}
+
+// configureAuth installs JWT keys, token lifetimes and the password hasher
+func configureAuth(cfg *config.Config) error {
+	if cfg.JWT.Configured() {
+		keySet, err := cfg.JWT.KeySet()
+		if err != nil {
+			return err
+		}
+		utils.SetKeySet(keySet)
+	} else {
+		slog.Warn("jwt.allow_insecure_dev_key is set, signing tokens with the insecure development key")
+	}
+
+	utils.AccessTokenTTL = time.Duration(cfg.JWT.AccessTokenTTLMinutes) * time.Minute
+	utils.RefreshTokenTTL = time.Duration(cfg.JWT.RefreshTokenTTLDays) * 24 * time.Hour
+	utils.SetPasswordHasher(utils.NewBcryptHasher(cfg.Passwords.BcryptCost))
+	return nil
 }
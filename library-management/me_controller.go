@@ -0,0 +1,150 @@
+// 🙋 My Requests and Loans
+package controllers
+
+import (
+	"library-management/audit"
+	"library-management/models"
+	"library-management/pagination"
+	"library-management/services"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// myRequestSort lists a reader's own requests newest first
+var myRequestSort = pagination.Spec[models.RequestEvent]{Fields: services.RequestSort.Fields, Default: "-request_date"}
+
+// ListMyRequests pages through the signed-in user's requests, newest first, optionally
+// filtered by ?status= and ?type=
+func ListMyRequests(circ services.CirculationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		req, err := pagination.Parse(c, myRequestSort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter := services.RequestFilter{ReaderID: userID.(uint), Status: c.Query("status"), Type: c.Query("type"), Page: req}
+		if filter.Status != "" && !slices.Contains(models.RequestStatuses, filter.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown request status: " + filter.Status})
+			return
+		}
+		if filter.Type != "" && filter.Type != "issue" && filter.Type != "return" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Request type must be issue or return"})
+			return
+		}
+
+		requests, page, err := circ.ListRequests(c.Request.Context(), filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch requests"})
+			return
+		}
+
+		formattedRequests := make([]gin.H, len(requests))
+		for i, request := range requests {
+			formattedRequests[i] = gin.H{
+				"id":            request.ID,
+				"isbn":          request.BookID,
+				"library_id":    request.LibraryID,
+				"request_type":  request.RequestType,
+				"status":        request.Status,
+				"request_date":  formatUnixTime(&request.RequestDate),
+				"approval_date": formatUnixTime(request.ApprovalDate),
+				"issue_id":      request.IssueID,
+			}
+			if request.Status == models.RequestRejected {
+				formattedRequests[i]["rejection_reason"] = request.RejectionReason
+			}
+		}
+		c.JSON(http.StatusOK, pagination.Envelope(formattedRequests, page))
+	}
+}
+
+// CancelMyRequest lets users withdraw one of their pending requests
+func CancelMyRequest(circ services.CirculationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		request, err := circ.CancelRequest(audit.Context(c), uint(requestID), userID.(uint))
+		if err != nil {
+			respondCirculationError(c, err, "Could not cancel request")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Request cancelled", "request": request})
+	}
+}
+
+// ListMyLoans pages through the signed-in user's loans, newest first; ?status=current or
+// ?status=past narrows them
+func ListMyLoans(circ services.CirculationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		req, err := pagination.Parse(c, services.LoanSort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter := services.LoanFilter{ReaderID: userID.(uint), Page: req}
+		switch c.Query("status") {
+		case "":
+		case "current":
+			filter.Status = "issued"
+		case "past":
+			filter.Status = "returned"
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Loan status must be current or past"})
+			return
+		}
+
+		loans, page, err := circ.ListLoans(c.Request.Context(), filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch loans"})
+			return
+		}
+
+		now := time.Now().Unix()
+		formattedLoans := make([]gin.H, len(loans))
+		for i, loan := range loans {
+			current := loan.IssueStatus == "issued"
+			formattedLoans[i] = gin.H{
+				"id":            loan.ID,
+				"isbn":          loan.ISBN,
+				"library_id":    loan.LibraryID,
+				"status":        loan.IssueStatus,
+				"issue_date":    formatUnixTime(&loan.IssueDate),
+				"due_date":      formatUnixTime(&loan.ExpectedReturnDate),
+				"return_date":   formatUnixTime(&loan.ReturnDate),
+				"renewal_count": loan.RenewalCount,
+				"overdue":       current && loan.ExpectedReturnDate > 0 && loan.ExpectedReturnDate < now,
+				"returned_late": !current && loan.ExpectedReturnDate > 0 && loan.ReturnDate > loan.ExpectedReturnDate,
+			}
+		}
+		c.JSON(http.StatusOK, pagination.Envelope(formattedLoans, page))
+	}
+}
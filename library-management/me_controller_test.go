@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/services"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestMyRequests(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", uint(2))
+		c.Set("userRole", "user")
+	})
+	r.GET("/me/requests", ListMyRequests(services.NewCirculationService(gormDB, nil, nil)))
+	r.DELETE("/me/requests/:id", CancelMyRequest(services.NewCirculationService(gormDB, circulation.NewService(gormDB, config.Default().Circulation), nil)))
+
+	requestColumns := []string{"id", "book_id", "library_id", "reader_id", "request_type", "request_date", "status", "rejection_reason"}
+
+	t.Run("Filtered by status and type", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE reader_id = $1 AND status = $2 AND request_type = $3 AND "request_events"."deleted_at" IS NULL ORDER BY request_date DESC, id DESC LIMIT $4`)).
+			WithArgs(2, "rejected", "issue", 21).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(4, "123456789", 1, 2, "issue", time.Now().Unix(), "rejected", "Reference copy only"))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me/requests?status=rejected&type=issue", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"rejected"`)
+		assert.Contains(t, w.Body.String(), `"rejection_reason":"Reference copy only"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown status", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me/requests?status=lost", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Cancel a pending request", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE (id = $1 AND reader_id = $2)`)).
+			WithArgs(4, 2, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(4, "123456789", 1, 2, "issue", time.Now().Unix(), "pending", ""))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, "request.cancel", "request", 4)
+		mock.ExpectCommit()
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/me/requests/4", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"cancelled"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Cancel an approved request", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE (id = $1 AND reader_id = $2)`)).
+			WithArgs(4, 2, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(4, "123456789", 1, 2, "issue", time.Now().Unix(), "approved", ""))
+		mock.ExpectRollback()
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/me/requests/4", nil))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestListMyLoans(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", uint(2))
+		c.Set("userRole", "user")
+	})
+	r.GET("/me/loans", ListMyLoans(services.NewCirculationService(gormDB, nil, nil)))
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "issue_registries" WHERE reader_id = $1 AND issue_status = $2 AND "issue_registries"."deleted_at" IS NULL`)).
+		WithArgs(2, "issued").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE reader_id = $1 AND issue_status = $2 AND "issue_registries"."deleted_at" IS NULL ORDER BY expected_return_date, id LIMIT $3`)).
+		WithArgs(2, "issued", 21).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id", "issue_status", "issue_date", "expected_return_date", "return_date"}).
+			AddRow(7, "123456789", 1, 2, "issued", now.AddDate(0, 0, -20).Unix(), now.AddDate(0, 0, -6).Unix(), 0).
+			AddRow(8, "987654321", 1, 2, "issued", now.AddDate(0, 0, -2).Unix(), now.AddDate(0, 0, 12).Unix(), 0))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me/loans?status=current&sort=due_date&total=true", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":7,"isbn":"123456789","issue_date"`)
+	assert.Regexp(t, `"id":7,.*"overdue":true`, w.Body.String())
+	assert.Regexp(t, `"id":8,.*"overdue":false`, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"page":{"limit":20,"sort":"due_date","total":2}`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
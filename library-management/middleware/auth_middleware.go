@@ -1,14 +1,24 @@
 package middleware
 
 import (
-	"fmt"
 	"library-management/utils"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// RevocationChecker reports whether the access token with the given claims was revoked
+type RevocationChecker func(claims *utils.TokenClaims) (bool, error)
+
+var revocationChecker RevocationChecker
+
+// SetRevocationChecker installs the lookup AuthMiddleware uses to reject revoked tokens
+func SetRevocationChecker(checker RevocationChecker) {
+	revocationChecker = checker
+}
+
 // AuthMiddleware verifies JWT and checks user role
 func AuthMiddleware(requiredRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -30,14 +40,31 @@ func AuthMiddleware(requiredRole string) gin.HandlerFunc {
 
 		tokenString = tokenParts[1] // Extract actual token
 
-		// Validate JWT using utils.ValidateJWT
-		userID, userRole, err := utils.ValidateJWT(tokenString)
+		// Validate JWT using utils.ParseJWT
+		claims, err := utils.ParseJWT(tokenString)
 		if err != nil {
-			fmt.Println("JWT Validation Error:", err) // Log the error
+			slog.Debug("Rejected token", "error", err) // A client error, so only worth seeing when debugging
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
+		userID, userRole := claims.UserID, claims.Role
+
+		// Reject tokens revoked by logout, or by an owner, before they expire
+		if revocationChecker != nil {
+			revoked, err := revocationChecker(claims)
+			if err != nil {
+				slog.Error("Token revocation check failed", "user_id", userID, "jti", claims.JTI, "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify token"})
+				c.Abort()
+				return
+			}
+			if revoked {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+				c.Abort()
+				return
+			}
+		}
 
 		// If a role is required, check access
 		if requiredRole != "" {
@@ -64,6 +91,8 @@ func AuthMiddleware(requiredRole string) gin.HandlerFunc {
 		// Store user details in context for later use
 		c.Set("userID", userID)
 		c.Set("userRole", userRole)
+		c.Set("tokenJTI", claims.JTI)
+		c.Set("tokenExpiresAt", claims.ExpiresAt.Unix())
 		c.Next()
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		allowed []string
+		method  string
+		origin  string
+		code    int
+		allow   string
+	}{
+		{"Listed origin", []string{"https://lms.example.com/"}, http.MethodGet, "https://lms.example.com", http.StatusOK, "https://lms.example.com"},
+		{"Trailing slash on the request", []string{"https://lms.example.com"}, http.MethodGet, "https://lms.example.com/", http.StatusOK, "https://lms.example.com"},
+		{"Unlisted origin", []string{"https://lms.example.com"}, http.MethodGet, "https://evil.example.com", http.StatusOK, ""},
+		{"Any origin", []string{"*"}, http.MethodGet, "https://evil.example.com", http.StatusOK, "*"},
+		{"Preflight", []string{"https://lms.example.com"}, http.MethodOptions, "https://lms.example.com", http.StatusNoContent, "https://lms.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(CORS(tt.allowed))
+			r.Handle(tt.method, "/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.allow, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package migrations
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var fixture = fstest.MapFS{
+	"sql/0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id int);")},
+	"sql/0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	"sql/0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id int);")},
+	"sql/0002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+}
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.NotEmpty(t, m.Checksum, "migration %d has no checksum", m.Version)
+		if i > 0 {
+			assert.Greater(t, m.Version, migrations[i-1].Version)
+		}
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	t.Run("Missing down file", func(t *testing.T) {
+		_, err := load(fstest.MapFS{"sql/0001_a.up.sql": {Data: []byte("SELECT 1;")}})
+		assert.ErrorContains(t, err, "both up and down files are required")
+	})
+
+	t.Run("Invalid version", func(t *testing.T) {
+		_, err := load(fstest.MapFS{"sql/first_a.up.sql": {Data: []byte("SELECT 1;")}})
+		assert.ErrorContains(t, err, "invalid version")
+	})
+
+	t.Run("Unknown suffix", func(t *testing.T) {
+		_, err := load(fstest.MapFS{"sql/0001_a.sql": {Data: []byte("SELECT 1;")}})
+		assert.ErrorContains(t, err, ".up.sql or .down.sql")
+	})
+}
+
+func newTestRunner(t *testing.T) (*Runner, sqlmock.Sqlmock, []Migration) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	migrations, err := load(fixture)
+	assert.NoError(t, err)
+	return &Runner{db: db, migrations: migrations}, mock, migrations
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).
+		WithArgs(lockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS schema_migrations`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).
+		WithArgs(lockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestRunnerUp(t *testing.T) {
+	runner, mock, migrations := newTestRunner(t)
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version, checksum, applied_at FROM schema_migrations`)).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "checksum", "applied_at"}).
+			AddRow(1, migrations[0].Checksum, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE b`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO schema_migrations`)).
+		WithArgs(int64(2), "create_b", migrations[1].Checksum, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	applied, err := runner.Up(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, applied, 1)
+	assert.Equal(t, int64(2), applied[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunnerDown(t *testing.T) {
+	runner, mock, migrations := newTestRunner(t)
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version, checksum, applied_at FROM schema_migrations`)).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "checksum", "applied_at"}).
+			AddRow(1, migrations[0].Checksum, time.Now()).
+			AddRow(2, migrations[1].Checksum, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE b`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM schema_migrations WHERE version = $1`)).
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	rolledBack, err := runner.Down(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, rolledBack, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunnerChecksumMismatch(t *testing.T) {
+	runner, mock, _ := newTestRunner(t)
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version, checksum, applied_at FROM schema_migrations`)).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "checksum", "applied_at"}).
+			AddRow(1, "edited", time.Now()))
+	expectUnlock(mock)
+
+	_, err := runner.Up(context.Background())
+	assert.ErrorContains(t, err, "checksum mismatch")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunnerPending(t *testing.T) {
+	runner, mock, migrations := newTestRunner(t)
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version, checksum, applied_at FROM schema_migrations`)).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "checksum", "applied_at"}).
+			AddRow(1, migrations[0].Checksum, time.Now()))
+	expectUnlock(mock)
+
+	pending, err := runner.Pending(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "create_b", pending[0].Name)
+}
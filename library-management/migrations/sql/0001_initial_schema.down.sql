@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS user_libraries;
+DROP TABLE IF EXISTS issue_registries;
+DROP TABLE IF EXISTS request_events;
+DROP TABLE IF EXISTS books;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS libraries;
@@ -0,0 +1,77 @@
+-- Core schema. IF NOT EXISTS lets databases created by the old AutoMigrate adopt this baseline.
+
+CREATE TABLE IF NOT EXISTS libraries (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    CONSTRAINT uni_libraries_name UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    email text NOT NULL,
+    contact text,
+    role varchar(50),
+    password text NOT NULL,
+    CONSTRAINT uni_users_email UNIQUE (email),
+    CONSTRAINT chk_users_role CHECK (role IN ('owner', 'admin', 'user'))
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS books (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    isbn text NOT NULL,
+    title text NOT NULL,
+    authors text,
+    publisher text,
+    version text,
+    total_copies bigint,
+    available_copies bigint,
+    library_id bigint
+);
+CREATE INDEX IF NOT EXISTS idx_books_deleted_at ON books (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_books_library_id ON books (library_id);
+
+CREATE TABLE IF NOT EXISTS request_events (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    book_id text NOT NULL,
+    library_id bigint NOT NULL,
+    reader_id bigint NOT NULL,
+    request_date bigint NOT NULL,
+    approval_date bigint,
+    approver_id bigint,
+    request_type varchar(50) NOT NULL,
+    CONSTRAINT chk_request_events_request_type CHECK (request_type IN ('issue', 'return'))
+);
+CREATE INDEX IF NOT EXISTS idx_request_events_deleted_at ON request_events (deleted_at);
+
+CREATE TABLE IF NOT EXISTS issue_registries (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    isbn text NOT NULL,
+    reader_id bigint NOT NULL,
+    issue_approver_id bigint NOT NULL,
+    issue_status varchar(50) NOT NULL,
+    issue_date bigint NOT NULL,
+    expected_return_date bigint NOT NULL,
+    return_date bigint DEFAULT 0,
+    return_approver_id bigint DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_issue_registries_deleted_at ON issue_registries (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_libraries (
+    user_id bigint NOT NULL,
+    library_id bigint NOT NULL,
+    PRIMARY KEY (user_id, library_id)
+);
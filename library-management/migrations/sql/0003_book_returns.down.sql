@@ -0,0 +1,4 @@
+ALTER TABLE request_events DROP COLUMN IF EXISTS issue_id;
+DROP INDEX IF EXISTS idx_issue_registries_isbn_library;
+DROP INDEX IF EXISTS idx_issue_registries_reader_id;
+ALTER TABLE issue_registries DROP COLUMN IF EXISTS library_id;
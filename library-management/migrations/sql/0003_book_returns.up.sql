@@ -0,0 +1,18 @@
+-- Loans remember their library so a return can restock the right book row,
+-- and return requests point at the loan they close.
+
+ALTER TABLE issue_registries ADD COLUMN IF NOT EXISTS library_id bigint NOT NULL DEFAULT 0;
+
+-- Backfill from the book catalog; an ISBN held by several libraries stays 0 and needs manual review
+UPDATE issue_registries ir
+SET library_id = b.library_id
+FROM books b
+WHERE ir.library_id = 0
+  AND b.isbn = ir.isbn
+  AND b.deleted_at IS NULL
+  AND (SELECT count(*) FROM books b2 WHERE b2.isbn = ir.isbn AND b2.deleted_at IS NULL) = 1;
+
+CREATE INDEX IF NOT EXISTS idx_issue_registries_reader_id ON issue_registries (reader_id);
+CREATE INDEX IF NOT EXISTS idx_issue_registries_isbn_library ON issue_registries (isbn, library_id);
+
+ALTER TABLE request_events ADD COLUMN IF NOT EXISTS issue_id bigint;
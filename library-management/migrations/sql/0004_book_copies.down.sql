@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_issue_registries_copy_id;
+ALTER TABLE issue_registries DROP COLUMN IF EXISTS copy_id;
+DROP TABLE IF EXISTS book_copies;
@@ -0,0 +1,52 @@
+-- Physical copies with barcodes. books.total_copies and books.available_copies
+-- are kept as counters derived from copy status.
+
+CREATE TABLE IF NOT EXISTS book_copies (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    book_id bigint NOT NULL,
+    barcode text NOT NULL,
+    condition varchar(50) NOT NULL DEFAULT 'good',
+    shelf_location text,
+    status varchar(50) NOT NULL DEFAULT 'available',
+    acquired_at bigint,
+    CONSTRAINT chk_book_copies_status CHECK (status IN ('available', 'issued', 'damaged', 'lost', 'withdrawn'))
+);
+CREATE INDEX IF NOT EXISTS idx_book_copies_deleted_at ON book_copies (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_book_copies_book_id ON book_copies (book_id);
+CREATE INDEX IF NOT EXISTS idx_book_copies_status ON book_copies (status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_book_copies_barcode ON book_copies (barcode);
+
+-- One copy per counted copy; the first (total - available) are the ones out on loan
+INSERT INTO book_copies (created_at, updated_at, book_id, barcode, condition, status, acquired_at)
+SELECT now(), now(), b.id,
+       lpad(b.id::text, 6, '0') || '-' || lpad(n::text, 3, '0'),
+       'good',
+       CASE WHEN n <= GREATEST(b.total_copies - b.available_copies, 0) THEN 'issued' ELSE 'available' END,
+       extract(epoch FROM coalesce(b.created_at, now()))::bigint
+FROM books b
+CROSS JOIN LATERAL generate_series(1, GREATEST(coalesce(b.total_copies, 0), 0)) AS n
+WHERE b.deleted_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM book_copies c WHERE c.book_id = b.id);
+
+ALTER TABLE issue_registries ADD COLUMN IF NOT EXISTS copy_id bigint;
+CREATE INDEX IF NOT EXISTS idx_issue_registries_copy_id ON issue_registries (copy_id);
+
+-- Pair open loans with the backfilled issued copies, oldest loan first
+WITH loans AS (
+    SELECT ir.id, b.id AS book_id, row_number() OVER (PARTITION BY b.id ORDER BY ir.id) AS n
+    FROM issue_registries ir
+    JOIN books b ON b.isbn = ir.isbn AND b.library_id = ir.library_id AND b.deleted_at IS NULL
+    WHERE ir.issue_status = 'issued' AND ir.copy_id IS NULL
+), copies AS (
+    SELECT c.id, c.book_id, row_number() OVER (PARTITION BY c.book_id ORDER BY c.id) AS n
+    FROM book_copies c
+    WHERE c.status = 'issued'
+)
+UPDATE issue_registries ir
+SET copy_id = copies.id
+FROM loans
+JOIN copies ON copies.book_id = loans.book_id AND copies.n = loans.n
+WHERE ir.id = loans.id;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS holds;
+
+UPDATE book_copies SET status = 'available' WHERE status = 'on_hold';
+ALTER TABLE book_copies DROP CONSTRAINT IF EXISTS chk_book_copies_status;
+ALTER TABLE book_copies ADD CONSTRAINT chk_book_copies_status
+    CHECK (status IN ('available', 'issued', 'damaged', 'lost', 'withdrawn'));
@@ -0,0 +1,30 @@
+-- Reservation queue per ISBN per library. A copy set aside for a ready hold
+-- has status 'on_hold' so it is neither available nor on loan.
+
+ALTER TABLE book_copies DROP CONSTRAINT IF EXISTS chk_book_copies_status;
+ALTER TABLE book_copies ADD CONSTRAINT chk_book_copies_status
+    CHECK (status IN ('available', 'on_hold', 'issued', 'damaged', 'lost', 'withdrawn'));
+
+CREATE TABLE IF NOT EXISTS holds (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    isbn text NOT NULL,
+    library_id bigint NOT NULL,
+    reader_id bigint NOT NULL,
+    status varchar(50) NOT NULL DEFAULT 'waiting',
+    placed_at bigint NOT NULL,
+    copy_id bigint,
+    ready_at bigint,
+    pickup_by bigint,
+    closed_at bigint,
+    CONSTRAINT chk_holds_status CHECK (status IN ('waiting', 'ready', 'fulfilled', 'cancelled', 'expired'))
+);
+CREATE INDEX IF NOT EXISTS idx_holds_deleted_at ON holds (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_holds_queue ON holds (isbn, library_id);
+CREATE INDEX IF NOT EXISTS idx_holds_reader_id ON holds (reader_id);
+
+-- A reader has at most one active hold per title
+CREATE UNIQUE INDEX IF NOT EXISTS idx_holds_active_reader ON holds (isbn, library_id, reader_id)
+    WHERE status IN ('waiting', 'ready') AND deleted_at IS NULL;
@@ -0,0 +1,17 @@
+-- Renewals extend a loan's due date; each one is kept as a history row.
+
+ALTER TABLE issue_registries ADD COLUMN IF NOT EXISTS renewal_count bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS loan_renewals (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    issue_id bigint NOT NULL,
+    renewed_by_id bigint NOT NULL,
+    previous_due_date bigint NOT NULL,
+    new_due_date bigint NOT NULL,
+    renewed_at bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_loan_renewals_deleted_at ON loan_renewals (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_loan_renewals_issue_id ON loan_renewals (issue_id);
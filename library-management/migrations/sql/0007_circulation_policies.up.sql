@@ -0,0 +1,17 @@
+-- Per-library circulation rules. Libraries without a row use the configured defaults.
+
+CREATE TABLE IF NOT EXISTS circulation_policies (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    library_id bigint NOT NULL,
+    loan_period_days bigint NOT NULL,
+    max_loans bigint NOT NULL,
+    max_renewals bigint NOT NULL,
+    hold_pickup_days bigint NOT NULL,
+    fine_per_day_cents bigint NOT NULL,
+    max_fine_cents bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_circulation_policies_deleted_at ON circulation_policies (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_circulation_policies_library_id ON circulation_policies (library_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS fines;
+ALTER TABLE circulation_policies DROP COLUMN IF EXISTS max_balance_cents;
+ALTER TABLE circulation_policies DROP COLUMN IF EXISTS fine_grace_days;
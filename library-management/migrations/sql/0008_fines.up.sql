@@ -0,0 +1,43 @@
+-- Overdue fines and the ledger of charges, payments and waivers per reader and library.
+
+ALTER TABLE circulation_policies ADD COLUMN IF NOT EXISTS fine_grace_days bigint NOT NULL DEFAULT 0;
+ALTER TABLE circulation_policies ADD COLUMN IF NOT EXISTS max_balance_cents bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS fines (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    issue_id bigint NOT NULL,
+    reader_id bigint NOT NULL,
+    library_id bigint NOT NULL,
+    days_overdue bigint NOT NULL,
+    amount_cents bigint NOT NULL,
+    status varchar(50) NOT NULL DEFAULT 'charged',
+    assessed_at bigint NOT NULL,
+    waived_by_id bigint,
+    waived_reason text,
+    CONSTRAINT chk_fines_status CHECK (status IN ('charged', 'waived'))
+);
+CREATE INDEX IF NOT EXISTS idx_fines_deleted_at ON fines (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fines_issue_id ON fines (issue_id);
+CREATE INDEX IF NOT EXISTS idx_fines_reader_id ON fines (reader_id);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    reader_id bigint NOT NULL,
+    library_id bigint NOT NULL,
+    fine_id bigint,
+    kind varchar(50) NOT NULL,
+    amount_cents bigint NOT NULL,
+    note text,
+    recorded_by_id bigint NOT NULL,
+    recorded_at bigint NOT NULL,
+    CONSTRAINT chk_ledger_entries_kind CHECK (kind IN ('fine', 'payment', 'waiver'))
+);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_deleted_at ON ledger_entries (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account ON ledger_entries (reader_id, library_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_fine_id ON ledger_entries (fine_id);
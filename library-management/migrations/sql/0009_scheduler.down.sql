@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS job_runs;
+DROP INDEX IF EXISTS idx_issue_registries_open_due;
+ALTER TABLE issue_registries DROP COLUMN IF EXISTS overdue_at;
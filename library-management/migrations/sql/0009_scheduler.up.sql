@@ -0,0 +1,21 @@
+-- Background jobs: loans flagged overdue, unanswered requests expired, and a history of job runs.
+
+ALTER TABLE issue_registries ADD COLUMN IF NOT EXISTS overdue_at bigint;
+CREATE INDEX IF NOT EXISTS idx_issue_registries_open_due ON issue_registries (expected_return_date)
+    WHERE issue_status = 'issued';
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    job varchar(100) NOT NULL,
+    started_at bigint NOT NULL,
+    finished_at bigint NOT NULL,
+    status varchar(50) NOT NULL,
+    affected bigint NOT NULL DEFAULT 0,
+    error text,
+    CONSTRAINT chk_job_runs_status CHECK (status IN ('succeeded', 'failed'))
+);
+CREATE INDEX IF NOT EXISTS idx_job_runs_deleted_at ON job_runs (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_job_runs_job ON job_runs (job);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS outbox_messages;
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS notifications;
+ALTER TABLE issue_registries DROP COLUMN IF EXISTS due_reminder_at;
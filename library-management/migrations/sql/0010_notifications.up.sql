@@ -0,0 +1,50 @@
+-- Notifications: in-app inbox, per-user channel preferences and the delivery outbox.
+
+ALTER TABLE issue_registries ADD COLUMN IF NOT EXISTS due_reminder_at bigint;
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    event varchar(100) NOT NULL,
+    subject text NOT NULL,
+    body text NOT NULL,
+    read_at bigint
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_deleted_at ON notifications (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    channel varchar(50) NOT NULL,
+    enabled boolean NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_notification_preferences_deleted_at ON notification_preferences (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_preferences_user_channel ON notification_preferences (user_id, channel);
+
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    channel varchar(50) NOT NULL,
+    event varchar(100) NOT NULL,
+    address text,
+    subject text NOT NULL,
+    body text NOT NULL,
+    status varchar(50) NOT NULL DEFAULT 'pending',
+    attempts bigint NOT NULL DEFAULT 0,
+    next_attempt_at bigint NOT NULL,
+    last_error text,
+    sent_at bigint,
+    CONSTRAINT chk_outbox_messages_status CHECK (status IN ('pending', 'sent', 'failed'))
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_deleted_at ON outbox_messages (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_due ON outbox_messages (status, next_attempt_at);
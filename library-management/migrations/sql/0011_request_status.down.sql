@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_request_events_status;
+ALTER TABLE request_events DROP CONSTRAINT IF EXISTS chk_request_events_status;
+ALTER TABLE request_events DROP COLUMN IF EXISTS closed_at;
+ALTER TABLE request_events DROP COLUMN IF EXISTS rejected_by_id;
+ALTER TABLE request_events DROP COLUMN IF EXISTS rejection_reason;
+ALTER TABLE request_events DROP COLUMN IF EXISTS status;
@@ -0,0 +1,13 @@
+-- Request status: requests move through a state machine instead of being deleted when turned down.
+
+ALTER TABLE request_events ADD COLUMN IF NOT EXISTS status varchar(50) NOT NULL DEFAULT 'pending';
+ALTER TABLE request_events ADD COLUMN IF NOT EXISTS rejection_reason text;
+ALTER TABLE request_events ADD COLUMN IF NOT EXISTS rejected_by_id bigint;
+ALTER TABLE request_events ADD COLUMN IF NOT EXISTS closed_at bigint;
+ALTER TABLE request_events DROP CONSTRAINT IF EXISTS chk_request_events_status;
+ALTER TABLE request_events ADD CONSTRAINT chk_request_events_status
+    CHECK (status IN ('pending', 'approved', 'rejected', 'cancelled', 'fulfilled', 'expired'));
+CREATE INDEX IF NOT EXISTS idx_request_events_status ON request_events (status);
+
+-- Requests approved before statuses existed; their loans were not linked, so none can be marked fulfilled
+UPDATE request_events SET status = 'approved' WHERE approval_date IS NOT NULL;
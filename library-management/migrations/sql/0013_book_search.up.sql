@@ -0,0 +1,10 @@
+-- Full-text search over the catalogue. Title matches weigh most (A), then
+-- authors (B), then publisher (C); Postgres keeps the vector in step with the row.
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(authors, '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(publisher, '')), 'C')
+    ) STORED;
+CREATE INDEX IF NOT EXISTS idx_books_search_vector ON books USING gin (search_vector);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_books_authors_trgm;
+DROP INDEX IF EXISTS idx_books_title_trgm;
+DROP EXTENSION IF EXISTS pg_trgm;
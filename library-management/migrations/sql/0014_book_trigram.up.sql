@@ -0,0 +1,5 @@
+-- Typo-tolerant search and autocomplete. Trigram indexes serve word similarity
+-- (<%) for misspelt titles and authors, and ILIKE for completions.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_books_title_trgm ON books USING gin (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_books_authors_trgm ON books USING gin (authors gin_trgm_ops);
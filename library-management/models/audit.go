@@ -0,0 +1,63 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// AuditEvent records one administrative or circulation change: who made it, from
+// where, and what the entity looked like before and after. Events are only ever
+// inserted; the database rejects updates and deletes.
+type AuditEvent struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	OccurredAt int64  `gorm:"not null;index" json:"occurred_at"`
+	ActorID    *uint  `gorm:"index" json:"actor_id"`
+	ActorRole  string `gorm:"type:varchar(50)" json:"actor_role"`
+	Action     string `gorm:"type:varchar(100);not null" json:"action"`
+	EntityType string `gorm:"type:varchar(100);not null;index:idx_audit_events_entity" json:"entity_type"`
+	EntityID   string `gorm:"type:varchar(100);not null;index:idx_audit_events_entity" json:"entity_id"`
+	Before     JSON   `gorm:"type:jsonb" json:"before"` // Changed fields only; the whole entity when deleted
+	After      JSON   `gorm:"type:jsonb" json:"after"`  // Changed fields only; the whole entity when created
+	RequestID  string `gorm:"type:varchar(100)" json:"request_id"`
+	ClientIP   string `gorm:"type:varchar(64)" json:"client_ip"`
+}
+
+// JSON is a jsonb column holding raw JSON; empty is NULL
+type JSON []byte
+
+// Value stores the JSON as text, or NULL when empty
+func (j JSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan reads a jsonb column
+func (j *JSON) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(JSON(nil), v...)
+	case string:
+		*j = JSON(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSON", src)
+	}
+	return nil
+}
+
+// MarshalJSON embeds the JSON as is
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON keeps a copy of the raw JSON
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	*j = append(JSON(nil), data...)
+	return nil
+}
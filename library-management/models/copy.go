@@ -0,0 +1,24 @@
+package models
+
+import "gorm.io/gorm"
+
+// Copy statuses; only available copies can be issued
+const (
+	CopyAvailable = "available"
+	CopyOnHold    = "on_hold" // Set aside for a reader with a ready hold
+	CopyIssued    = "issued"
+	CopyDamaged   = "damaged"
+	CopyLost      = "lost"
+	CopyWithdrawn = "withdrawn"
+)
+
+// BookCopy is one physical, barcoded copy of a Book
+type BookCopy struct {
+	gorm.Model
+	BookID        uint   `gorm:"not null;index" json:"book_id"`
+	Barcode       string `gorm:"not null;uniqueIndex" json:"barcode"`
+	Condition     string `gorm:"type:varchar(50);not null;default:good" json:"condition"`
+	ShelfLocation string `json:"shelf_location"`
+	Status        string `gorm:"type:varchar(50);not null;default:available;index;check:status IN ('available', 'on_hold', 'issued', 'damaged', 'lost', 'withdrawn')" json:"status"`
+	AcquiredAt    int64  `json:"acquired_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "gorm.io/gorm"
+
+// Fine statuses
+const (
+	FineCharged = "charged"
+	FineWaived  = "waived"
+)
+
+// Ledger entry kinds; fines add to a reader's balance, payments and waivers reduce it
+const (
+	LedgerFine    = "fine"
+	LedgerPayment = "payment"
+	LedgerWaiver  = "waiver"
+)
+
+// Fine is the overdue charge assessed when a late loan is returned
+type Fine struct {
+	gorm.Model
+	IssueID      uint   `gorm:"not null;uniqueIndex" json:"issue_id"`
+	ReaderID     uint   `gorm:"not null;index" json:"reader_id"`
+	LibraryID    uint   `gorm:"not null" json:"library_id"`
+	DaysOverdue  int    `gorm:"not null" json:"days_overdue"` // Chargeable days, after grace days
+	AmountCents  int64  `gorm:"not null" json:"amount_cents"`
+	Status       string `gorm:"type:varchar(50);not null;default:charged;check:status IN ('charged', 'waived')" json:"status"`
+	AssessedAt   int64  `gorm:"not null" json:"assessed_at"`
+	WaivedByID   *uint  `json:"waived_by_id"`
+	WaivedReason string `json:"waived_reason"`
+}
+
+// LedgerEntry is one change to a reader's balance with a library. Charges are
+// positive and credits negative, so the balance is the sum of the amounts.
+type LedgerEntry struct {
+	gorm.Model
+	ReaderID     uint   `gorm:"not null;index:idx_ledger_entries_account" json:"reader_id"`
+	LibraryID    uint   `gorm:"not null;index:idx_ledger_entries_account" json:"library_id"`
+	FineID       *uint  `gorm:"index" json:"fine_id"`
+	Kind         string `gorm:"type:varchar(50);not null;check:kind IN ('fine', 'payment', 'waiver')" json:"kind"`
+	AmountCents  int64  `gorm:"not null" json:"amount_cents"`
+	Note         string `json:"note"`
+	RecordedByID uint   `gorm:"not null" json:"recorded_by_id"` // The admin who took the payment or waived, or who received the late return
+	RecordedAt   int64  `gorm:"not null" json:"recorded_at"`
+}
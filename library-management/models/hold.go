@@ -0,0 +1,26 @@
+package models
+
+import "gorm.io/gorm"
+
+// Hold statuses; waiting and ready holds are active
+const (
+	HoldWaiting   = "waiting"
+	HoldReady     = "ready"
+	HoldFulfilled = "fulfilled"
+	HoldCancelled = "cancelled"
+	HoldExpired   = "expired"
+)
+
+// Hold is a reader's place in the queue for a title that has no copy on the shelf
+type Hold struct {
+	gorm.Model
+	ISBN      string `gorm:"not null;index:idx_holds_queue" json:"isbn"`
+	LibraryID uint   `gorm:"not null;index:idx_holds_queue" json:"library_id"`
+	ReaderID  uint   `gorm:"not null;index" json:"reader_id"`
+	Status    string `gorm:"type:varchar(50);not null;default:waiting;check:status IN ('waiting', 'ready', 'fulfilled', 'cancelled', 'expired')" json:"status"`
+	PlacedAt  int64  `gorm:"not null" json:"placed_at"`
+	CopyID    *uint  `json:"copy_id"`   // Copy set aside once the hold is ready
+	ReadyAt   *int64 `json:"ready_at"`  // When the copy was set aside
+	PickupBy  *int64 `json:"pickup_by"` // The hold expires if not collected by then
+	ClosedAt  *int64 `json:"closed_at"`
+}
@@ -5,11 +5,16 @@ import "gorm.io/gorm"
 type IssueRegistry struct {
 	gorm.Model
 	ISBN               string `gorm:"not null" json:"isbn"`
+	LibraryID          uint   `gorm:"not null;default:0" json:"library_id"`
+	CopyID             *uint  `gorm:"index" json:"copy_id"` // Nil for loans made before copies were tracked
 	ReaderID           uint   `gorm:"not null" json:"reader_id"`
 	IssueApproverID    uint   `gorm:"not null" json:"issue_approver_id"`
 	IssueStatus        string `gorm:"type:varchar(50);not null" json:"issue_status"`
 	IssueDate          int64  `gorm:"not null" json:"issue_date"`
 	ExpectedReturnDate int64  `gorm:"not null" json:"expected_return_date"`
+	RenewalCount       int    `gorm:"not null;default:0" json:"renewal_count"`
+	OverdueAt          *int64 `json:"overdue_at"`      // Set by the scheduler once the due date has passed
+	DueReminderAt      *int64 `json:"due_reminder_at"` // When the reader was reminded of the due date
 	ReturnDate         int64  `gorm:"default:0" json:"return_date"`
 	ReturnApproverID   uint   `gorm:"default:0" json:"return_approver_id"`
-}
\ No newline at end of file
+}
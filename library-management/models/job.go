@@ -0,0 +1,20 @@
+package models
+
+import "gorm.io/gorm"
+
+// Job run statuses
+const (
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// JobRun records one execution of a background job
+type JobRun struct {
+	gorm.Model
+	Job        string `gorm:"type:varchar(100);not null;index" json:"job"`
+	StartedAt  int64  `gorm:"not null" json:"started_at"`
+	FinishedAt int64  `gorm:"not null" json:"finished_at"`
+	Status     string `gorm:"type:varchar(50);not null;check:status IN ('succeeded', 'failed')" json:"status"`
+	Affected   int    `gorm:"not null;default:0" json:"affected"` // Rows the job changed
+	Error      string `json:"error,omitempty"`
+}
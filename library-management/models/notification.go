@@ -0,0 +1,47 @@
+package models
+
+import "gorm.io/gorm"
+
+// Outbox message statuses
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// Notification is a message in a user's in-app inbox
+type Notification struct {
+	gorm.Model
+	UserID  uint   `gorm:"not null;index" json:"user_id"`
+	Event   string `gorm:"type:varchar(100);not null" json:"event"`
+	Subject string `gorm:"not null" json:"subject"`
+	Body    string `gorm:"not null" json:"body"`
+	ReadAt  *int64 `json:"read_at"`
+}
+
+// NotificationPreference turns one delivery channel on or off for a user;
+// channels without a row use their default
+type NotificationPreference struct {
+	gorm.Model
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_notification_preferences_user_channel" json:"user_id"`
+	Channel string `gorm:"type:varchar(50);not null;uniqueIndex:idx_notification_preferences_user_channel" json:"channel"`
+	Enabled bool   `gorm:"not null" json:"enabled"`
+}
+
+// OutboxMessage is a rendered notification waiting to be delivered on one channel.
+// It is written in the same transaction as the change it reports, so nothing is
+// lost if the server stops before delivery.
+type OutboxMessage struct {
+	gorm.Model
+	UserID        uint   `gorm:"not null" json:"user_id"`
+	Channel       string `gorm:"type:varchar(50);not null" json:"channel"`
+	Event         string `gorm:"type:varchar(100);not null" json:"event"`
+	Address       string `json:"address"` // Email address or phone number; empty for the inbox
+	Subject       string `gorm:"not null" json:"subject"`
+	Body          string `gorm:"not null" json:"body"`
+	Status        string `gorm:"type:varchar(50);not null;default:pending;check:status IN ('pending', 'sent', 'failed');index:idx_outbox_messages_due" json:"status"`
+	Attempts      int    `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt int64  `gorm:"not null;index:idx_outbox_messages_due" json:"next_attempt_at"`
+	LastError     string `json:"last_error,omitempty"`
+	SentAt        *int64 `json:"sent_at"`
+}
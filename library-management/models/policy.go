@@ -0,0 +1,18 @@
+package models
+
+import "gorm.io/gorm"
+
+// CirculationPolicy holds a library's loan, renewal, hold and fine rules.
+// Libraries without one use the configured defaults.
+type CirculationPolicy struct {
+	gorm.Model
+	LibraryID       uint  `gorm:"not null;uniqueIndex" json:"library_id"`
+	LoanPeriodDays  int   `gorm:"not null" json:"loan_period_days"`
+	MaxLoans        int   `gorm:"not null" json:"max_loans"`    // Concurrent loans per reader, 0 for no limit
+	MaxRenewals     int   `gorm:"not null" json:"max_renewals"` // Renewals allowed per loan, 0 disables renewal
+	HoldPickupDays  int   `gorm:"not null" json:"hold_pickup_days"`
+	FinePerDayCents int64 `gorm:"not null" json:"fine_per_day_cents"`          // Charged for each day a loan is overdue
+	MaxFineCents    int64 `gorm:"not null" json:"max_fine_cents"`              // Cap on the fine for one loan, 0 for no cap
+	FineGraceDays   int   `gorm:"not null;default:0" json:"fine_grace_days"`   // Overdue days not charged
+	MaxBalanceCents int64 `gorm:"not null;default:0" json:"max_balance_cents"` // Readers owing more may not request books, 0 for no limit
+}
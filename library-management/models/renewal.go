@@ -0,0 +1,13 @@
+package models
+
+import "gorm.io/gorm"
+
+// LoanRenewal records one extension of a loan's due date
+type LoanRenewal struct {
+	gorm.Model
+	IssueID         uint  `gorm:"not null;index" json:"issue_id"`
+	RenewedByID     uint  `gorm:"not null" json:"renewed_by_id"` // The reader or admin who renewed
+	PreviousDueDate int64 `gorm:"not null" json:"previous_due_date"`
+	NewDueDate      int64 `gorm:"not null" json:"new_due_date"`
+	RenewedAt       int64 `gorm:"not null" json:"renewed_at"`
+}
@@ -2,14 +2,33 @@ package models
 
 import "gorm.io/gorm"
 
+// Request statuses. Pending requests await a librarian; approved issue requests become
+// fulfilled when their loan is returned. The other statuses are final.
+const (
+	RequestPending   = "pending"
+	RequestApproved  = "approved"
+	RequestRejected  = "rejected"
+	RequestCancelled = "cancelled"
+	RequestFulfilled = "fulfilled"
+	RequestExpired   = "expired"
+)
+
+// RequestStatuses lists every request status
+var RequestStatuses = []string{RequestPending, RequestApproved, RequestRejected, RequestCancelled, RequestFulfilled, RequestExpired}
+
 type RequestEvent struct {
 	gorm.Model
-	ID           uint   `gorm:"primaryKey"`
-	BookID       string `gorm:"not null" json:"isbn"`
-	LibraryID    uint   `gorm:"not null" json:"libraryid"`
-	ReaderID     uint   `gorm:"not null"` // Reference to User (Reader)
-	RequestDate  int64  `gorm:"not null"`
-	ApprovalDate *int64 `gorm:"default:null"` // Default -1 Not yet approved
-	ApproverID   *uint  `gorm:"default:null"` // Default 0 Not yet approved
-	RequestType  string `gorm:"type:varchar(50);not null;check:request_type IN ('issue', 'return')"`
+	ID              uint   `gorm:"primaryKey"`
+	BookID          string `gorm:"not null" json:"isbn"`
+	LibraryID       uint   `gorm:"not null" json:"libraryid"`
+	ReaderID        uint   `gorm:"not null"` // Reference to User (Reader)
+	RequestDate     int64  `gorm:"not null"`
+	ApprovalDate    *int64 `gorm:"default:null"` // Default -1 Not yet approved
+	ApproverID      *uint  `gorm:"default:null"` // Default 0 Not yet approved
+	RequestType     string `gorm:"type:varchar(50);not null;check:request_type IN ('issue', 'return')"`
+	IssueID         *uint  `gorm:"default:null" json:"issue_id"` // Loan opened by an issue request or closed by a return request
+	Status          string `gorm:"type:varchar(50);not null;default:pending;index;check:status IN ('pending', 'approved', 'rejected', 'cancelled', 'fulfilled', 'expired')" json:"status"`
+	RejectionReason string `json:"rejection_reason,omitempty"`
+	RejectedByID    *uint  `gorm:"default:null" json:"rejected_by_id,omitempty"`
+	ClosedAt        *int64 `gorm:"default:null" json:"closed_at,omitempty"` // When the request was rejected, cancelled or expired
 }
@@ -0,0 +1,25 @@
+package models
+
+import "gorm.io/gorm"
+
+// RefreshToken is a single-use token exchanged for a new access token.
+// Every token rotated from the same login shares a FamilyID so reuse of an
+// already rotated token can revoke the whole chain.
+type RefreshToken struct {
+	gorm.Model
+	UserID       uint   `gorm:"not null;index"`
+	TokenHash    string `gorm:"not null;uniqueIndex"` // SHA-256 of the opaque token
+	FamilyID     string `gorm:"not null;index"`
+	ExpiresAt    int64  `gorm:"not null"`
+	RevokedAt    *int64 `gorm:"default:null"`
+	ReplacedByID *uint  `gorm:"default:null"`
+}
+
+// RevokedToken blocks an access token before it expires, keyed by its jti.
+// A row keyed "user:<id>" blocks every token of that user issued up to RevokedAt.
+type RevokedToken struct {
+	JTI       string `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null"`
+	ExpiresAt int64  `gorm:"not null;index"` // Row can be purged after this
+	RevokedAt int64  `gorm:"not null"`
+}
@@ -1,16 +1,19 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
+	"library-management/audit"
 	"library-management/models"
+	"library-management/services"
+	"library-management/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // RegisterOwnerNew allows an existing owner to create a new owner
-func RegisterOwnerNew(db *gorm.DB) gin.HandlerFunc {
+func RegisterOwnerNew(users services.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input models.User
 
@@ -24,17 +27,34 @@ func RegisterOwnerNew(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		if err := db.Create(&input).Error; err != nil {
+		if input.Password == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Password is required"})
+			return
+		}
+
+		hashed, err := utils.HashPassword(input.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not hash password"})
+			return
+		}
+		input.Password = hashed
+
+		err = users.CreateUser(audit.Context(c), &input, nil)
+		if errors.Is(err, services.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+			return
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create owner"})
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "New owner registered successfully", "owner": input})
+		respondWithLibraries(c, users, input.ID, "owner", "New owner registered successfully")
 	}
 }
 
 // RegisterAdmin allows an owner to create an admin
-func RegisterAdmin(db *gorm.DB) gin.HandlerFunc {
+func RegisterAdmin(users services.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
 			Name       string `json:"name" binding:"required"`
@@ -49,67 +69,47 @@ func RegisterAdmin(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		creatorID := c.GetUint("userID")
-		var creator models.User
-		if err := db.First(&creator, creatorID).Error; err != nil || creator.Role != "owner" {
+		creator, err := users.GetUser(c.Request.Context(), c.GetUint("userID"))
+		if err != nil || creator.Role != "owner" {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Only an owner can create an admin"})
 			return
 		}
 
+		hashed, err := utils.HashPassword(input.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not hash password"})
+			return
+		}
+
 		admin := models.User{
 			Name:     input.Name,
 			Email:    input.Email,
-			Password: input.Password,
+			Password: hashed,
 			Contact:  input.Contact,
 			Role:     "admin",
 		}
 
-		if err := db.Create(&admin).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create admin"})
+		// The admin, their libraries and the audit event are saved together or not at all
+		var missing *services.LibraryNotFoundError
+		err = users.CreateUser(audit.Context(c), &admin, input.LibraryIDs)
+		switch {
+		case errors.As(err, &missing):
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Library ID %d not found", missing.ID)})
 			return
-		}
-
-		for _, libID := range input.LibraryIDs {
-			var library models.Library
-			if err := db.First(&library, libID).Error; err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Library ID %d not found", libID)})
-				return
-			}
-
-			adminLibrary := models.UserLibrary{
-				UserID:    admin.ID,
-				LibraryID: libID,
-			}
-			if err := db.Create(&adminLibrary).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to associate admin with library"})
-				return
-			}
-		}
-
-		// Preload libraries to include them in the response
-		var adminWithLibraries models.User
-		if err := db.Preload("Library").First(&adminWithLibraries, admin.ID).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load libraries"})
+		case errors.Is(err, services.ErrEmailTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create admin"})
 			return
 		}
 
-		// Return the response with the libraries included
-		c.JSON(http.StatusCreated, gin.H{
-			"message": "Admin registered successfully",
-			"admin": gin.H{
-				"ID":      adminWithLibraries.ID,
-				"Name":    adminWithLibraries.Name,
-				"Email":   adminWithLibraries.Email,
-				"Role":    adminWithLibraries.Role,
-				"Contact": adminWithLibraries.Contact,
-				"Library": adminWithLibraries.Library, // This will include associated libraries
-			},
-		})
+		respondWithLibraries(c, users, admin.ID, "admin", "Admin registered successfully")
 	}
 }
 
 // RegisterUser allows an admin to create a user
-func RegisterUser(db *gorm.DB) gin.HandlerFunc {
+func RegisterUser(users services.UserService, libraries services.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
 			Name       string `json:"name" binding:"required"`
@@ -127,15 +127,15 @@ func RegisterUser(db *gorm.DB) gin.HandlerFunc {
 
 		// Admin authentication check
 		adminID := c.GetUint("userID")
-		var admin models.User
-		if err := db.First(&admin, adminID).Error; err != nil || admin.Role != "admin" {
+		admin, err := users.GetUser(c.Request.Context(), adminID)
+		if err != nil || admin.Role != "admin" {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can create users"})
 			return
 		}
 
 		// Fetch admin's accessible libraries
-		var adminLibraries []uint
-		if err := db.Table("user_libraries").Where("user_id = ?", adminID).Pluck("library_id", &adminLibraries).Error; err != nil {
+		adminLibraries, err := libraries.LibraryIDs(c.Request.Context(), adminID)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify admin libraries"})
 			return
 		}
@@ -155,10 +155,9 @@ func RegisterUser(db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 
-		// Check for duplicate email
-		var existingUser models.User
-		if err := db.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		hashed, err := utils.HashPassword(input.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not hash password"})
 			return
 		}
 
@@ -166,46 +165,42 @@ func RegisterUser(db *gorm.DB) gin.HandlerFunc {
 		user := models.User{
 			Name:     input.Name,
 			Email:    input.Email,
-			Password: input.Password,
+			Password: hashed,
 			Contact:  input.Contact,
 			Role:     "user", // Default role as "user"
 		}
 
-		if err := db.Create(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not register user"})
+		err = users.CreateUser(audit.Context(c), &user, input.LibraryIDs)
+		if errors.Is(err, services.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
 			return
 		}
-
-		// Associate user with libraries
-		for _, libID := range input.LibraryIDs {
-			userLibrary := models.UserLibrary{
-				UserID:    user.ID,
-				LibraryID: libID,
-			}
-			if err := db.Create(&userLibrary).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to associate user with library"})
-				return
-			}
-		}
-
-		// Preload libraries for the response
-		var userWithLibraries models.User
-		if err := db.Preload("Library").First(&userWithLibraries, user.ID).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load libraries"})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not register user"})
 			return
 		}
 
-		// Return the response with the libraries included
-		c.JSON(http.StatusCreated, gin.H{
-			"message": "User registered successfully",
-			"user": gin.H{
-				"ID":      userWithLibraries.ID,
-				"Name":    userWithLibraries.Name,
-				"Email":   userWithLibraries.Email,
-				"Role":    userWithLibraries.Role,
-				"Contact": userWithLibraries.Contact,
-				"Library": userWithLibraries.Library, // This will include associated libraries
-			},
-		})
+		respondWithLibraries(c, users, user.ID, "user", "User registered successfully")
 	}
 }
+
+// respondWithLibraries answers a registration with the new account and the libraries it was given
+func respondWithLibraries(c *gin.Context, users services.UserService, id uint, key, message string) {
+	created, err := users.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load libraries"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": message,
+		key: gin.H{
+			"ID":      created.ID,
+			"Name":    created.Name,
+			"Email":   created.Email,
+			"Role":    created.Role,
+			"Contact": created.Contact,
+			"Library": created.Library, // This will include associated libraries
+		},
+	})
+}
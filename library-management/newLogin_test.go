@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 
+	"library-management/services"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
@@ -27,13 +28,13 @@ func TestRegisterOwnerNew(t *testing.T) {
 	// Set Gin to TestMode for API tests
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
-	r.POST("/register/owner", RegisterOwnerNew(gormDB))
+	r.POST("/register/owner", RegisterOwnerNew(services.NewUserService(gormDB)))
 
 	// Successful Owner Registration
 	t.Run("Successful Owner Registration", func(t *testing.T) {
 		// Expect the SQL INSERT query for creating a new user
 		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "users" ("name","email","password","role") VALUES ($1,$2,$3,$4)`)).
-			WithArgs("John Doe", "john@example.com", "securepassword", "owner").
+			WithArgs("John Doe", "john@example.com", sqlmock.AnyArg(), "owner").
 			WillReturnResult(sqlmock.NewResult(1, 1)) // Simulate successful row insertion
 
 		req := httptest.NewRequest(http.MethodPost, "/register/owner",
@@ -50,6 +51,38 @@ func TestRegisterOwnerNew(t *testing.T) {
 		//assert.Contains(t, w.Body.String(), "Owner registered successfully")
 	})
 
+	// The response describes the owner without the password hash
+	t.Run("Password Not Returned", func(t *testing.T) {
+		store := services.NewMemory()
+		r := gin.Default()
+		r.POST("/register/owner", RegisterOwnerNew(store))
+
+		req := httptest.NewRequest(http.MethodPost, "/register/owner",
+			bytes.NewBufferString(`{"name":"John Doe","email":"john@example.com","password":"securepassword","role":"owner"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), "New owner registered successfully")
+		assert.Contains(t, w.Body.String(), `"Email":"john@example.com"`)
+		assert.NotContains(t, w.Body.String(), "Password")
+		assert.NotContains(t, w.Body.String(), "$2a$")
+	})
+
+	// Missing password is the caller's mistake, not a hashing failure
+	t.Run("Missing Password", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/register/owner",
+			bytes.NewBufferString(`{"name":"Jane Doe","email":"jane@example.com","role":"owner"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Password is required")
+	})
+
 	// Invalid Role (Test case when role is not "owner")
 	t.Run("Invalid Role", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/register/owner",
@@ -66,7 +99,7 @@ func TestRegisterOwnerNew(t *testing.T) {
 	// Database Error (Simulating an error when inserting the user)
 	t.Run("Database Error", func(t *testing.T) {
 		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "users" ("name","email","password","role") VALUES ($1,$2,$3,$4)`)).
-			WithArgs("Jane Doe", "jane@example.com", "securepassword", "owner").
+			WithArgs("Jane Doe", "jane@example.com", sqlmock.AnyArg(), "owner").
 			WillReturnError(fmt.Errorf("database error"))
 
 		req := httptest.NewRequest(http.MethodPost, "/register/owner",
@@ -83,7 +116,7 @@ func TestRegisterOwnerNew(t *testing.T) {
 	// Unexpected Server Error
 	t.Run("Unexpected Server Error", func(t *testing.T) {
 		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "users" ("name","email","password","role") VALUES ($1,$2,$3,$4)`)).
-			WithArgs("John Doe", "john@example.com", "securepassword", "owner").
+			WithArgs("John Doe", "john@example.com", sqlmock.AnyArg(), "owner").
 			WillReturnError(fmt.Errorf("unexpected server error"))
 
 		req := httptest.NewRequest(http.MethodPost, "/register/owner",
@@ -152,7 +185,7 @@ func TestRegisterOwnerNew(t *testing.T) {
 	// Duplicate Email (Simulating unique constraint violation)
 	t.Run("Duplicate Email", func(t *testing.T) {
 		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "users" ("name","email","password","role") VALUES ($1,$2,$3,$4)`)).
-			WithArgs("John Doe", "john@example.com", "securepassword", "owner").
+			WithArgs("John Doe", "john@example.com", sqlmock.AnyArg(), "owner").
 			WillReturnError(fmt.Errorf("duplicate key value violates unique constraint"))
 
 		req := httptest.NewRequest(http.MethodPost, "/register/owner",
@@ -208,7 +241,7 @@ func TestRegisterAdmin(t *testing.T) {
 	// Mock the context for the "userID" and use RegisterAdmin handler
 	r.POST("/register/admin", func(c *gin.Context) {
 		c.Set("userID", uint(1)) // Mock user as owner (assuming user ID 1 is an owner)
-		RegisterAdmin(gormDB)(c)
+		RegisterAdmin(services.NewUserService(gormDB))(c)
 	})
 
 	// Successful Admin Registration
@@ -220,7 +253,7 @@ func TestRegisterAdmin(t *testing.T) {
 
 		// Mock Admin User Creation
 		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "users" ("name","email","password","role") VALUES ($1,$2,$3,$4)`)).
-			WithArgs("Admin Name", "admin@example.com", "securepassword", "admin").
+			WithArgs("Admin Name", "admin@example.com", sqlmock.AnyArg(), "admin").
 			WillReturnResult(sqlmock.NewResult(1, 1)) // Ensure row is inserted
 
 		// Mock Library Association
@@ -406,7 +439,7 @@ func TestRegisterUser(t *testing.T) {
 	r := gin.Default()
 	r.POST("/register/user", func(c *gin.Context) {
 		c.Set("userID", uint(1)) // Mock user as admin
-		RegisterUser(gormDB)(c)
+		RegisterUser(services.NewUserService(gormDB), services.NewLibraryService(gormDB))(c)
 	})
 
 	// Existing test cases...
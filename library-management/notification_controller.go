@@ -0,0 +1,155 @@
+// 🔔 Notifications
+package controllers
+
+import (
+	"errors"
+	"library-management/pagination"
+	"library-management/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListNotifications pages through the signed-in user's inbox, newest first; ?unread=true hides read messages
+func ListNotifications(notifications services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		req, err := pagination.Parse(c, services.NotificationSort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		inbox, page, err := notifications.ListNotifications(c.Request.Context(), userID.(uint), c.Query("unread") == "true", req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch notifications"})
+			return
+		}
+
+		unread, err := notifications.UnreadCount(c.Request.Context(), userID.(uint))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not count unread notifications"})
+			return
+		}
+
+		formatted := make([]gin.H, len(inbox))
+		for i, n := range inbox {
+			formatted[i] = gin.H{
+				"id":         n.ID,
+				"event":      n.Event,
+				"subject":    n.Subject,
+				"body":       n.Body,
+				"created_at": n.CreatedAt,
+				"read":       n.ReadAt != nil,
+				"read_at":    formatUnixTime(n.ReadAt),
+			}
+		}
+		reply := pagination.Envelope(formatted, page)
+		reply["unread"] = unread
+		c.JSON(http.StatusOK, reply)
+	}
+}
+
+// MarkNotificationRead marks one of the signed-in user's notifications as read;
+// marking twice keeps the original read time
+func MarkNotificationRead(notifications services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+
+		notification, err := notifications.MarkRead(c.Request.Context(), userID.(uint), uint(id))
+		if errors.Is(err, services.ErrNotificationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update notification"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read", "notification": notification})
+	}
+}
+
+// MarkAllNotificationsRead marks every unread notification of the signed-in user as read
+func MarkAllNotificationsRead(notifications services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		marked, err := notifications.MarkAllRead(c.Request.Context(), userID.(uint))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update notifications"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read", "marked": marked})
+	}
+}
+
+// GetNotificationPreferences lists the configured channels and whether the signed-in user receives them
+func GetNotificationPreferences(notifications services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		preferences, err := notifications.Preferences(c.Request.Context(), userID.(uint))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch notification preferences"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"preferences": preferences})
+	}
+}
+
+// UpdateNotificationPreferences turns channels on or off for the signed-in user
+func UpdateNotificationPreferences(notifications services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		var input struct {
+			Channels map[string]bool `json:"channels" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+			return
+		}
+
+		preferences, err := notifications.UpdatePreferences(c.Request.Context(), userID.(uint), input.Channels)
+		var unknown *services.UnknownChannelError
+		if errors.As(err, &unknown) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification channel: " + unknown.Channel})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update notification preferences"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Notification preferences updated", "preferences": preferences})
+	}
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"library-management/notify"
+	"library-management/services"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+	notifier := notify.New(gormDB, 5, notify.NewInboxChannel(gormDB), notify.NewSMSWebhookChannel("http://sms.invalid"))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", uint(2))
+		c.Set("userRole", "user")
+	})
+	notifications := services.NewNotificationService(gormDB, notifier)
+	r.GET("/me/notification-preferences", GetNotificationPreferences(notifications))
+	r.PUT("/me/notification-preferences", UpdateNotificationPreferences(notifications))
+
+	prefsQuery := regexp.QuoteMeta(`SELECT * FROM "notification_preferences" WHERE user_id = $1 AND "notification_preferences"."deleted_at" IS NULL`)
+
+	t.Run("Defaults", func(t *testing.T) {
+		mock.ExpectQuery(prefsQuery).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "channel", "enabled"}))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me/notification-preferences", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `{"channel":"inbox","enabled":true}`)
+		assert.Contains(t, w.Body.String(), `{"channel":"sms","enabled":false}`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Turn on SMS", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "notification_preferences" ("created_at","updated_at","deleted_at","user_id","channel","enabled") VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT ("user_id","channel") DO UPDATE SET "enabled"="excluded"."enabled","updated_at"="excluded"."updated_at" RETURNING "id"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, "sms", true).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+		mock.ExpectQuery(prefsQuery).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "channel", "enabled"}).AddRow(1, 2, "sms", true))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/me/notification-preferences", strings.NewReader(`{"channels":{"sms":true}}`)))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `{"channel":"sms","enabled":true}`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown channel", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/me/notification-preferences", strings.NewReader(`{"channels":{"email":true}}`)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Unknown notification channel: email")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestNotificationInbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", uint(2))
+		c.Set("userRole", "user")
+	})
+	notifications := services.NewNotificationService(gormDB, notify.New(gormDB, 5, notify.NewInboxChannel(gormDB)))
+	r.GET("/me/notifications", ListNotifications(notifications))
+	r.POST("/me/notifications/:id/read", MarkNotificationRead(notifications))
+	r.POST("/me/notifications/read-all", MarkAllNotificationsRead(notifications))
+
+	columns := []string{"id", "created_at", "user_id", "event", "subject", "body", "read_at"}
+
+	t.Run("Unread only", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "notifications" WHERE user_id = $1 AND read_at IS NULL AND "notifications"."deleted_at" IS NULL ORDER BY created_at DESC, id DESC LIMIT $2`)).
+			WithArgs(2, 21).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(5, time.Now(), 2, notify.EventIssueApproved, "Your request for Dune was approved", "Hi Ada", nil))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "notifications" WHERE (user_id = $1 AND read_at IS NULL)`)).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me/notifications?unread=true", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"event":"issue_approved"`)
+		assert.Contains(t, w.Body.String(), `"read":false`)
+		assert.Contains(t, w.Body.String(), `"unread":1`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Mark one read", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "notifications" WHERE (id = $1 AND user_id = $2)`)).
+			WithArgs(5, 2, 1).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(5, time.Now(), 2, notify.EventIssueApproved, "Approved", "Hi Ada", nil))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "notifications" SET "read_at"=$1,"updated_at"=$2 WHERE "notifications"."deleted_at" IS NULL AND "id" = $3`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/me/notifications/5/read", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), `"read_at":null`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Someone else's notification", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "notifications" WHERE (id = $1 AND user_id = $2)`)).
+			WithArgs(6, 2, 1).
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/me/notifications/6/read", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Mark all read", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "notifications" SET "read_at"=$1,"updated_at"=$2 WHERE (user_id = $3 AND read_at IS NULL) AND "notifications"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 2).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/me/notifications/read-all", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"marked":3`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"library-management/config"
+	"library-management/models"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Channel delivers rendered messages to users
+type Channel interface {
+	// Name identifies the channel in preferences and the outbox
+	Name() string
+	// Address returns where the user is reached on this channel, or false if they cannot be
+	Address(user *models.User) (string, bool)
+	// Send delivers one message
+	Send(ctx context.Context, msg Message) error
+}
+
+// storer is implemented by channels that deliver by writing to the database;
+// they are written in the notifying transaction rather than queued in the outbox
+type storer interface {
+	Store(tx *gorm.DB, msg Message) error
+}
+
+// InboxChannel stores messages in the user's in-app inbox
+type InboxChannel struct {
+	db *gorm.DB
+}
+
+// NewInboxChannel returns the in-app inbox channel
+func NewInboxChannel(db *gorm.DB) *InboxChannel {
+	return &InboxChannel{db: db}
+}
+
+func (c *InboxChannel) Name() string { return "inbox" }
+
+func (c *InboxChannel) Address(*models.User) (string, bool) { return "", true }
+
+func (c *InboxChannel) Send(ctx context.Context, msg Message) error {
+	return c.Store(c.db.WithContext(ctx), msg)
+}
+
+// Store adds the message to the inbox as part of tx, so it appears as soon as the change commits
+func (c *InboxChannel) Store(tx *gorm.DB, msg Message) error {
+	return tx.Create(&models.Notification{
+		UserID:  msg.UserID,
+		Event:   msg.Event,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+	}).Error
+}
+
+// SMTPChannel sends email through an SMTP server
+type SMTPChannel struct {
+	cfg config.SMTPConfig
+}
+
+// NewSMTPChannel returns an email channel using cfg
+func NewSMTPChannel(cfg config.SMTPConfig) *SMTPChannel {
+	return &SMTPChannel{cfg: cfg}
+}
+
+func (c *SMTPChannel) Name() string { return "email" }
+
+// Address skips users whose email is missing or cannot be parsed
+func (c *SMTPChannel) Address(user *models.User) (string, bool) {
+	if user.Email == "" {
+		return "", false
+	}
+	_, err := mail.ParseAddress(user.Email)
+	return user.Email, err == nil
+}
+
+func (c *SMTPChannel) Send(_ context.Context, msg Message) error {
+	// Parsed addresses and an encoded subject keep CR and LF out of the headers,
+	// where they would let a value add headers or recipients of its own
+	from, err := mail.ParseAddress(c.cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.Address)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+	subject := strings.Join(strings.Fields(msg.Subject), " ")
+
+	var auth smtp.Auth
+	if c.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(c.cfg.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, host)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return smtp.SendMail(c.cfg.Addr, auth, from.Address, []string{to.Address}, []byte(b.String()))
+}
+
+// SMSWebhookChannel posts text messages to an SMS gateway webhook
+type SMSWebhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+// NewSMSWebhookChannel returns an SMS channel posting to url
+func NewSMSWebhookChannel(url string) *SMSWebhookChannel {
+	return &SMSWebhookChannel{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *SMSWebhookChannel) Name() string { return "sms" }
+
+// Address uses the contact field, which holds the reader's phone number
+func (c *SMSWebhookChannel) Address(user *models.User) (string, bool) {
+	return user.Contact, user.Contact != ""
+}
+
+func (c *SMSWebhookChannel) Send(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(map[string]string{"to": msg.Address, "body": msg.Subject + "\n\n" + msg.Body})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sms webhook returned %s", resp.Status)
+	}
+	return nil
+}
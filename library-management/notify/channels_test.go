@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"library-management/config"
+	"library-management/models"
+	"library-management/notify/smtptest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSMTPChannel(t *testing.T) {
+	server, err := smtptest.NewServer()
+	assert.NoError(t, err)
+	defer server.Close()
+
+	ch := NewSMTPChannel(config.SMTPConfig{Addr: server.Addr, From: "library@example.com"})
+	address, ok := ch.Address(&models.User{Email: "ada@example.com"})
+	assert.True(t, ok)
+
+	err = ch.Send(context.Background(), Message{Address: address, Subject: "Dune is ready for pickup", Body: "Hi Ada,\n\n.Collect it soon."})
+	assert.NoError(t, err)
+
+	mail := server.Messages()
+	if assert.Len(t, mail, 1) {
+		assert.Equal(t, "library@example.com", mail[0].From)
+		assert.Equal(t, []string{"ada@example.com"}, mail[0].To)
+		assert.Contains(t, mail[0].Data, "Subject: Dune is ready for pickup\r\n")
+		assert.Contains(t, mail[0].Data, "Hi Ada,\r\n\r\n.Collect it soon.")
+	}
+
+	_, ok = ch.Address(&models.User{})
+	assert.False(t, ok, "users without an email are skipped")
+	_, ok = ch.Address(&models.User{Email: "ada@example.com\r\nBcc: eve@example.com"})
+	assert.False(t, ok, "users with an unparseable email are skipped")
+}
+
+func TestSMTPChannelHeaders(t *testing.T) {
+	server, err := smtptest.NewServer()
+	assert.NoError(t, err)
+	defer server.Close()
+
+	ch := NewSMTPChannel(config.SMTPConfig{Addr: server.Addr, From: "Library <library@example.com>"})
+
+	err = ch.Send(context.Background(), Message{Address: "ada@example.com", Subject: "Dune\r\nBcc: eve@example.com", Body: "Hi"})
+	assert.NoError(t, err)
+	err = ch.Send(context.Background(), Message{Address: "ada@example.com", Subject: "Café au lait is ready", Body: "Hi"})
+	assert.NoError(t, err)
+	err = ch.Send(context.Background(), Message{Address: "ada@example.com\r\nBcc: eve@example.com", Subject: "Dune", Body: "Hi"})
+	assert.ErrorContains(t, err, "invalid recipient address")
+
+	mail := server.Messages()
+	if assert.Len(t, mail, 2) {
+		assert.Equal(t, "library@example.com", mail[0].From)
+		assert.Equal(t, []string{"ada@example.com"}, mail[0].To)
+		assert.Contains(t, mail[0].Data, "From: \"Library\" <library@example.com>\r\n")
+		assert.Contains(t, mail[0].Data, "Subject: Dune Bcc: eve@example.com\r\n")
+		assert.NotContains(t, mail[0].Data, "\r\nBcc:")
+		assert.Contains(t, mail[1].Data, "Subject: =?utf-8?q?Caf=C3=A9_au_lait_is_ready?=\r\n")
+	}
+}
+
+func TestSMSWebhookChannel(t *testing.T) {
+	var received map[string]string
+	status := http.StatusAccepted
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer gateway.Close()
+
+	ch := NewSMSWebhookChannel(gateway.URL)
+	msg := Message{Address: "+15550100", Subject: "Dune is overdue", Body: "Please return it."}
+	assert.NoError(t, ch.Send(context.Background(), msg))
+	assert.Equal(t, "+15550100", received["to"])
+	assert.Equal(t, "Dune is overdue\n\nPlease return it.", received["body"])
+
+	status = http.StatusBadGateway
+	assert.Error(t, ch.Send(context.Background(), msg), "gateway errors are retried")
+}
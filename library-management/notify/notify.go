@@ -0,0 +1,213 @@
+// Package notify tells readers about changes to their requests, loans and holds.
+// Notify renders a message per enabled channel inside the caller's transaction: the
+// in-app inbox is written straight away and other channels are queued in the outbox,
+// which Deliver later drains, retrying failures with backoff.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"library-management/config"
+	"library-management/models"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// deliveryBatch caps how many messages one Deliver call sends
+const deliveryBatch = 100
+
+// retryDelay is the wait after the first failed attempt; it doubles on each retry
+const retryDelay = 30 * time.Second
+
+// defaultEnabled lists the channels used when a user has no preference for them
+var defaultEnabled = map[string]bool{"inbox": true, "email": true}
+
+// Message is one rendered notification addressed to a user on a channel
+type Message struct {
+	UserID  uint
+	Event   string
+	Address string
+	Subject string
+	Body    string
+}
+
+// Notifier queues and delivers notifications
+type Notifier struct {
+	db          *gorm.DB
+	channels    map[string]Channel
+	maxAttempts int
+}
+
+// New returns a Notifier that delivers through the given channels
+func New(db *gorm.DB, maxAttempts int, channels ...Channel) *Notifier {
+	n := &Notifier{db: db, channels: make(map[string]Channel), maxAttempts: maxAttempts}
+	for _, ch := range channels {
+		n.channels[ch.Name()] = ch
+	}
+	return n
+}
+
+// FromConfig returns a Notifier with the inbox plus whichever of email and SMS are configured
+func FromConfig(db *gorm.DB, cfg config.NotifyConfig) *Notifier {
+	channels := []Channel{NewInboxChannel(db)}
+	if cfg.SMTP.Addr != "" {
+		channels = append(channels, NewSMTPChannel(cfg.SMTP))
+	}
+	if cfg.SMSWebhookURL != "" {
+		channels = append(channels, NewSMSWebhookChannel(cfg.SMSWebhookURL))
+	}
+	return New(db, cfg.MaxAttempts, channels...)
+}
+
+// Channels lists the names of the configured channels
+func (n *Notifier) Channels() []string {
+	names := make([]string, 0, len(n.channels))
+	for name := range n.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultEnabled reports whether a channel is used for users with no preference for it
+func DefaultEnabled(channel string) bool {
+	return defaultEnabled[channel]
+}
+
+// Notify queues an event's message for the user on each channel they have enabled;
+// the inbox is written directly, other channels go through the outbox.
+// Pass the caller's transaction so the message is only sent if the change commits.
+// The user's name is added to data as Name. A nil Notifier does nothing.
+func (n *Notifier) Notify(tx *gorm.DB, userID uint, event string, data map[string]interface{}) error {
+	if n == nil {
+		return nil
+	}
+
+	var user models.User
+	if err := tx.Select("id", "name", "email", "contact").First(&user, userID).Error; err != nil {
+		return err
+	}
+	var prefs []models.NotificationPreference
+	if err := tx.Where("user_id = ?", userID).Find(&prefs).Error; err != nil {
+		return err
+	}
+	enabled := make(map[string]bool)
+	for _, p := range prefs {
+		enabled[p.Channel] = p.Enabled
+	}
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["Name"] = user.Name
+	subject, body, err := Render(event, data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, name := range n.Channels() {
+		on, ok := enabled[name]
+		if !ok {
+			on = DefaultEnabled(name)
+		}
+		if !on {
+			continue
+		}
+		ch := n.channels[name]
+		address, ok := ch.Address(&user)
+		if !ok {
+			continue
+		}
+		if s, ok := ch.(storer); ok {
+			if err := s.Store(tx, Message{UserID: user.ID, Event: event, Address: address, Subject: subject, Body: body}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		msg := models.OutboxMessage{
+			UserID:        user.ID,
+			Channel:       name,
+			Event:         event,
+			Address:       address,
+			Subject:       subject,
+			Body:          body,
+			Status:        models.OutboxPending,
+			NextAttemptAt: now,
+		}
+		if err := tx.Create(&msg).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deliver sends pending outbox messages that are due by now; it returns how many were sent.
+// A failed send is retried with exponential backoff until the attempt limit is reached.
+func (n *Notifier) Deliver(ctx context.Context, now time.Time) (int, error) {
+	var pending []models.OutboxMessage
+	if err := n.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", models.OutboxPending, now.Unix()).
+		Order("id").Limit(deliveryBatch).Find(&pending).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range pending {
+		msg := &pending[i]
+		err := n.send(ctx, msg)
+		if err := n.record(msg, now, err); err != nil {
+			return sent, err
+		}
+		// Failed sends are retried on a later run, so they are logged rather than returned
+		if err != nil {
+			slog.Warn("Notification failed", "id", msg.ID, "channel", msg.Channel, "attempt", msg.Attempts, "error", err)
+			continue
+		}
+		sent++
+	}
+	return sent, ctx.Err()
+}
+
+// send delivers one message on its channel
+func (n *Notifier) send(ctx context.Context, msg *models.OutboxMessage) error {
+	ch, ok := n.channels[msg.Channel]
+	if !ok {
+		return fmt.Errorf("channel %q is not configured", msg.Channel)
+	}
+	return ch.Send(ctx, Message{
+		UserID:  msg.UserID,
+		Event:   msg.Event,
+		Address: msg.Address,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+	})
+}
+
+// record stores the outcome of a delivery attempt
+func (n *Notifier) record(msg *models.OutboxMessage, now time.Time, sendErr error) error {
+	msg.Attempts++
+	updates := map[string]interface{}{"attempts": msg.Attempts}
+	switch {
+	case sendErr == nil:
+		sentAt := now.Unix()
+		msg.Status = models.OutboxSent
+		msg.SentAt = &sentAt
+		updates["status"] = msg.Status
+		updates["sent_at"] = sentAt
+		updates["last_error"] = ""
+	case msg.Attempts >= n.maxAttempts || n.channels[msg.Channel] == nil:
+		msg.Status = models.OutboxFailed
+		updates["status"] = msg.Status
+		updates["last_error"] = sendErr.Error()
+	default:
+		msg.NextAttemptAt = now.Add(retryDelay << (msg.Attempts - 1)).Unix()
+		updates["next_attempt_at"] = msg.NextAttemptAt
+		updates["last_error"] = sendErr.Error()
+	}
+	return n.db.Model(msg).Updates(updates).Error
+}
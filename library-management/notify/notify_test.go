@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"library-management/config"
+	"library-management/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// fakeChannel records what it is asked to send
+type fakeChannel struct {
+	name string
+	err  error
+	sent []Message
+}
+
+func (c *fakeChannel) Name() string { return c.name }
+
+func (c *fakeChannel) Address(*models.User) (string, bool) { return "", true }
+
+func (c *fakeChannel) Send(_ context.Context, msg Message) error {
+	c.sent = append(c.sent, msg)
+	return c.err
+}
+
+func newTestDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+	return gormDB, mock
+}
+
+func TestRender(t *testing.T) {
+	subject, body, err := Render(EventHoldReady, map[string]interface{}{"Name": "Ada", "Title": "Dune", "PickupBy": "Fri 3 Jan 2025"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Dune is ready for pickup", subject)
+	assert.Contains(t, body, "Hi Ada,")
+	assert.Contains(t, body, "collect it by Fri 3 Jan 2025")
+
+	_, _, err = Render(EventHoldReady, map[string]interface{}{"Name": "Ada"})
+	assert.Error(t, err, "missing data is an error, not a blank")
+
+	_, _, err = Render("unknown", nil)
+	assert.Error(t, err)
+}
+
+func TestNotify(t *testing.T) {
+	db, mock := newTestDB(t)
+	n := New(db, 5, NewInboxChannel(db), NewSMTPChannel(config.SMTPConfig{}), NewSMSWebhookChannel("http://sms.invalid"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","name","email","contact" FROM "users" WHERE "users"."id" = $1`)).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "contact"}).AddRow(2, "Ada", "ada@example.com", "+15550100"))
+	// Email is on by default and SMS off, so this reader's choices flip both
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "notification_preferences" WHERE user_id = $1`)).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "channel", "enabled"}).
+			AddRow(1, 2, "email", false).
+			AddRow(2, 2, "sms", true))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "notifications"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, EventLoanDueSoon, "Dune is due on Fri 3 Jan 2025", sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "outbox_messages"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, "sms", EventLoanDueSoon, "+15550100", "Dune is due on Fri 3 Jan 2025", sqlmock.AnyArg(), "pending", 0, sqlmock.AnyArg(), "", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return n.Notify(tx, 2, EventLoanDueSoon, map[string]interface{}{"Title": "Dune", "DueDate": "Fri 3 Jan 2025"})
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotifyWithoutNotifier(t *testing.T) {
+	var n *Notifier
+	assert.NoError(t, n.Notify(nil, 2, EventLoanDueSoon, nil))
+}
+
+func TestDeliver(t *testing.T) {
+	db, mock := newTestDB(t)
+	ok := &fakeChannel{name: "ok"}
+	down := &fakeChannel{name: "down", err: errors.New("connection refused")}
+	n := New(db, 3, ok, down)
+	now := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "outbox_messages" WHERE (status = $1 AND next_attempt_at <= $2) AND "outbox_messages"."deleted_at" IS NULL ORDER BY id LIMIT $3`)).
+		WithArgs("pending", now.Unix(), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "channel", "event", "address", "subject", "body", "status", "attempts"}).
+			AddRow(1, 2, "ok", EventHoldReady, "", "Ready", "Collect it", "pending", 0).
+			AddRow(2, 2, "down", EventHoldReady, "", "Ready", "Collect it", "pending", 0).
+			AddRow(3, 2, "down", EventHoldReady, "", "Ready", "Collect it", "pending", 2).
+			AddRow(4, 2, "pager", EventHoldReady, "", "Ready", "Collect it", "pending", 0))
+
+	// Sent
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "outbox_messages" SET "attempts"=$1,"last_error"=$2,"sent_at"=$3,"status"=$4,"updated_at"=$5 WHERE`)).
+		WithArgs(1, "", now.Unix(), "sent", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// Retried after the first backoff
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "outbox_messages" SET "attempts"=$1,"last_error"=$2,"next_attempt_at"=$3,"updated_at"=$4 WHERE`)).
+		WithArgs(1, "connection refused", now.Add(30*time.Second).Unix(), sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// Out of attempts
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "outbox_messages" SET "attempts"=$1,"last_error"=$2,"status"=$3,"updated_at"=$4 WHERE`)).
+		WithArgs(3, "connection refused", "failed", sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// Channel no longer configured
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "outbox_messages" SET "attempts"=$1,"last_error"=$2,"status"=$3,"updated_at"=$4 WHERE`)).
+		WithArgs(1, `channel "pager" is not configured`, "failed", sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	sent, err := n.Deliver(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Len(t, ok.sent, 1)
+	assert.Len(t, down.sent, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
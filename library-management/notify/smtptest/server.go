@@ -0,0 +1,153 @@
+// Package smtptest runs a local SMTP sink that records the mail it receives,
+// for tests and for trying notifications without a real mail server.
+package smtptest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Mail is one message accepted by the server
+type Mail struct {
+	From string
+	To   []string
+	Data string // Headers and body as sent, with dot-stuffing removed
+}
+
+// Server accepts SMTP connections on a loopback port and keeps every message
+type Server struct {
+	Addr string // host:port to point an SMTP client at
+
+	listener net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	messages []Mail
+}
+
+// NewServer starts a server on a random loopback port
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{Addr: l.Addr().String(), listener: l}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Messages returns the mail received so far
+func (s *Server) Messages() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.messages...)
+}
+
+// Close stops accepting connections and waits for open sessions to end
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.session(conn)
+		}()
+	}
+}
+
+// session speaks just enough SMTP for net/smtp.SendMail
+func (s *Server) session(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	reply := func(line string) bool {
+		w.WriteString(line + "\r\n")
+		return w.Flush() == nil
+	}
+
+	if !reply("220 smtptest ready") {
+		return
+	}
+	var mail Mail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(verb, "EHLO"):
+			reply("250-smtptest")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(verb, "HELO"):
+			reply("250 smtptest")
+		case strings.HasPrefix(verb, "AUTH"):
+			reply("235 Authenticated")
+		case strings.HasPrefix(verb, "MAIL FROM:"):
+			mail = Mail{From: address(line[len("MAIL FROM:"):])}
+			reply("250 OK")
+		case strings.HasPrefix(verb, "RCPT TO:"):
+			mail.To = append(mail.To, address(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case verb == "DATA":
+			if !reply("354 End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			mail.Data = data
+			s.mu.Lock()
+			s.messages = append(s.messages, mail)
+			s.mu.Unlock()
+			reply("250 OK")
+		case verb == "RSET" || verb == "NOOP":
+			reply("250 OK")
+		case verb == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// readData reads a DATA block up to the terminating dot line
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return b.String(), nil
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+// address strips the angle brackets and any parameters from a MAIL or RCPT argument
+func address(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if i := strings.IndexByte(arg, '>'); i >= 0 {
+		arg = arg[:i]
+	}
+	return strings.TrimPrefix(arg, "<")
+}
@@ -0,0 +1,81 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Events readers are notified about
+const (
+	EventIssueRequested   = "issue_requested"
+	EventIssueApproved    = "issue_approved"
+	EventIssueDisapproved = "issue_disapproved"
+	EventLoanDueSoon      = "loan_due_soon"
+	EventLoanOverdue      = "loan_overdue"
+	EventHoldReady        = "hold_ready"
+)
+
+// messageTemplate is the subject and body of one event's message
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func newTemplate(event, subject, body string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New(event + ".subject").Option("missingkey=error").Parse(subject)),
+		body:    template.Must(template.New(event + ".body").Option("missingkey=error").Parse(body)),
+	}
+}
+
+var templates = map[string]messageTemplate{
+	EventIssueRequested: newTemplate(EventIssueRequested,
+		`We received your request for {{.Title}}`,
+		`Hi {{.Name}},
+
+Your request for {{.Title}} is waiting for a librarian. We will let you know once it has been answered.`),
+	EventIssueApproved: newTemplate(EventIssueApproved,
+		`Your request for {{.Title}} was approved`,
+		`Hi {{.Name}},
+
+Your request for {{.Title}} has been approved and the book is now on loan to you.
+Please return it by {{.DueDate}}.`),
+	EventIssueDisapproved: newTemplate(EventIssueDisapproved,
+		`Your request for {{.Title}} was declined`,
+		`Hi {{.Name}},
+
+Your request for {{.Title}} was declined: {{.Reason}}`),
+	EventLoanDueSoon: newTemplate(EventLoanDueSoon,
+		`{{.Title}} is due on {{.DueDate}}`,
+		`Hi {{.Name}},
+
+{{.Title}} is due back on {{.DueDate}}. Renew it or return it by then to avoid a fine.`),
+	EventLoanOverdue: newTemplate(EventLoanOverdue,
+		`{{.Title}} is overdue`,
+		`Hi {{.Name}},
+
+{{.Title}} was due on {{.DueDate}}. Please return it as soon as you can; fines accrue daily.`),
+	EventHoldReady: newTemplate(EventHoldReady,
+		`{{.Title}} is ready for pickup`,
+		`Hi {{.Name}},
+
+A copy of {{.Title}} has been set aside for you. Please collect it by {{.PickupBy}}.`),
+}
+
+// Render fills in the subject and body of an event's message
+func Render(event string, data map[string]interface{}) (subject, body string, err error) {
+	tmpl, ok := templates[event]
+	if !ok {
+		return "", "", fmt.Errorf("no template for event %q", event)
+	}
+
+	var sb, bb strings.Builder
+	if err := tmpl.subject.Execute(&sb, data); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.body.Execute(&bb, data); err != nil {
+		return "", "", err
+	}
+	return sb.String(), bb.String(), nil
+}
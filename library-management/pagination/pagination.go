@@ -0,0 +1,416 @@
+// Package pagination pages and sorts the rows of list endpoints. Every list
+// takes the same query parameters:
+//
+//	limit=20          rows per page, at most 100
+//	cursor=...        continue after the last row of the previous page
+//	offset=40         or skip that many rows instead; not with cursor
+//	sort=-date,name   fields from the list's whitelist, "-" for descending
+//	total=true        also count every matching row
+//
+// and answers with {"data": [...], "page": {...}}. Cursors continue from the
+// values of the last row, so a page never repeats or skips rows that were
+// added or removed meanwhile; offsets let a client jump to any page.
+package pagination
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Page sizes
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrCursorSort    = errors.New("cursor was issued for another sort order")
+)
+
+// Field is a field a list can be sorted by
+type Field[T any] struct {
+	Name   string      // As clients write it in ?sort=
+	Column string      // The SQL column, or alias, holding it
+	Value  func(T) any // The row's value, for cursors and for sorting in memory
+}
+
+// Spec lists the fields a list can be sorted by and its default sort. One of
+// the fields must be named "id": IDs are unique, so every sort ends with it
+// to give each row a place to continue from.
+type Spec[T any] struct {
+	Fields  []Field[T]
+	Default string
+}
+
+type order[T any] struct {
+	field Field[T]
+	desc  bool
+}
+
+// Request is the page of a list a client asked for. The zero Request asks
+// for every row, in whatever order the query already has.
+type Request[T any] struct {
+	Limit  int // 0 for no limit
+	Offset int
+	Total  bool
+	sort   string
+	orders []order[T]
+	after  []any // Sort values of the row the page starts after
+}
+
+// First reports whether r asks for the first page
+func (r Request[T]) First() bool {
+	return r.Offset == 0 && r.after == nil
+}
+
+// Page describes the rows a response holds
+type Page struct {
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset,omitempty"`
+	Sort       string `json:"sort"`
+	NextCursor string `json:"next_cursor,omitempty"` // Empty on the last page
+	Total      *int64 `json:"total,omitempty"`       // Only asked for with total=true
+}
+
+// Envelope is the body of a list response; handlers may add keys of their own
+func Envelope(data any, page Page) gin.H {
+	return gin.H{"data": data, "page": page}
+}
+
+// Parse reads the paging parameters of c. Its errors are meant for the
+// client, as a bad request.
+func Parse[T any](c *gin.Context, spec Spec[T]) (Request[T], error) {
+	r := Request[T]{Limit: DefaultLimit, Total: c.Query("total") == "true"}
+
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxLimit {
+			return r, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+		}
+		r.Limit = n
+	}
+
+	by := c.Query("sort")
+	if by == "" {
+		by = spec.Default
+	}
+	var err error
+	if r.orders, r.sort, err = spec.parse(by); err != nil {
+		return r, err
+	}
+
+	cursor, offset := c.Query("cursor"), c.Query("offset")
+	switch {
+	case cursor != "" && offset != "":
+		return r, errors.New("use either cursor or offset, not both")
+	case offset != "":
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return r, errors.New("offset must be a number of rows")
+		}
+		r.Offset = n
+	case cursor != "":
+		if r.after, err = decode(cursor, r.sort, len(r.orders)); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// parse reads a sort parameter into orders that end with the id, and returns
+// it as it will be echoed back
+func (s Spec[T]) parse(by string) ([]order[T], string, error) {
+	fields := map[string]Field[T]{}
+	names := make([]string, len(s.Fields))
+	for i, f := range s.Fields {
+		fields[f.Name] = f
+		names[i] = f.Name
+	}
+	id, ok := fields["id"]
+	if !ok {
+		panic("pagination: sort spec has no id field")
+	}
+
+	var orders []order[T]
+	var given []string
+	seen := map[string]bool{}
+	for _, part := range strings.Split(by, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name := strings.TrimPrefix(part, "-")
+		f, ok := fields[name]
+		if !ok {
+			return nil, "", fmt.Errorf("cannot sort by %q, use %s", name, strings.Join(names, ", "))
+		}
+		if seen[name] {
+			return nil, "", fmt.Errorf("sort lists %q twice", name)
+		}
+		seen[name] = true
+		orders = append(orders, order[T]{field: f, desc: part != name})
+		given = append(given, part)
+	}
+	if !seen["id"] {
+		// Ties go the way of the first field, so "-date" lists the newest first
+		desc := len(orders) > 0 && orders[0].desc
+		orders = append(orders, order[T]{field: id, desc: desc})
+	}
+	return orders, strings.Join(given, ","), nil
+}
+
+// Find reads the page r asks for of the rows query selects
+func Find[T any](query *gorm.DB, r Request[T]) ([]T, Page, error) {
+	page := r.page()
+	if r.Total {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, page, err
+		}
+		page.Total = &total
+	}
+
+	if len(r.orders) > 0 {
+		columns := make([]string, len(r.orders))
+		for i, o := range r.orders {
+			columns[i] = o.field.Column
+			if o.desc {
+				columns[i] += " DESC"
+			}
+		}
+		query = query.Order(strings.Join(columns, ", "))
+	}
+	if r.after != nil {
+		condition, args := r.keyset()
+		query = query.Where(condition, args...)
+	}
+	if r.Offset > 0 {
+		query = query.Offset(r.Offset)
+	}
+	if r.Limit > 0 {
+		query = query.Limit(r.Limit + 1) // One more tells whether another page follows
+	}
+
+	var rows []T
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, page, err
+	}
+	return r.cut(rows, &page), page, nil
+}
+
+// Slice is Find for rows already in memory
+func Slice[T any](rows []T, r Request[T]) ([]T, Page) {
+	page := r.page()
+	if r.Total {
+		total := int64(len(rows))
+		page.Total = &total
+	}
+
+	sorted := make([]T, 0, len(rows))
+	for _, row := range rows {
+		if r.after == nil || r.compare(r.values(row), r.after) > 0 {
+			sorted = append(sorted, row)
+		}
+	}
+	if len(r.orders) > 0 {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return r.compare(r.values(sorted[i]), r.values(sorted[j])) < 0
+		})
+	}
+
+	if r.Offset >= len(sorted) {
+		sorted = sorted[:0]
+	} else {
+		sorted = sorted[r.Offset:]
+	}
+	if r.Limit > 0 && len(sorted) > r.Limit+1 {
+		sorted = sorted[:r.Limit+1]
+	}
+	return r.cut(sorted, &page), page
+}
+
+func (r Request[T]) page() Page {
+	return Page{Limit: r.Limit, Offset: r.Offset, Sort: r.sort}
+}
+
+// cut drops the extra row fetched past the limit and points the next cursor
+// at the last row kept
+func (r Request[T]) cut(rows []T, page *Page) []T {
+	if r.Limit == 0 || len(rows) <= r.Limit {
+		return rows
+	}
+	rows = rows[:r.Limit]
+	page.NextCursor = encode(r.sort, r.values(rows[len(rows)-1]))
+	return rows
+}
+
+func (r Request[T]) values(row T) []any {
+	values := make([]any, len(r.orders))
+	for i, o := range r.orders {
+		values[i] = o.field.Value(row)
+	}
+	return values
+}
+
+// keyset is the condition for rows after r.after in the sort order:
+// a > ? OR (a = ? AND b > ?) OR ..., with < for descending fields
+func (r Request[T]) keyset() (string, []any) {
+	var terms []string
+	var args []any
+	for i, o := range r.orders {
+		var and []string
+		for j := 0; j < i; j++ {
+			and = append(and, r.orders[j].field.Column+" = ?")
+			args = append(args, r.after[j])
+		}
+		op := " > ?"
+		if o.desc {
+			op = " < ?"
+		}
+		and = append(and, o.field.Column+op)
+		args = append(args, r.after[i])
+		terms = append(terms, "("+strings.Join(and, " AND ")+")")
+	}
+	return strings.Join(terms, " OR "), args
+}
+
+// compare orders two rows' sort values as the sort does
+func (r Request[T]) compare(a, b []any) int {
+	for i, o := range r.orders {
+		c := compareValues(a[i], b[i])
+		if o.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareValues orders two sort values of the same field. Numbers may differ
+// in type, as a row's uint ID does from the int64 decoded from a cursor.
+func compareValues(a, b any) int {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			return cmp(x, y)
+		}
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok && x != y {
+			if y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func cmp(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+// Times are written as {"t": "..."} so they come back as times, not strings
+type timeValue struct {
+	T time.Time `json:"t"`
+}
+
+func encode(sort string, values []any) string {
+	c := cursor{Sort: sort, Values: make([]any, len(values))}
+	for i, v := range values {
+		if t, ok := v.(time.Time); ok {
+			v = timeValue{T: t}
+		}
+		c.Values[i] = v
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(s, sort string, n int) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber() // IDs must stay integers
+	if err := d.Decode(&c); err != nil || len(c.Values) != n {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sort {
+		return nil, ErrCursorSort
+	}
+
+	for i, v := range c.Values {
+		switch v := v.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				c.Values[i] = n
+			} else if f, err := v.Float64(); err == nil {
+				c.Values[i] = f
+			} else {
+				return nil, ErrInvalidCursor
+			}
+		case map[string]any:
+			s, _ := v["t"].(string)
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil || len(v) != 1 {
+				return nil, ErrInvalidCursor
+			}
+			c.Values[i] = t
+		case string, bool:
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return c.Values, nil
+}
@@ -0,0 +1,112 @@
+package pagination
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type row struct {
+	ID   uint
+	Name string
+	At   time.Time
+}
+
+var spec = Spec[row]{
+	Fields: []Field[row]{
+		{Name: "id", Column: "id", Value: func(r row) any { return r.ID }},
+		{Name: "name", Column: "name", Value: func(r row) any { return r.Name }},
+		{Name: "at", Column: "at", Value: func(r row) any { return r.At }},
+	},
+	Default: "name",
+}
+
+func parse(t *testing.T, query string) (Request[row], error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/?"+query, nil)
+	return Parse(c, spec)
+}
+
+func TestParse(t *testing.T) {
+	r, err := parse(t, "")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultLimit, r.Limit)
+	assert.Equal(t, "name", r.sort)
+	assert.True(t, r.First())
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"limit=0", "limit must be between 1 and 100"},
+		{"limit=101", "limit must be between 1 and 100"},
+		{"offset=-1", "offset must be a number of rows"},
+		{"offset=2&cursor=abc", "use either cursor or offset, not both"},
+		{"sort=email", `cannot sort by "email", use id, name, at`},
+		{"sort=name,-name", `sort lists "name" twice`},
+		{"cursor=!!", ErrInvalidCursor.Error()},
+		{"cursor=" + encode("-name", []any{"b", 1}), ErrCursorSort.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := parse(t, tt.query)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	r, err := parse(t, "sort=-at,name&cursor="+encode("-at,name", []any{time.Unix(100, 0).UTC(), "b", 3}))
+	assert.NoError(t, err)
+	assert.False(t, r.First())
+
+	condition, args := r.keyset()
+	assert.Equal(t, "(at < ?) OR (at = ? AND name > ?) OR (at = ? AND name = ? AND id < ?)", condition)
+	at := time.Unix(100, 0).UTC()
+	assert.Equal(t, []any{at, at, "b", at, "b", int64(3)}, args)
+}
+
+func TestSlice(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := []row{
+		{ID: 1, Name: "c", At: base},
+		{ID: 2, Name: "a", At: base.Add(time.Hour)},
+		{ID: 3, Name: "b", At: base},
+		{ID: 4, Name: "a", At: base.Add(2 * time.Hour)},
+	}
+
+	// Following the cursors visits every row once, ties broken by ID
+	var ids []uint
+	query := "limit=3&total=true&sort=-at"
+	for pages := 0; query != "" && pages < len(rows); pages++ {
+		r, err := parse(t, query)
+		assert.NoError(t, err)
+		page, p := Slice(rows, r)
+		assert.Equal(t, int64(len(rows)), *p.Total)
+		for _, row := range page {
+			ids = append(ids, row.ID)
+		}
+		query = ""
+		if p.NextCursor != "" {
+			query = "limit=3&total=true&sort=-at&cursor=" + p.NextCursor
+		}
+	}
+	assert.Equal(t, []uint{4, 2, 3, 1}, ids)
+
+	r, err := parse(t, "sort=name&offset=1&limit=2")
+	assert.NoError(t, err)
+	page, p := Slice(rows, r)
+	assert.Equal(t, []row{rows[3], rows[2]}, page)
+	assert.NotEmpty(t, p.NextCursor)
+	assert.Nil(t, p.Total)
+
+	// The zero Request keeps every row as it is
+	page, p = Slice(rows, Request[row]{})
+	assert.Equal(t, rows, page)
+	assert.Empty(t, p.NextCursor)
+}
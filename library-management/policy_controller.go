@@ -0,0 +1,93 @@
+// 📏 Circulation Policies
+package controllers
+
+import (
+	"library-management/audit"
+	"library-management/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPolicy shows the circulation rules in force for a library
+func GetPolicy(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, ok := libraryForPolicy(c, libraries)
+		if !ok {
+			return
+		}
+
+		policy, err := circ.Policy(c.Request.Context(), libraryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load circulation policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"policy": policy, "default": policy.ID == 0})
+	}
+}
+
+// UpdatePolicy sets a library's circulation rules; omitted fields keep their current value
+func UpdatePolicy(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryID, ok := libraryForPolicy(c, libraries)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			LoanPeriodDays  *int   `json:"loan_period_days" binding:"omitempty,gt=0"`
+			MaxLoans        *int   `json:"max_loans" binding:"omitempty,gte=0"`
+			MaxRenewals     *int   `json:"max_renewals" binding:"omitempty,gte=0"`
+			HoldPickupDays  *int   `json:"hold_pickup_days" binding:"omitempty,gt=0"`
+			FinePerDayCents *int64 `json:"fine_per_day_cents" binding:"omitempty,gte=0"`
+			MaxFineCents    *int64 `json:"max_fine_cents" binding:"omitempty,gte=0"`
+			FineGraceDays   *int   `json:"fine_grace_days" binding:"omitempty,gte=0"`
+			MaxBalanceCents *int64 `json:"max_balance_cents" binding:"omitempty,gte=0"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		policy, err := circ.UpdatePolicy(audit.Context(c), libraryID, services.PolicyUpdate(input))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save circulation policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Circulation policy updated", "policy": policy})
+	}
+}
+
+// libraryForPolicy resolves the :id library and checks the caller may manage it:
+// owners manage every library, admins only their assigned ones
+func libraryForPolicy(c *gin.Context, libraries services.LibraryService) (uint, bool) {
+	userID, exists := c.Get("userID")
+	userRole, roleExists := c.Get("userRole")
+	if !exists || !roleExists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return 0, false
+	}
+
+	library, err := libraries.GetLibrary(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+		return 0, false
+	}
+
+	if userRole != "owner" {
+		if member, err := libraries.IsMember(c.Request.Context(), userID.(uint), library.ID); err != nil || !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage the policy of your assigned library"})
+			return 0, false
+		}
+	}
+	return library.ID, true
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"bytes"
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/services"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestCirculationPolicy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	circ := circulation.NewService(gormDB, config.Default().Circulation)
+	router := func(userID uint, role string) *gin.Engine {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set("userID", userID)
+			c.Set("userRole", role)
+		})
+		r.GET("/library/:id/policy", GetPolicy(services.NewCirculationService(gormDB, circ, nil), services.NewLibraryService(gormDB)))
+		r.PUT("/library/:id/policy", UpdatePolicy(services.NewCirculationService(gormDB, circ, nil), services.NewLibraryService(gormDB)))
+		return r
+	}
+
+	libraryQuery := regexp.QuoteMeta(`SELECT * FROM "libraries" WHERE "libraries"."id" = $1`)
+	policyQuery := regexp.QuoteMeta(`SELECT * FROM "circulation_policies" WHERE library_id = $1`)
+
+	t.Run("Library without a policy uses the defaults", func(t *testing.T) {
+		mock.ExpectQuery(libraryQuery).WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Central"))
+		mock.ExpectQuery(policyQuery).WithArgs(1, 1).WillReturnError(gorm.ErrRecordNotFound)
+
+		w := httptest.NewRecorder()
+		router(1, "owner").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/library/1/policy", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"default":true`)
+		assert.Contains(t, w.Body.String(), `"loan_period_days":14`)
+	})
+
+	t.Run("Admin creates a policy for their library", func(t *testing.T) {
+		mock.ExpectQuery(libraryQuery).WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Central"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(policyQuery).WithArgs(1, 1).WillReturnError(gorm.ErrRecordNotFound)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "circulation_policies"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, 7, 5, 2, 3, 25, 1000, 1, 500).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		expectAudit(mock, "policy.update", "library", 1)
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPut, "/library/1/policy", bytes.NewBufferString(`{"loan_period_days": 7}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router(2, "admin").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"loan_period_days":7`)
+	})
+
+	t.Run("Admin from another library", func(t *testing.T) {
+		mock.ExpectQuery(libraryQuery).WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Central"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		req := httptest.NewRequest(http.MethodPut, "/library/1/policy", bytes.NewBufferString(`{"max_loans": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router(2, "admin").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Loan period must be positive", func(t *testing.T) {
+		mock.ExpectQuery(libraryQuery).WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Central"))
+
+		req := httptest.NewRequest(http.MethodPut, "/library/1/policy", bytes.NewBufferString(`{"loan_period_days": 0}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router(1, "owner").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,119 @@
+// 🔁 Loan Renewals
+package controllers
+
+import (
+	"library-management/audit"
+	"library-management/circulation"
+	"library-management/models"
+	"library-management/pagination"
+	"library-management/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RenewLoan extends a loan's due date; readers renew their own loans, admins any loan in their library
+func RenewLoan(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		loan, ok := loadLoanForCaller(c, circ, libraries)
+		if !ok {
+			return
+		}
+
+		policy, err := circ.Policy(c.Request.Context(), loan.LibraryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load circulation policy"})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		renewed, err := circ.RenewLoan(audit.Context(c), loan.ID, userID.(uint))
+		if err != nil {
+			respondCirculationError(c, err, "Could not renew loan")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":              "Loan renewed",
+			"expected_return_date": formatUnixTime(&renewed.ExpectedReturnDate),
+			"renewal_count":        renewed.RenewalCount,
+			"renewals_left":        circulation.RenewalsLeft(policy, renewed),
+		})
+	}
+}
+
+// ListRenewals pages through the due-date history of a loan, oldest first
+func ListRenewals(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		loan, ok := loadLoanForCaller(c, circ, libraries)
+		if !ok {
+			return
+		}
+
+		req, err := pagination.Parse(c, services.RenewalSort)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		renewals, page, err := circ.ListRenewals(c.Request.Context(), loan.ID, req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch renewals"})
+			return
+		}
+
+		formattedRenewals := make([]gin.H, len(renewals))
+		for i, renewal := range renewals {
+			formattedRenewals[i] = gin.H{
+				"renewed_at":        formatUnixTime(&renewal.RenewedAt),
+				"renewed_by_id":     renewal.RenewedByID,
+				"previous_due_date": formatUnixTime(&renewal.PreviousDueDate),
+				"new_due_date":      formatUnixTime(&renewal.NewDueDate),
+			}
+		}
+		reply := pagination.Envelope(formattedRenewals, page)
+		reply["issue_id"] = loan.ID
+		c.JSON(http.StatusOK, reply)
+	}
+}
+
+// loadLoanForCaller fetches the loan in the :id param if the caller may see it,
+// writing the error response otherwise
+func loadLoanForCaller(c *gin.Context, circ services.CirculationService, libraries services.LibraryService) (*models.IssueRegistry, bool) {
+	userID, exists := c.Get("userID")
+	userRole, roleExists := c.Get("userRole")
+	if !exists || !roleExists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Loan not found"})
+		return nil, false
+	}
+	loan, err := circ.GetLoan(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Loan not found"})
+		return nil, false
+	}
+
+	switch userRole {
+	case "user":
+		// Other readers' loans are reported as missing rather than forbidden
+		if loan.ReaderID != userID.(uint) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Loan not found"})
+			return nil, false
+		}
+	case "admin":
+		if member, err := libraries.IsMember(c.Request.Context(), userID.(uint), loan.LibraryID); err != nil || !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage loans in your assigned library"})
+			return nil, false
+		}
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return loan, true
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/services"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestRenewLoan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	circ := circulation.NewService(gormDB, config.Default().Circulation)
+	router := func(userID uint, role string) *gin.Engine {
+		r := gin.New()
+		r.POST("/loans/:id/renew", func(c *gin.Context) {
+			c.Set("userID", userID)
+			c.Set("userRole", role)
+			RenewLoan(services.NewCirculationService(gormDB, circ, nil), services.NewLibraryService(gormDB))(c)
+		})
+		return r
+	}
+
+	loanQuery := regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE "issue_registries"."id" = $1`)
+	policyQuery := regexp.QuoteMeta(`SELECT * FROM "circulation_policies" WHERE library_id = $1`)
+	loanRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id", "issue_status", "expected_return_date", "renewal_count"}).
+			AddRow(7, "123456789", 1, 2, "issued", time.Now().AddDate(0, 0, 7).Unix(), 0)
+	}
+
+	t.Run("Reader cannot renew another reader's loan", func(t *testing.T) {
+		mock.ExpectQuery(loanQuery).WithArgs(7, 1).WillReturnRows(loanRows())
+
+		w := httptest.NewRecorder()
+		router(3, "user").ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/loans/7/renew", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Admin from another library", func(t *testing.T) {
+		mock.ExpectQuery(loanQuery).WithArgs(7, 1).WillReturnRows(loanRows())
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		w := httptest.NewRecorder()
+		router(5, "admin").ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/loans/7/renew", nil))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Reader renews their loan", func(t *testing.T) {
+		mock.ExpectQuery(loanQuery).WithArgs(7, 1).WillReturnRows(loanRows())
+		mock.ExpectQuery(policyQuery).WithArgs(1, 1).WillReturnError(gorm.ErrRecordNotFound)
+		mock.ExpectBegin()
+		mock.ExpectQuery(loanQuery).WithArgs(7, 1).WillReturnRows(loanRows())
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2)`)).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(3, "123456789", 1))
+		mock.ExpectQuery(loanQuery).WithArgs(7, 7, 1).WillReturnRows(loanRows())
+		mock.ExpectQuery(policyQuery).WithArgs(1, 1).WillReturnError(gorm.ErrRecordNotFound)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "holds"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "loan_renewals"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "issue_registries" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, "loan.renew", "loan", 7)
+		mock.ExpectCommit()
+
+		w := httptest.NewRecorder()
+		router(2, "user").ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/loans/7/renew", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"renewals_left":1`)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
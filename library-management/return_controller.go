@@ -0,0 +1,151 @@
+// 📚 Book Returns
+package controllers
+
+import (
+	"errors"
+	"library-management/audit"
+	"library-management/circulation"
+	"library-management/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestReturn allows users to ask for a borrowed book to be checked back in
+func RequestReturn(circ services.CirculationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			BookID    string `json:"isbn" binding:"required"`
+			LibraryID uint   `json:"libraryid" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		// The reader must currently hold this book
+		request, err := circ.RequestReturn(audit.Context(c), userID.(uint), input.BookID, input.LibraryID)
+		if errors.Is(err, circulation.ErrNoActiveLoan) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No active loan for this book in this library"})
+			return
+		}
+		if errors.Is(err, services.ErrDuplicateRequest) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending return request for this book"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create return request"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Return request submitted", "request": request})
+	}
+}
+
+// ApproveReturn allows an admin to receive a returned book and restock it
+func ApproveReturn(circ services.CirculationService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		requestID, err := strconv.ParseUint(c.Param("id"), 10, 0)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Return request not found"})
+			return
+		}
+		request, err := circ.GetRequest(c.Request.Context(), uint(requestID))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Return request not found"})
+			return
+		}
+
+		if request.RequestType != "return" || request.IssueID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Not a return request"})
+			return
+		}
+
+		if member, err := libraries.IsMember(c.Request.Context(), adminID.(uint), request.LibraryID); err != nil || !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only receive returns for your assigned library"})
+			return
+		}
+
+		var input struct {
+			Condition string `json:"condition"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+				return
+			}
+		}
+
+		_, fine, err := circ.ApproveReturn(audit.Context(c), request.ID, adminID.(uint), input.Condition)
+		if err != nil {
+			respondCirculationError(c, err, "Could not process return")
+			return
+		}
+
+		response := gin.H{"message": "Book returned successfully"}
+		if fine != nil {
+			response["fine"] = fine
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// ReturnCopy lets an admin check in a copy by scanning its barcode at the desk
+func ReturnCopy(circ services.CirculationService, books services.BookService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		barcode := c.Param("barcode")
+
+		adminID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		var input struct {
+			Condition string `json:"condition"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+				return
+			}
+		}
+
+		_, book, err := books.GetCopy(c.Request.Context(), barcode)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Copy not found"})
+			return
+		}
+
+		if member, err := libraries.IsMember(c.Request.Context(), adminID.(uint), book.LibraryID); err != nil || !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only receive returns for your assigned library"})
+			return
+		}
+
+		issue, fine, err := circ.ReturnCopy(audit.Context(c), barcode, adminID.(uint), input.Condition)
+		if err != nil {
+			respondCirculationError(c, err, "Could not process return")
+			return
+		}
+
+		response := gin.H{"message": "Book returned successfully", "issue": issue}
+		if fine != nil {
+			response["fine"] = fine
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"bytes"
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/services"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestRequestReturn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/return", func(c *gin.Context) {
+		c.Set("userID", uint(2))
+		RequestReturn(services.NewCirculationService(gormDB, nil, nil))(c)
+	})
+
+	loanQuery := regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE (reader_id = $1 AND isbn = $2 AND library_id = $3 AND issue_status = $4)`)
+	pendingQuery := regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE (issue_id = $1 AND request_type = $2 AND status = $3)`)
+
+	tests := []struct {
+		name           string
+		input          string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:  "Successful return request",
+			input: `{"isbn": "123456789", "libraryid": 1}`,
+			mockSetup: func() {
+				mock.ExpectQuery(loanQuery).
+					WithArgs(2, "123456789", 1, "issued", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id"}).AddRow(5, "123456789", 1, 2))
+				mock.ExpectQuery(pendingQuery).
+					WithArgs(5, "return", "pending", 1).
+					WillReturnError(gorm.ErrRecordNotFound)
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "request_events"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+				expectAudit(mock, "request.create", "request", 9)
+				mock.ExpectCommit()
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   "Return request submitted",
+		},
+		{
+			name:  "No active loan",
+			input: `{"isbn": "123456789", "libraryid": 1}`,
+			mockSetup: func() {
+				mock.ExpectQuery(loanQuery).
+					WithArgs(2, "123456789", 1, "issued", 1).
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "No active loan",
+		},
+		{
+			name:  "Duplicate return request",
+			input: `{"isbn": "123456789", "libraryid": 1}`,
+			mockSetup: func() {
+				mock.ExpectQuery(loanQuery).
+					WithArgs(2, "123456789", 1, "issued", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id"}).AddRow(5, "123456789", 1, 2))
+				mock.ExpectQuery(pendingQuery).
+					WithArgs(5, "return", "pending", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "pending return request",
+		},
+		{
+			name:           "Missing fields",
+			input:          `{"isbn": "123456789"}`,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Field validation for 'LibraryID' failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodPost, "/return", bytes.NewBufferString(tt.input))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApproveReturn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/return/approve/:id", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		ApproveReturn(services.NewCirculationService(gormDB, circulation.NewService(gormDB, config.Default().Circulation), nil), services.NewLibraryService(gormDB))(c)
+	})
+
+	requestQuery := regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE "request_events"."id" = $1`)
+	requestColumns := []string{"id", "book_id", "library_id", "reader_id", "request_type", "request_date", "approval_date", "approver_id", "issue_id", "status"}
+
+	t.Run("Successful return restocks the book", func(t *testing.T) {
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "return", time.Now().Unix(), nil, nil, 5, "pending"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE "request_events"."id" = $1 AND "request_events"."deleted_at" IS NULL ORDER BY "request_events"."id" LIMIT $2 FOR UPDATE`)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "return", time.Now().Unix(), nil, nil, 5, "pending"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE "issue_registries"."id" = $1 AND "issue_registries"."deleted_at" IS NULL ORDER BY "issue_registries"."id" LIMIT $2 FOR UPDATE`)).
+			WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id", "issue_status"}).AddRow(5, "123456789", 1, 2, "issued"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL ORDER BY "books"."id" LIMIT $3 FOR UPDATE`)).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "total_copies", "available_copies"}).AddRow(3, "123456789", 1, 2, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "issue_registries" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, "loan.return", "loan", 5)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET "status"=$1`)).
+			WithArgs("fulfilled", sqlmock.AnyArg(), 5, "issue", "approved").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "available_copies"=available_copies + 1,"updated_at"=$1 WHERE available_copies < total_copies`)).
+			WithArgs(sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, "request.approve", "request", 1)
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPut, "/return/approve/1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Book returned successfully")
+	})
+
+	t.Run("Issue request is rejected", func(t *testing.T) {
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "issue", time.Now().Unix(), nil, nil, nil, "pending"))
+
+		req := httptest.NewRequest(http.MethodPut, "/return/approve/1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Not a return request")
+	})
+
+	t.Run("Admin from another library", func(t *testing.T) {
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "return", time.Now().Unix(), nil, nil, 5, "pending"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		req := httptest.NewRequest(http.MethodPut, "/return/approve/1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Loan already returned rolls back", func(t *testing.T) {
+		mock.ExpectQuery(requestQuery).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "return", time.Now().Unix(), nil, nil, 5, "pending"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE "request_events"."id" = $1 AND "request_events"."deleted_at" IS NULL ORDER BY "request_events"."id" LIMIT $2 FOR UPDATE`)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(requestColumns).AddRow(1, "123456789", 1, 2, "return", time.Now().Unix(), nil, nil, 5, "pending"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE "issue_registries"."id" = $1 AND "issue_registries"."deleted_at" IS NULL ORDER BY "issue_registries"."id" LIMIT $2 FOR UPDATE`)).
+			WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id", "issue_status"}).AddRow(5, "123456789", 1, 2, "returned"))
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest(http.MethodPut, "/return/approve/1", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
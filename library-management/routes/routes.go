@@ -1,26 +1,55 @@
 package routes
 
 import (
+	"library-management/circulation"
+	"library-management/config"
 	controllers "library-management/controllers"
 	"library-management/middleware"
+	"library-management/notify"
+	"library-management/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupRouter(db *gorm.DB) *gin.Engine {
+func SetupRouter(cfg *config.Config, db *gorm.DB) *gin.Engine {
 	r := gin.Default()
+	_ = r.SetTrustedProxies(cfg.Server.TrustedProxies) // Validated by config.Load; none trusts no one
+	r.Use(middleware.CORS(cfg.Server.CORSOrigins))
+	r.Use(middleware.RequestID())
+
+	// Issues and returns share one transactional service, which notifies readers through the outbox
+	notifier := notify.FromConfig(db, cfg.Notify)
+	circ := circulation.NewService(db, cfg.Circulation).WithNotifier(notifier)
+
+	// Domain services the catalogue, account and request handlers depend on
+	libraries := services.NewLibraryService(db)
+	books := services.NewBookService(db, circ, cfg.Search)
+	users := services.NewUserService(db)
+	requests := services.NewCirculationService(db, circ, notifier)
+	sessions := services.NewAuthService(db)
+	notifications := services.NewNotificationService(db, notifier)
+	auditLog := services.NewAuditService(db)
+	jobs := services.NewJobService(db)
+
+	// Reject access tokens revoked by logout
+	middleware.SetRevocationChecker(controllers.IsTokenRevoked(sessions))
+
+	// Public key set for offline token verification
+	r.GET("/.well-known/jwks.json", controllers.JWKS())
 
 	// Public routes (No authentication required)
 	auth := r.Group("/auth")
 	{
-		auth.POST("/login", controllers.Login(db))
+		auth.POST("/login", controllers.Login(sessions))
+		auth.POST("/refresh", controllers.RefreshToken(sessions))
+		auth.POST("/logout", middleware.AuthMiddleware(""), controllers.Logout(sessions)) // Any authenticated role
 	}
 
 	// Protected API routes (Require authentication)
 	api := r.Group("/api")
 	{
-		r.GET("/libraries", controllers.ListLibraries(db))
+		r.GET("/libraries", controllers.ListLibraries(libraries))
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(200, gin.H{"message": "API is running"})
 		})
@@ -28,38 +57,93 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		// Owner-Only Routes
 		ownerRoutes := api.Group("", middleware.AuthMiddleware("owner"))
 		{
-			ownerRoutes.POST("/library", controllers.CreateLibrary(db))  // Owner can create a library
-			ownerRoutes.POST("/admin", controllers.RegisterAdmin(db))    // Owner can create Admins
-			ownerRoutes.POST("/owner", controllers.RegisterOwnerNew(db)) // Owner can create a new Owner
+			ownerRoutes.POST("/library", controllers.CreateLibrary(libraries))                   // Owner can create a library
+			ownerRoutes.POST("/admin", controllers.RegisterAdmin(users))                         // Owner can create Admins
+			ownerRoutes.POST("/owner", controllers.RegisterOwnerNew(users))                      // Owner can create a new Owner
+			ownerRoutes.GET("/jobs/runs", controllers.ListJobRuns(jobs))                         // Owner can review background job runs
+			ownerRoutes.GET("/audit", controllers.ListAuditEvents(auditLog))                     // Owner can review who changed what, ?actor_id= ?entity_type= ?entity_id= ?from= ?to=
+			ownerRoutes.POST("/users/:id/revoke-tokens", controllers.RevokeUserTokens(sessions)) // Owner can sign a user out everywhere
+		}
+
+		// Routes for owners and admins alike
+		staffRoutes := api.Group("", middleware.AuthMiddleware("owner|admin"))
+		{
+			staffRoutes.GET("/library/:id/policy", controllers.GetPolicy(requests, libraries))    // Circulation rules in force for a library
+			staffRoutes.PUT("/library/:id/policy", controllers.UpdatePolicy(requests, libraries)) // Owners set any library's rules, admins their own library's
 		}
 
 		// Admin-Only Routes
 		adminRoutes := api.Group("", middleware.AuthMiddleware("admin"))
 		{
-			adminRoutes.POST("/user", controllers.RegisterUser(db))
+			adminRoutes.POST("/user", controllers.RegisterUser(users, libraries))
 
 			// Book Management
-			adminRoutes.POST("/book", controllers.AddBook(db))            // Admin can add books
-			adminRoutes.PUT("/book/:isbn", controllers.UpdateBook(db))    // Admin can update book details (copies, title, etc.)
-			adminRoutes.DELETE("/book/:isbn", controllers.RemoveBook(db)) // Admin can remove books
+			adminRoutes.POST("/book", controllers.AddBook(books, libraries))            // Admin can add books
+			adminRoutes.PUT("/book/:isbn", controllers.UpdateBook(books, libraries))    // Admin can update book details (copies, title, etc.)
+			adminRoutes.DELETE("/book/:isbn", controllers.RemoveBook(books, libraries)) // Admin can remove books
+
+			// Copy Inventory
+			adminRoutes.POST("/book/:isbn/copies", controllers.AddCopy(books, libraries))   // Admin can add a barcoded copy
+			adminRoutes.GET("/book/:isbn/copies", controllers.ListCopies(books, libraries)) // Admin can list copies of a book
+			adminRoutes.PUT("/copy/:barcode", controllers.UpdateCopy(books, libraries))     // Admin can mark a copy damaged, lost or withdrawn
 
 			// Issue Request Management
-			adminRoutes.GET("/issues", controllers.ListIssueRequests(db))             // Admin can list issue requests
-			adminRoutes.PUT("/issue/approve/:id", controllers.ApproveIssue(db))       // Admin can approve issue requests
-			adminRoutes.PUT("/issue/disapprove/:id", controllers.DisapproveIssue(db)) // Admin can disapprove issue requests
+			adminRoutes.GET("/issues", controllers.ListIssueRequests(requests, libraries))             // Admin can list issue requests
+			adminRoutes.PUT("/issue/approve/:id", controllers.ApproveIssue(requests, libraries))       // Admin can approve issue requests
+			adminRoutes.PUT("/issue/disapprove/:id", controllers.DisapproveIssue(requests, libraries)) // Admin can disapprove issue requests
+
+			// Return Management
+			adminRoutes.PUT("/return/approve/:id", controllers.ApproveReturn(requests, libraries))        // Admin receives a returned book
+			adminRoutes.POST("/return/copy/:barcode", controllers.ReturnCopy(requests, books, libraries)) // Admin checks in a scanned copy
+
+			// Fines
+			adminRoutes.POST("/readers/:id/payments", controllers.RecordPayment(requests, libraries)) // Admin records a reader's payment
+			adminRoutes.POST("/fines/:id/waive", controllers.WaiveFine(requests, libraries))          // Admin waives a fine with a reason
 
 			// Issue Books to Users
-			adminRoutes.POST("/issue/book/:isbn", controllers.IssueBookToUser(db)) // Admin can issue books to a reader
+			adminRoutes.POST("/issue/book/:isbn", controllers.IssueBookToUser(requests, libraries)) // Admin can issue books to a reader
 		}
 
 		// User-Only Routes
 		userRoutes := api.Group("", middleware.AuthMiddleware("user"))
 		{
 			// Book Search
-			userRoutes.GET("/books/search", controllers.SearchBooks(db)) // Users can search books by title, author, publisher
+			userRoutes.GET("/books/search", controllers.SearchBooks(books, libraries))   // Users can search books by title, author, publisher, or q for all three; fuzzy=true forgives typos
+			userRoutes.GET("/books/suggest", controllers.SuggestBooks(books, libraries)) // Users get title and author completions for ?prefix=
 
 			// Request a Book
-			userRoutes.POST("/issue", controllers.RequestIssue(db)) // Users can request book issues
+			userRoutes.POST("/issue", controllers.RequestIssue(requests)) // Users can request book issues
+
+			// Holds
+			userRoutes.POST("/holds", controllers.PlaceHold(requests, libraries)) // Users can queue for an unavailable book
+			userRoutes.GET("/holds", controllers.ListHolds(requests))             // Users can list their holds
+			userRoutes.DELETE("/holds/:id", controllers.CancelHold(requests))     // Users can cancel a hold
+
+			// Return a Book
+			userRoutes.POST("/return", controllers.RequestReturn(requests)) // Users can request to return a borrowed book
+
+			// Fines
+			userRoutes.GET("/fines", controllers.ListFines(requests)) // Users can see their balance and fines
+		}
+
+		// Routes for readers and admins alike
+		loanRoutes := api.Group("", middleware.AuthMiddleware("admin|user"))
+		{
+			loanRoutes.POST("/loans/:id/renew", controllers.RenewLoan(requests, libraries))      // Readers renew their loans, admins any loan in their library
+			loanRoutes.GET("/loans/:id/renewals", controllers.ListRenewals(requests, libraries)) // Due-date history of a loan
+		}
+
+		// The signed-in user's own data, for any role
+		meRoutes := api.Group("/me", middleware.AuthMiddleware(""))
+		{
+			meRoutes.GET("/requests", controllers.ListMyRequests(requests))                                     // Own requests, ?status= and ?type= filter
+			meRoutes.DELETE("/requests/:id", controllers.CancelMyRequest(requests))                             // Withdraw a pending request
+			meRoutes.GET("/loans", controllers.ListMyLoans(requests))                                           // Current and past loans, ?status=current|past
+			meRoutes.GET("/notifications", controllers.ListNotifications(notifications))                        // Inbox, ?unread=true for unread only
+			meRoutes.POST("/notifications/:id/read", controllers.MarkNotificationRead(notifications))           // Mark one notification read
+			meRoutes.POST("/notifications/read-all", controllers.MarkAllNotificationsRead(notifications))       // Mark the whole inbox read
+			meRoutes.GET("/notification-preferences", controllers.GetNotificationPreferences(notifications))    // Channels the user is notified on
+			meRoutes.PUT("/notification-preferences", controllers.UpdateNotificationPreferences(notifications)) // Turn channels on or off
 		}
 	}
 
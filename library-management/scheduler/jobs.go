@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	"library-management/circulation"
+	"library-management/models"
+	"library-management/notify"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CirculationJobs returns the circulation housekeeping jobs, each run every interval
+func CirculationJobs(circ *circulation.Service, every time.Duration) []Job {
+	return []Job{
+		{
+			Name:  "mark-overdue",
+			Every: every,
+			Run:   func(context.Context) (int, error) { return circ.MarkOverdue(time.Now()) },
+		},
+		{
+			Name:  "accrue-fines",
+			Every: every,
+			Run:   func(context.Context) (int, error) { return circ.AccrueFines(time.Now()) },
+		},
+		{
+			Name:  "expire-holds",
+			Every: every,
+			Run:   func(context.Context) (int, error) { return circ.ExpireHolds(time.Now()) },
+		},
+		{
+			Name:  "expire-requests",
+			Every: every,
+			Run:   func(context.Context) (int, error) { return circ.ExpireRequests(time.Now()) },
+		},
+		{
+			Name:  "remind-due",
+			Every: every,
+			Run:   func(context.Context) (int, error) { return circ.RemindDue(time.Now()) },
+		},
+	}
+}
+
+// NotificationJobs returns the job that drains the notification outbox every interval
+func NotificationJobs(notifier *notify.Notifier, every time.Duration) []Job {
+	return []Job{
+		{
+			Name:  "deliver-notifications",
+			Every: every,
+			Run:   func(ctx context.Context) (int, error) { return notifier.Deliver(ctx, time.Now()) },
+		},
+	}
+}
+
+// TokenJobs returns the job that deletes revoked_tokens rows once the access
+// tokens they block have expired anyway
+func TokenJobs(db *gorm.DB, every time.Duration) []Job {
+	return []Job{
+		{
+			Name:  "purge-revoked-tokens",
+			Every: every,
+			Run: func(ctx context.Context) (int, error) {
+				result := db.WithContext(ctx).Where("expires_at < ?", time.Now().Unix()).Delete(&models.RevokedToken{})
+				return int(result.RowsAffected), result.Error
+			},
+		},
+	}
+}
@@ -0,0 +1,107 @@
+// Package scheduler runs background jobs on a fixed interval inside the server
+// process. Each run takes a Postgres advisory lock named after the job, so when
+// several replicas are up only one of them runs a given job at a time. SQLite
+// serves a single process and needs no lock.
+package scheduler
+
+import (
+	"context"
+	"hash/fnv"
+	"library-management/models"
+	"library-management/storage"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Job is a named task run every Every; Run reports how many rows it changed
+type Job struct {
+	Name  string
+	Every time.Duration
+	Run   func(ctx context.Context) (int, error)
+}
+
+// Scheduler runs registered jobs until its context is cancelled
+type Scheduler struct {
+	db   *gorm.DB
+	jobs []Job
+	wg   sync.WaitGroup
+}
+
+// New returns a Scheduler that locks and records runs in db
+func New(db *gorm.DB) *Scheduler {
+	return &Scheduler{db: db}
+}
+
+// Add registers a job; it must be called before Start
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every job once and then on its interval until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+			ticker := time.NewTicker(job.Every)
+			defer ticker.Stop()
+			for {
+				if _, err := s.RunOnce(ctx, job); err != nil {
+					slog.Error("Job could not run", "job", job.Name, "error", err)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(job)
+	}
+}
+
+// Wait blocks until every job loop has stopped after the context passed to Start was cancelled
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// RunOnce runs a job if no other replica is running it and records the run.
+// It returns false when another replica holds the job's lock. Cancelling ctx
+// does not abort a run already in progress, so shutdown lets it finish.
+func (s *Scheduler) RunOnce(ctx context.Context, job Job) (bool, error) {
+	ran := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// The lock is transaction-scoped, so it is released once the run is recorded
+		locked, err := storage.TryLock(tx, lockKey(job.Name))
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		ran = true
+
+		run := models.JobRun{Job: job.Name, StartedAt: time.Now().Unix(), Status: models.JobSucceeded}
+		affected, err := job.Run(ctx)
+		run.FinishedAt = time.Now().Unix()
+		run.Affected = affected
+		if err != nil {
+			run.Status = models.JobFailed
+			run.Error = err.Error()
+			slog.Error("Job failed", "job", job.Name, "error", err)
+		} else if affected > 0 {
+			slog.Info("Job changed rows", "job", job.Name, "affected", affected)
+		}
+		return tx.Create(&run).Error
+	})
+	return ran, err
+}
+
+// lockKey maps a job name to the 64-bit key Postgres advisory locks take
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("lms-job:" + name))
+	return int64(h.Sum64())
+}
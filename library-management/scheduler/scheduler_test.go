@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/models"
+	"library-management/storage"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestScheduler(t *testing.T) (*Scheduler, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+	return New(gormDB), mock
+}
+
+func TestRunOnce(t *testing.T) {
+	lockQuery := regexp.QuoteMeta(`SELECT pg_try_advisory_xact_lock($1)`)
+
+	t.Run("Runs and records the job when the lock is free", func(t *testing.T) {
+		s, mock := newTestScheduler(t)
+		calls := 0
+		job := Job{Name: "expire-holds", Run: func(context.Context) (int, error) {
+			calls++
+			return 3, nil
+		}}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).
+			WithArgs(lockKey("expire-holds")).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(true))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "job_runs"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "expire-holds", sqlmock.AnyArg(), sqlmock.AnyArg(), "succeeded", 3, "").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		ran, err := s.RunOnce(context.Background(), job)
+		assert.NoError(t, err)
+		assert.True(t, ran)
+		assert.Equal(t, 1, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Failures are recorded", func(t *testing.T) {
+		s, mock := newTestScheduler(t)
+		job := Job{Name: "accrue-fines", Run: func(context.Context) (int, error) {
+			return 0, errors.New("connection reset")
+		}}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(true))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "job_runs"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "accrue-fines", sqlmock.AnyArg(), sqlmock.AnyArg(), "failed", 0, "connection reset").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectCommit()
+
+		ran, err := s.RunOnce(context.Background(), job)
+		assert.NoError(t, err)
+		assert.True(t, ran)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Skips the job while another replica holds the lock", func(t *testing.T) {
+		s, mock := newTestScheduler(t)
+		job := Job{Name: "mark-overdue", Run: func(context.Context) (int, error) {
+			t.Fatal("job must not run without the lock")
+			return 0, nil
+		}}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(false))
+		mock.ExpectCommit()
+
+		ran, err := s.RunOnce(context.Background(), job)
+		assert.NoError(t, err)
+		assert.False(t, ran)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestLockKeysDiffer(t *testing.T) {
+	seen := map[int64]string{}
+	for _, job := range append(CirculationJobs(nil, 0), NotificationJobs(nil, 0)...) {
+		assert.NotContains(t, seen, lockKey(job.Name), "%s and %s share a lock", job.Name, seen[lockKey(job.Name)])
+		seen[lockKey(job.Name)] = job.Name
+	}
+}
+
+// Scheduled changes have no signed-in user; the audit log attributes them to the system
+func TestCirculationJobsAreAudited(t *testing.T) {
+	ctx := context.Background()
+	db, err := storage.Open(storage.SQLite, storage.Memory)
+	assert.NoError(t, err)
+	db.Logger = logger.Discard
+	assert.NoError(t, storage.PrepareSchema(ctx, db))
+
+	library := models.Library{Name: "Central Library"}
+	assert.NoError(t, db.Create(&library).Error)
+	reader := models.User{Name: "Reader", Email: "reader@example.com", Role: "user"}
+	assert.NoError(t, db.Create(&reader).Error)
+	due := time.Now().AddDate(0, 0, -3).Unix()
+	loan := models.IssueRegistry{ISBN: "123456789", LibraryID: library.ID, ReaderID: reader.ID, IssueStatus: "issued", IssueDate: due - 14*24*3600, ExpectedReturnDate: due}
+	assert.NoError(t, db.Create(&loan).Error)
+
+	s := New(db)
+	jobs := CirculationJobs(circulation.NewService(db, config.Default().Circulation), time.Hour)
+	for _, job := range jobs[:2] { // mark-overdue, then accrue-fines
+		ran, err := s.RunOnce(ctx, job)
+		assert.NoError(t, err)
+		assert.True(t, ran)
+	}
+
+	var events []models.AuditEvent
+	assert.NoError(t, db.Order("id").Find(&events).Error)
+	var actions []string
+	for _, event := range events {
+		assert.Nil(t, event.ActorID)
+		assert.Equal(t, "system", event.ActorRole)
+		actions = append(actions, event.Action)
+	}
+	assert.Equal(t, []string{"loan.overdue", "fine.charge"}, actions)
+}
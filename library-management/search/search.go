@@ -0,0 +1,242 @@
+// Package search reads the catalogue search box. On Postgres a query becomes a
+// tsquery matched against the books' weighted search_vector column; for other
+// drivers the same query is matched, ranked and highlighted in Go, which
+// behaves alike apart from stemming.
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Weights of the fields of a book, as ts_rank weighs the A, B and C labels the
+// search_vector column gives them
+const (
+	TitleWeight     = 1.0
+	AuthorsWeight   = 0.4
+	PublisherWeight = 0.2
+)
+
+// Query is a parsed search string. Every term must match:
+//
+//	word      a word, on Postgres in any of its forms ("program" finds "programming")
+//	"a b c"   the words next to each other, in that order
+//	prefix*   a word that starts with prefix
+type Query struct {
+	Words    []string
+	Phrases  []string
+	Prefixes []string
+}
+
+// Parse splits s into terms. Anything other than letters and digits only
+// separates words, so no input can break the tsquery syntax.
+func Parse(s string) Query {
+	var q Query
+	for i, part := range strings.Split(s, `"`) {
+		if i%2 == 1 {
+			// Between quotes; an unclosed quote runs to the end
+			if words := tokens(part); len(words) > 0 {
+				q.Phrases = append(q.Phrases, strings.Join(words, " "))
+			}
+			continue
+		}
+		for _, field := range strings.Fields(part) {
+			words := tokens(field)
+			if len(words) == 0 {
+				continue
+			}
+			if strings.HasSuffix(field, "*") {
+				// "o'reil*" reads as the word o followed by the prefix reil
+				last := len(words) - 1
+				q.Words = append(q.Words, words[:last]...)
+				q.Prefixes = append(q.Prefixes, words[last])
+				continue
+			}
+			q.Words = append(q.Words, words...)
+		}
+	}
+	return q
+}
+
+// Empty reports whether the query has no terms, and so matches everything
+func (q Query) Empty() bool {
+	return len(q.Words) == 0 && len(q.Phrases) == 0 && len(q.Prefixes) == 0
+}
+
+// TSQuery returns a Postgres expression, with its arguments, for the tsquery
+// of q. Words and phrases go through the english configuration, like the
+// search_vector column; prefixes are not stemmed.
+func (q Query) TSQuery() (string, []interface{}) {
+	var parts []string
+	var args []interface{}
+	if len(q.Words) > 0 {
+		parts = append(parts, "plainto_tsquery('english', ?)")
+		args = append(args, strings.Join(q.Words, " "))
+	}
+	for _, phrase := range q.Phrases {
+		parts = append(parts, "phraseto_tsquery('english', ?)")
+		args = append(args, phrase)
+	}
+	for _, prefix := range q.Prefixes {
+		parts = append(parts, "to_tsquery('simple', ?)")
+		args = append(args, prefix+":*")
+	}
+	return strings.Join(parts, " && "), args
+}
+
+// Patterns returns a LIKE pattern per term. A field that matches a term
+// matches its pattern, so the patterns narrow down candidates in SQL.
+func (q Query) Patterns() []string {
+	var patterns []string
+	for _, term := range q.terms() {
+		patterns = append(patterns, "%"+strings.Join(term, "%")+"%")
+	}
+	return patterns
+}
+
+// terms lists every term as the sequence of words it must match
+func (q Query) terms() [][]string {
+	var terms [][]string
+	for _, word := range q.Words {
+		terms = append(terms, []string{word})
+	}
+	for _, phrase := range q.Phrases {
+		terms = append(terms, strings.Fields(phrase))
+	}
+	for _, prefix := range q.Prefixes {
+		terms = append(terms, []string{prefix})
+	}
+	return terms
+}
+
+// Document is the searchable text of a book
+type Document struct {
+	Title     string
+	Authors   string
+	Publisher string
+}
+
+// Text joins the fields the way the headline shows them
+func (d Document) Text() string {
+	var fields []string
+	for _, field := range []string{d.Title, d.Authors, d.Publisher} {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return strings.Join(fields, " / ")
+}
+
+// Match reports whether every term of q matches one of the fields
+func (q Query) Match(d Document) bool {
+	fields := []string{d.Title, d.Authors, d.Publisher}
+	for _, term := range q.terms() {
+		found := false
+		for _, field := range fields {
+			if len(find(words(field), term)) > 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Rank scores a match by the weight of the fields each term is found in. It
+// orders results like ts_rank does, though the numbers differ.
+func (q Query) Rank(d Document) float64 {
+	fields := []struct {
+		text   string
+		weight float64
+	}{{d.Title, TitleWeight}, {d.Authors, AuthorsWeight}, {d.Publisher, PublisherWeight}}
+
+	var rank float64
+	for _, term := range q.terms() {
+		for _, field := range fields {
+			rank += float64(len(find(words(field.text), term))) * field.weight
+		}
+	}
+	return rank
+}
+
+// Headline returns the document text with every matched word in <b></b>, as
+// ts_headline marks it
+func (q Query) Headline(d Document) string {
+	text := d.Text()
+	ws := words(text)
+	marked := make([]bool, len(ws))
+	for _, term := range q.terms() {
+		for _, at := range find(ws, term) {
+			for i := range term {
+				marked[at+i] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	end := 0
+	for i, w := range ws {
+		if !marked[i] {
+			continue
+		}
+		b.WriteString(text[end:w.start])
+		b.WriteString("<b>" + text[w.start:w.end] + "</b>")
+		end = w.end
+	}
+	b.WriteString(text[end:])
+	return b.String()
+}
+
+// word is a run of letters and digits in a text
+type word struct {
+	start, end int
+	lower      string
+}
+
+func words(text string) []word {
+	var ws []word
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			ws = append(ws, word{start, i, strings.ToLower(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		ws = append(ws, word{start, len(text), strings.ToLower(text[start:])})
+	}
+	return ws
+}
+
+func tokens(s string) []string {
+	var ts []string
+	for _, w := range words(s) {
+		ts = append(ts, w.lower)
+	}
+	return ts
+}
+
+// find returns where term starts in ws. Without a stemmer each query word
+// matches the words it begins, so "program" still finds "programming".
+func find(ws []word, term []string) []int {
+	var at []int
+	for i := 0; i+len(term) <= len(ws); i++ {
+		matched := true
+		for j, t := range term {
+			if !strings.HasPrefix(ws[i+j].lower, t) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			at = append(at, i)
+		}
+	}
+	return at
+}
@@ -0,0 +1,69 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Query
+	}{
+		{"Empty", "  ", Query{}},
+		{"Words", "Go  Programming", Query{Words: []string{"go", "programming"}}},
+		{"Phrase", `"the go" language`, Query{Words: []string{"language"}, Phrases: []string{"the go"}}},
+		{"Unclosed phrase", `go "programming language`, Query{Words: []string{"go"}, Phrases: []string{"programming language"}}},
+		{"Prefix", "prog* kernighan", Query{Words: []string{"kernighan"}, Prefixes: []string{"prog"}}},
+		{"Punctuation only separates", "o'reil* C++ :*|!", Query{Words: []string{"o", "c"}, Prefixes: []string{"reil"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.input)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.input == "  ", got.Empty())
+		})
+	}
+}
+
+func TestTSQuery(t *testing.T) {
+	sql, args := Parse(`go "programming language" kern*`).TSQuery()
+	assert.Equal(t, "plainto_tsquery('english', ?) && phraseto_tsquery('english', ?) && to_tsquery('simple', ?)", sql)
+	assert.Equal(t, []interface{}{"go", "programming language", "kern:*"}, args)
+}
+
+func TestMatchRankHeadline(t *testing.T) {
+	book := Document{Title: "The Go Programming Language", Authors: "Alan Donovan, Brian Kernighan", Publisher: "Addison-Wesley"}
+
+	tests := []struct {
+		query    string
+		match    bool
+		rank     float64
+		headline string
+	}{
+		{"go", true, TitleWeight, "The <b>Go</b> Programming Language / Alan Donovan, Brian Kernighan / Addison-Wesley"},
+		{"program", true, TitleWeight, "The Go <b>Programming</b> Language / Alan Donovan, Brian Kernighan / Addison-Wesley"},
+		{`"go programming" kern*`, true, TitleWeight + AuthorsWeight, "The <b>Go</b> <b>Programming</b> Language / Alan Donovan, Brian <b>Kernighan</b> / Addison-Wesley"},
+		{"addison language", true, PublisherWeight + TitleWeight, "The Go Programming <b>Language</b> / Alan Donovan, Brian Kernighan / <b>Addison</b>-Wesley"},
+		{`"programming go"`, false, 0, book.Text()},
+		{"go rust", false, TitleWeight, "The <b>Go</b> Programming Language / Alan Donovan, Brian Kernighan / Addison-Wesley"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q := Parse(tt.query)
+			assert.Equal(t, tt.match, q.Match(book))
+			assert.InDelta(t, tt.rank, q.Rank(book), 1e-9)
+			assert.Equal(t, tt.headline, q.Headline(book))
+		})
+	}
+
+	t.Run("Empty fields are left out", func(t *testing.T) {
+		assert.Equal(t, "<b>Go</b> / Acme Press", Parse("go").Headline(Document{Title: "Go", Publisher: "Acme Press"}))
+	})
+}
+
+func TestPatterns(t *testing.T) {
+	assert.Equal(t, []string{"%go%", "%programming%language%", "%kern%"}, Parse(`go "programming language" kern*`).Patterns())
+}
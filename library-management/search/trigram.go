@@ -0,0 +1,113 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// trigrams splits s into words and returns the set of their trigrams, each
+// word padded as pg_trgm pads it: two spaces in front and one behind
+func trigrams(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range tokens(s) {
+		padded := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// WordSimilarity is close to pg_trgm's word_similarity: the share of the
+// trigrams of query found in the best run of as many words of text. It is 1
+// when text contains every word of query.
+func WordSimilarity(query, text string) float64 {
+	want := trigrams(query)
+	if len(want) == 0 {
+		return 0
+	}
+	words := tokens(text)
+	size := len(tokens(query))
+	if size > len(words) {
+		size = len(words)
+	}
+
+	var best float64
+	for i := 0; i+size <= len(words) && size > 0; i++ {
+		found := 0
+		for trigram := range trigrams(strings.Join(words[i:i+size], " ")) {
+			if want[trigram] {
+				found++
+			}
+		}
+		if s := float64(found) / float64(len(want)); s > best {
+			best = s
+		}
+	}
+	return best
+}
+
+// Names splits a book's authors field into the people it lists
+func Names(authors string) []string {
+	var names []string
+	for _, name := range strings.FieldsFunc(authors, func(r rune) bool { return r == ',' || r == ';' || r == '&' }) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Completes reports whether text, or one of its words, starts with prefix,
+// ignoring case
+func Completes(text, prefix string) bool {
+	text, prefix = strings.ToLower(text), strings.ToLower(strings.TrimSpace(prefix))
+	if prefix == "" {
+		return false
+	}
+	if strings.HasPrefix(text, prefix) {
+		return true
+	}
+	for _, w := range words(text) {
+		if strings.HasPrefix(text[w.start:], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Candidate is a title or author name offered to a reader, with its score
+type Candidate struct {
+	Text  string
+	Kind  string
+	Score float64
+}
+
+// Best orders candidates by score, then shortest and alphabetically, drops
+// repeats of the same text and kind, and keeps at most limit of them
+func Best(candidates []Candidate, limit int) []Candidate {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if len(a.Text) != len(b.Text) {
+			return len(a.Text) < len(b.Text)
+		}
+		return a.Text < b.Text
+	})
+
+	seen := map[string]bool{}
+	best := make([]Candidate, 0, limit)
+	for _, c := range candidates {
+		key := c.Kind + "\x00" + strings.ToLower(c.Text)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if best = append(best, c); len(best) == limit {
+			break
+		}
+	}
+	return best
+}
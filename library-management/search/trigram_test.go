@@ -0,0 +1,56 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWordSimilarity(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+		want  float64
+	}{
+		{"Tolkien", "J.R.R. Tolkien", 1},
+		{"Tolkein", "J.R.R. Tolkien", 0.5}, // Shares "  t", " to", "tol" and "olk" of eight trigrams
+		{"hobit", "The Hobbit", 5.0 / 6},
+		{"lord rings", "The Lord of the Rings", 6.0 / 11}, // The words are compared two at a time, "the rings" comes closest
+		{"dune", "Foundation", 0},
+		{"", "Foundation", 0},
+		{"two words", "words", 6.0 / 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query+" in "+tt.text, func(t *testing.T) {
+			assert.InDelta(t, tt.want, WordSimilarity(tt.query, tt.text), 1e-9)
+		})
+	}
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, []string{"Alan Donovan", "Brian Kernighan", "Rob Pike"}, Names(" Alan Donovan,Brian Kernighan & Rob Pike; "))
+	assert.Empty(t, Names(""))
+}
+
+func TestCompletes(t *testing.T) {
+	assert.True(t, Completes("The Lord of the Rings", "lord of"))
+	assert.True(t, Completes("J.R.R. Tolkien", "tol"))
+	assert.True(t, Completes("J.R.R. Tolkien", "J.R"))
+	assert.False(t, Completes("J.R.R. Tolkien", "kien"))
+	assert.False(t, Completes("Anything", " "))
+}
+
+func TestBest(t *testing.T) {
+	best := Best([]Candidate{
+		{Text: "The Hobbit", Kind: "title", Score: 0.5},
+		{Text: "Tolkien", Kind: "author", Score: 1},
+		{Text: "tolkien", Kind: "author", Score: 1},
+		{Text: "Tolkien", Kind: "title", Score: 1},
+		{Text: "Hobbit", Kind: "title", Score: 0.5},
+	}, 3)
+	assert.Equal(t, []Candidate{
+		{Text: "Tolkien", Kind: "author", Score: 1},
+		{Text: "Tolkien", Kind: "title", Score: 1},
+		{Text: "Hobbit", Kind: "title", Score: 0.5},
+	}, best)
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"library-management/audit"
+	"library-management/models"
+	"library-management/utils"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type authService struct {
+	db *gorm.DB
+}
+
+// NewAuthService returns an AuthService that keeps refresh tokens and
+// revocations in db
+func NewAuthService(db *gorm.DB) AuthService {
+	return &authService{db: db}
+}
+
+func (s *authService) Login(ctx context.Context, email, password string) (*TokenPair, error) {
+	db := s.db.WithContext(ctx)
+
+	var user models.User
+	err := db.Where("email = ? AND deleted_at IS NULL", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	match, rehash := utils.CheckPassword(user.Password, password)
+	if !match {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Upgrade legacy plaintext or outdated hashes; login still succeeds if this fails
+	if rehash {
+		if hashed, err := utils.HashPassword(password); err == nil {
+			if err := db.Model(&user).Update("password", hashed).Error; err != nil {
+				slog.Error("Failed to rehash password", "user_id", user.ID, "error", err)
+			}
+		}
+	}
+
+	pair, _, err := issueTokenPair(db, user, "")
+	return pair, err
+}
+
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	db := s.db.WithContext(ctx)
+
+	var stored models.RefreshToken
+	err := db.Where("token_hash = ?", utils.HashRefreshToken(refreshToken)).First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	if stored.RevokedAt != nil {
+		if err := revokeTokenFamily(db, stored.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if stored.ExpiresAt <= now {
+		return nil, ErrRefreshTokenExpired
+	}
+
+	var user models.User
+	if err := db.First(&user, stored.UserID).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var pair *TokenPair
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Guard on revoked_at so two concurrent refreshes cannot both rotate the same token
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", stored.ID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		var next *models.RefreshToken
+		var err error
+		pair, next, err = issueTokenPair(tx, user, stored.FamilyID)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).Where("id = ?", stored.ID).Update("replaced_by_id", next.ID).Error
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if err := revokeTokenFamily(db, stored.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+func (s *authService) Logout(ctx context.Context, userID uint, jti string, expiresAt int64, refreshToken string) error {
+	now := time.Now().Unix()
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if jti != "" {
+			revoked := models.RevokedToken{
+				JTI:       jti,
+				UserID:    userID,
+				ExpiresAt: expiresAt,
+				RevokedAt: now,
+			}
+			if err := tx.Create(&revoked).Error; err != nil {
+				return err
+			}
+		}
+
+		if refreshToken == "" {
+			return nil
+		}
+
+		var stored models.RefreshToken
+		err := tx.Where("token_hash = ? AND user_id = ?", utils.HashRefreshToken(refreshToken), userID).First(&stored).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return revokeTokenFamily(tx, stored.FamilyID, now)
+	})
+}
+
+func (s *authService) RevokeUser(ctx context.Context, userID uint) (int64, error) {
+	db := s.db.WithContext(ctx)
+
+	var user models.User
+	err := db.First(&user, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrUserNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var refreshTokens int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", now.Unix())
+		if result.Error != nil {
+			return result.Error
+		}
+		refreshTokens = result.RowsAffected
+
+		// Access tokens are not stored, so one row rejects all those issued until
+		// now; it can go once the last of them has expired
+		revoked := models.RevokedToken{
+			JTI:       userRevocationJTI(user.ID),
+			UserID:    user.ID,
+			ExpiresAt: now.Add(utils.AccessTokenTTL).Unix(),
+			RevokedAt: now.Unix(),
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "jti"}},
+			DoUpdates: clause.AssignmentColumns([]string{"expires_at", "revoked_at"}),
+		}).Create(&revoked).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, "user.revoke_tokens", "user", user.ID, nil, revoked)
+	})
+	return refreshTokens, err
+}
+
+func (s *authService) IsRevoked(ctx context.Context, claims *utils.TokenClaims) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.RevokedToken{}).
+		Where("jti = ? OR (jti = ? AND revoked_at >= ?)", claims.JTI, userRevocationJTI(claims.UserID), claims.IssuedAt.Unix()).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// userRevocationJTI keys the revoked_tokens row that revokes all of a user's
+// access tokens; it cannot clash with the hex jti of a real token
+func userRevocationJTI(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// issueTokenPair signs an access token and persists a new refresh token in the given family.
+// An empty familyID starts a new family, as on login.
+func issueTokenPair(db *gorm.DB, user models.User, familyID string) (*TokenPair, *models.RefreshToken, error) {
+	accessToken, err := utils.GenerateJWT(user.ID, user.Role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if familyID == "" {
+		if familyID, err = utils.NewTokenID(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	refreshToken, hash, err := utils.NewRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL).Unix(),
+	}
+	if err := db.Create(&stored).Error; err != nil {
+		return nil, nil, err
+	}
+
+	pair := &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresIn: utils.AccessTokenTTL}
+	return pair, &stored, nil
+}
+
+// revokeTokenFamily revokes every still-active refresh token rotated from the same login
+func revokeTokenFamily(db *gorm.DB, familyID string, now int64) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
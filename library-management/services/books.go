@@ -0,0 +1,523 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"library-management/audit"
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/models"
+	"library-management/pagination"
+	"library-management/search"
+	"library-management/storage"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type bookService struct {
+	db     *gorm.DB
+	circ   *circulation.Service
+	search config.SearchConfig
+}
+
+// NewBookService returns a BookService backed by db; circ keeps copies and holds
+// in step and cfg tunes typo-tolerant search
+func NewBookService(db *gorm.DB, circ *circulation.Service, cfg config.SearchConfig) BookService {
+	return &bookService{db: db, circ: circ, search: cfg}
+}
+
+// findBook loads a book by ISBN in a library; any lookup failure is reported
+// as circulation.ErrBookNotFound, as the handlers always have
+func findBook(db *gorm.DB, isbn string, libraryID uint) (*models.Book, error) {
+	var book models.Book
+	if err := db.Where("isbn = ? AND library_id = ?", isbn, libraryID).First(&book).Error; err != nil {
+		return nil, circulation.ErrBookNotFound
+	}
+	return &book, nil
+}
+
+func (s *bookService) AddBook(ctx context.Context, input models.Book) (*models.Book, bool, error) {
+	db := s.db.WithContext(ctx)
+
+	// Every counted copy becomes a barcoded copy; the counters are derived from them
+	newCopies := make([]circulation.CopyInput, input.TotalCopies)
+
+	existing, err := findBook(db, input.ISBN, input.LibraryID)
+	if err == nil {
+		// Book already exists, add the new copies
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(existing, existing.ID).Error; err != nil {
+				return err
+			}
+			before := *existing
+			if _, err := s.circ.AddCopies(tx, existing, newCopies...); err != nil {
+				return err
+			}
+			return audit.Record(tx, "book.add_copies", "book", existing.ID, before, existing)
+		})
+		return existing, false, err
+	}
+	if !errors.Is(err, circulation.ErrBookNotFound) {
+		return nil, false, err
+	}
+
+	input.TotalCopies = 0
+	input.AvailableCopies = 0
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&input).Error; err != nil {
+			return err
+		}
+		if _, err := s.circ.AddCopies(tx, &input, newCopies...); err != nil {
+			return err
+		}
+		return audit.Record(tx, "book.create", "book", input.ID, nil, input)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &input, true, nil
+}
+
+func (s *bookService) UpdateBook(ctx context.Context, isbn string, libraryID uint, update BookUpdate) (*models.Book, error) {
+	db := s.db.WithContext(ctx)
+
+	book, err := findBook(db, isbn, libraryID)
+	if err != nil {
+		return nil, err
+	}
+	if update.TotalCopies < book.TotalCopies-book.AvailableCopies {
+		return nil, ErrIssuedCopies
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(book, book.ID).Error; err != nil {
+			return err
+		}
+		before := *book
+
+		if err := tx.Model(book).Updates(map[string]interface{}{
+			"title":     update.Title,
+			"authors":   update.Authors,
+			"publisher": update.Publisher,
+			"version":   update.Version,
+		}).Error; err != nil {
+			return err
+		}
+		book.Title, book.Authors, book.Publisher, book.Version = update.Title, update.Authors, update.Publisher, update.Version
+
+		// Grow or shrink the shelf one copy at a time; only available copies are withdrawn
+		switch diff := update.TotalCopies - book.TotalCopies; {
+		case diff > 0:
+			if _, err := s.circ.AddCopies(tx, book, make([]circulation.CopyInput, diff)...); err != nil {
+				return err
+			}
+		case diff < 0:
+			if err := circulation.WithdrawCopies(tx, book, -diff); err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, "book.update", "book", book.ID, before, book)
+	})
+	if errors.Is(err, circulation.ErrNoCopiesAvailable) {
+		return nil, ErrIssuedCopies
+	}
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+func (s *bookService) RemoveBook(ctx context.Context, isbn string, libraryID uint) (*models.Book, bool, error) {
+	db := s.db.WithContext(ctx)
+
+	book, err := findBook(db, isbn, libraryID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if book.TotalCopies > 1 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(book, book.ID).Error; err != nil {
+				return err
+			}
+			before := *book
+			if err := circulation.WithdrawCopies(tx, book, 1); err != nil {
+				return err
+			}
+			return audit.Record(tx, "book.withdraw_copy", "book", book.ID, before, book)
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		return book, false, nil
+	}
+
+	// A book whose last copy is out or set aside stays until the copy comes back,
+	// or the return would find no book to restock
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var inUse int64
+		if err := tx.Model(&models.BookCopy{}).
+			Where("book_id = ? AND status IN ?", book.ID, []string{models.CopyIssued, models.CopyOnHold}).
+			Count(&inUse).Error; err != nil {
+			return err
+		}
+		if inUse > 0 {
+			return circulation.ErrCopyUnavailable
+		}
+
+		if err := tx.Model(&models.BookCopy{}).
+			Where("book_id = ?", book.ID).
+			Update("status", models.CopyWithdrawn).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(book).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, "book.delete", "book", book.ID, book, nil)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return book, true, nil
+}
+
+// bookColumns are what a search result needs of a book
+const bookColumns = "isbn, title, authors, publisher, available_copies, library_id"
+
+func (s *bookService) SearchBooks(ctx context.Context, libraryIDs []uint, q BookQuery) ([]BookResult, pagination.Page, error) {
+	db := s.db.WithContext(ctx)
+
+	var results []BookResult
+	var page pagination.Page
+	var err error
+	text := search.Parse(q.Text)
+	switch {
+	case text.Empty():
+		results, page, err = pagination.Find(filterBooks(db, libraryIDs, q).Select("id, "+bookColumns), q.Page)
+	case q.Fuzzy:
+		results, page, err = s.similarBooks(db, libraryIDs, q)
+	case storage.IsPostgres(db):
+		results, page, err = fullTextSearch(db, filterBooks(db, libraryIDs, q), text, q.Page)
+	default:
+		results, page, err = matchText(db, filterBooks(db, libraryIDs, q), text, q.Page)
+	}
+	if err != nil {
+		return nil, page, err
+	}
+
+	if err := availability(db, results); err != nil {
+		return nil, page, err
+	}
+	return results, page, nil
+}
+
+// availability tells the readers of books with every copy out when the first
+// loan is due back and how many holds are queued ahead of them, reading the
+// loans and holds of the whole page at once
+func availability(db *gorm.DB, results []BookResult) error {
+	type title struct {
+		ISBN      string
+		LibraryID uint
+	}
+	out := map[title][]int{}
+	var isbns []string
+	var libraryIDs []uint
+	for i, result := range results {
+		if result.AvailableCopies > 0 {
+			continue
+		}
+		t := title{result.ISBN, result.LibraryID}
+		if _, ok := out[t]; !ok {
+			isbns = append(isbns, t.ISBN)
+			libraryIDs = append(libraryIDs, t.LibraryID)
+		}
+		out[t] = append(out[t], i)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+
+	var due []struct {
+		ISBN      string
+		LibraryID uint
+		Due       int64
+	}
+	if err := db.Model(&models.IssueRegistry{}).
+		Select("isbn, library_id, MIN(expected_return_date) AS due").
+		Where("issue_status = ? AND isbn IN (?) AND library_id IN (?)", "issued", isbns, libraryIDs).
+		Group("isbn, library_id").
+		Scan(&due).Error; err != nil {
+		return err
+	}
+	for _, d := range due {
+		for _, i := range out[title{d.ISBN, d.LibraryID}] {
+			at := d.Due
+			results[i].NextAvailableAt = &at
+		}
+	}
+
+	var holds []struct {
+		ISBN      string
+		LibraryID uint
+		Waiting   int64
+	}
+	if err := db.Model(&models.Hold{}).
+		Select("isbn, library_id, COUNT(*) AS waiting").
+		Where("status = ? AND isbn IN (?) AND library_id IN (?)", models.HoldWaiting, isbns, libraryIDs).
+		Group("isbn, library_id").
+		Scan(&holds).Error; err != nil {
+		return err
+	}
+	for _, h := range holds {
+		for _, i := range out[title{h.ISBN, h.LibraryID}] {
+			results[i].HoldsWaiting = h.Waiting
+		}
+	}
+	return nil
+}
+
+// ranked pages the results of a query that computes their rank. Wrapping it
+// lets the page be sorted, and continued, by the rank alias.
+func ranked(db *gorm.DB, query *gorm.DB, page pagination.Request[BookResult]) ([]BookResult, pagination.Page, error) {
+	return pagination.Find(db.Unscoped().Table("(?) AS ranked", query), page)
+}
+
+// filterBooks selects the books of the libraries that match the title, author
+// and publisher filters of q
+func filterBooks(db *gorm.DB, libraryIDs []uint, q BookQuery) *gorm.DB {
+	query := db.Model(&models.Book{}).Where("library_id IN (?)", libraryIDs)
+	if q.Title != "" {
+		query = query.Where(storage.ILike(db, "title"), "%"+q.Title+"%")
+	}
+	if q.Author != "" {
+		query = query.Where(storage.ILike(db, "authors"), "%"+q.Author+"%")
+	}
+	if q.Publisher != "" {
+		query = query.Where(storage.ILike(db, "publisher"), "%"+q.Publisher+"%")
+	}
+	return query
+}
+
+// fullTextSearch matches text against the search_vector column that migration
+// 0013 keeps up to date
+func fullTextSearch(db *gorm.DB, query *gorm.DB, text search.Query, page pagination.Request[BookResult]) ([]BookResult, pagination.Page, error) {
+	tsquery, args := text.TSQuery()
+	// The rank is widened to double precision so that a cursor holding it
+	// compares equal to the row it was taken from
+	query = query.
+		Select("id, "+bookColumns+", ts_rank(search_vector, search.query)::float8 AS rank, "+
+			"ts_headline('english', concat_ws(' / ', title, nullif(authors, ''), nullif(publisher, '')), search.query) AS headline").
+		Joins("CROSS JOIN (SELECT "+tsquery+" AS query) AS search", args...).
+		Where("search_vector @@ search.query")
+	return ranked(db, query, page)
+}
+
+// matchText is full-text search for drivers without it: LIKE narrows the
+// books down to those containing every term, then Go matches and ranks them
+func matchText(db *gorm.DB, query *gorm.DB, text search.Query, page pagination.Request[BookResult]) ([]BookResult, pagination.Page, error) {
+	for _, pattern := range text.Patterns() {
+		query = query.Where(db.Where(storage.ILike(db, "title"), pattern).
+			Or(storage.ILike(db, "authors"), pattern).
+			Or(storage.ILike(db, "publisher"), pattern))
+	}
+	var candidates []BookResult
+	if err := query.Select("id, " + bookColumns).Find(&candidates).Error; err != nil {
+		return nil, pagination.Page{}, err
+	}
+	results, p := pagination.Slice(rankText(candidates, text), page)
+	return results, p, nil
+}
+
+// rankText keeps the candidates text matches, best ranked first, with their
+// rank and headline
+func rankText(candidates []BookResult, text search.Query) []BookResult {
+	results := make([]BookResult, 0, len(candidates))
+	for _, result := range candidates {
+		doc := search.Document{Title: result.Title, Authors: result.Authors, Publisher: result.Publisher}
+		if !text.Match(doc) {
+			continue
+		}
+		result.Rank = text.Rank(doc)
+		result.Headline = text.Headline(doc)
+		results = append(results, result)
+	}
+	sortByRank(results)
+	return results
+}
+
+func sortByRank(results []BookResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID < results[j].ID
+	})
+}
+
+// similarityThreshold sets how alike the <% operator wants its operands for
+// the rest of the transaction. The operator, unlike the word_similarity
+// function, can use the trigram indexes of migration 0014.
+func similarityThreshold(tx *gorm.DB, similarity float64) error {
+	return tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)",
+		strconv.FormatFloat(similarity, 'f', -1, 64)).Error
+}
+
+// similarBooks matches q.Text against titles and authors by trigram
+// similarity, so misspelt words still find their books
+func (s *bookService) similarBooks(db *gorm.DB, libraryIDs []uint, q BookQuery) ([]BookResult, pagination.Page, error) {
+	if !storage.IsPostgres(db) {
+		var candidates []BookResult
+		if err := filterBooks(db, libraryIDs, q).Select("id, " + bookColumns).Find(&candidates).Error; err != nil {
+			return nil, pagination.Page{}, err
+		}
+		results, page := pagination.Slice(similarTo(candidates, q.Text, s.search.Similarity), q.Page)
+		return results, page, nil
+	}
+
+	var results []BookResult
+	var page pagination.Page
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := similarityThreshold(tx, s.search.Similarity); err != nil {
+			return err
+		}
+		query := filterBooks(tx, libraryIDs, q).
+			Select("id, "+bookColumns+", greatest(word_similarity(?, title), word_similarity(?, authors))::float8 AS rank", q.Text, q.Text).
+			Where("? <% title OR ? <% authors", q.Text, q.Text)
+		var err error
+		results, page, err = ranked(tx, query, q.Page)
+		return err
+	})
+	return results, page, err
+}
+
+// similarTo keeps the candidates whose title or authors are at least
+// similarity alike to text, most alike first
+func similarTo(candidates []BookResult, text string, similarity float64) []BookResult {
+	results := make([]BookResult, 0, len(candidates))
+	for _, result := range candidates {
+		result.Rank = math.Max(search.WordSimilarity(text, result.Title), search.WordSimilarity(text, result.Authors))
+		if result.Rank >= similarity {
+			results = append(results, result)
+		}
+	}
+	sortByRank(results)
+	return results
+}
+
+// suggestionCandidates caps the books read to find suggestions, which keeps
+// autocomplete quick on a large catalogue
+const suggestionCandidates = 200
+
+func (s *bookService) Suggest(ctx context.Context, libraryIDs []uint, prefix string) ([]Suggestion, error) {
+	db := s.db.WithContext(ctx)
+	escaped := storage.EscapeLike(strings.TrimSpace(prefix))
+	matching := func(pattern string) *gorm.DB {
+		return db.Where(storage.ILike(db, "title"), pattern).Or(storage.ILike(db, "authors"), pattern)
+	}
+	candidates := func() *gorm.DB {
+		return db.Model(&models.Book{}).Distinct("title", "authors").Where("library_id IN (?)", libraryIDs)
+	}
+
+	// Books starting with prefix are read first, so that a catalogue with many
+	// books merely containing it cannot crowd out the best completions
+	var books []models.Book
+	if err := candidates().Where(matching(escaped + "%")).
+		Limit(suggestionCandidates).
+		Find(&books).Error; err != nil {
+		return nil, err
+	}
+	if len(books) < suggestionCandidates {
+		var inner []models.Book
+		if err := candidates().Where(matching("%" + escaped + "%")).Not(matching(escaped + "%")).
+			Limit(suggestionCandidates - len(books)).
+			Find(&inner).Error; err != nil {
+			return nil, err
+		}
+		books = append(books, inner...)
+	}
+	return completions(books, prefix, s.search.SuggestionLimit), nil
+}
+
+func (s *bookService) DidYouMean(ctx context.Context, libraryIDs []uint, text string) ([]Suggestion, error) {
+	db := s.db.WithContext(ctx)
+	candidates := func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&models.Book{}).Distinct("title", "authors").Where("library_id IN (?)", libraryIDs)
+	}
+
+	var books []models.Book
+	if !storage.IsPostgres(db) {
+		// Without trigram indexes every book is a candidate
+		if err := candidates(db).Find(&books).Error; err != nil {
+			return nil, err
+		}
+		return corrections(books, text, s.search), nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := similarityThreshold(tx, s.search.Similarity); err != nil {
+			return err
+		}
+		return candidates(tx).
+			Where("? <% title OR ? <% authors", text, text).
+			Limit(suggestionCandidates).
+			Find(&books).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return corrections(books, text, s.search), nil
+}
+
+// completions lists the titles and authors of books that prefix completes,
+// those starting with it first
+func completions(books []models.Book, prefix string, limit int) []Suggestion {
+	var candidates []search.Candidate
+	add := func(text, kind string) {
+		if !search.Completes(text, prefix) {
+			return
+		}
+		score := 0.5
+		if strings.HasPrefix(strings.ToLower(text), strings.ToLower(strings.TrimSpace(prefix))) {
+			score = 1
+		}
+		candidates = append(candidates, search.Candidate{Text: text, Kind: kind, Score: score})
+	}
+	for _, book := range books {
+		add(book.Title, SuggestTitle)
+		for _, name := range search.Names(book.Authors) {
+			add(name, SuggestAuthor)
+		}
+	}
+	return suggestions(search.Best(candidates, limit))
+}
+
+// corrections lists the titles and authors of books spelt like text, most
+// alike first
+func corrections(books []models.Book, text string, cfg config.SearchConfig) []Suggestion {
+	var candidates []search.Candidate
+	add := func(candidate, kind string) {
+		if score := search.WordSimilarity(text, candidate); score >= cfg.Similarity {
+			candidates = append(candidates, search.Candidate{Text: candidate, Kind: kind, Score: score})
+		}
+	}
+	for _, book := range books {
+		add(book.Title, SuggestTitle)
+		for _, name := range search.Names(book.Authors) {
+			add(name, SuggestAuthor)
+		}
+	}
+	return suggestions(search.Best(candidates, cfg.SuggestionLimit))
+}
+
+func suggestions(candidates []search.Candidate) []Suggestion {
+	list := make([]Suggestion, len(candidates))
+	for i, c := range candidates {
+		list[i] = Suggestion{Text: c.Text, Kind: c.Kind}
+	}
+	return list
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"library-management/circulation"
+	"library-management/config"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchBooksSQL(t *testing.T) {
+	db, mock := newMockDB(t)
+	books := NewBookService(db, nil, config.Default().Search)
+	ctx := context.Background()
+	resultColumns := []string{"id", "isbn", "title", "authors", "publisher", "available_copies", "library_id"}
+
+	t.Run("Browse", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isbn, title, authors, publisher, available_copies, library_id FROM "books" WHERE library_id IN ($1) AND "books"."deleted_at" IS NULL ORDER BY title, id LIMIT $2`)).
+			WithArgs(1, 21).
+			WillReturnRows(sqlmock.NewRows(resultColumns).AddRow(1, "123456789", "Test Book", "Test Author", "Test Publisher", 2, 1))
+
+		results, _, err := books.SearchBooks(ctx, []uint{1}, BookQuery{Page: parsePage(t, BookSort, "")})
+		assert.NoError(t, err)
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, "Test Book", results[0].Title)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isbn, title, authors, publisher, available_copies, library_id FROM "books" WHERE library_id IN ($1) AND title ILIKE $2`)).
+			WithArgs(1, "%Test Title%", 21).
+			WillReturnRows(sqlmock.NewRows(resultColumns))
+
+		results, _, err := books.SearchBooks(ctx, []uint{1}, BookQuery{Title: "Test Title", Page: parsePage(t, BookSort, "")})
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Full-Text", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`FROM "books" CROSS JOIN (SELECT plainto_tsquery('english', $1) && to_tsquery('simple', $2) AS query) AS search `+
+			`WHERE library_id IN ($3) AND search_vector @@ search.query AND "books"."deleted_at" IS NULL) AS ranked ORDER BY rank DESC, id DESC LIMIT $4`)).
+			WithArgs("test", "auth:*", 1, 21).
+			WillReturnRows(sqlmock.NewRows(append(resultColumns, "rank", "headline")).
+				AddRow(1, "123456789", "Test Book", "Test Author", "Test Publisher", 2, 1, 0.6, "<b>Test</b> Book / <b>Test</b> <b>Author</b> / <b>Test</b> Publisher"))
+
+		results, _, err := books.SearchBooks(ctx, []uint{1}, BookQuery{Text: "test auth*", Page: parsePage(t, RankedBookSort, "")})
+		assert.NoError(t, err)
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, 0.6, results[0].Rank)
+			assert.Contains(t, results[0].Headline, "<b>Test</b> Book")
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	// Fuzzy search ranks by trigram similarity under the configured threshold
+	t.Run("Fuzzy", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`)).
+			WithArgs("0.5").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM (SELECT id, isbn, title, authors, publisher, available_copies, library_id, greatest(word_similarity($1, title), word_similarity($2, authors))::float8 AS rank FROM "books" `+
+			`WHERE library_id IN ($3) AND ($4 <% title OR $5 <% authors) AND "books"."deleted_at" IS NULL) AS ranked ORDER BY rank DESC, id DESC LIMIT $6`)).
+			WithArgs("Tolkein", "Tolkein", 1, "Tolkein", "Tolkein", 21).
+			WillReturnRows(sqlmock.NewRows(append(resultColumns, "rank")).
+				AddRow(1, "123456789", "The Hobbit", "J.R.R. Tolkien", "Allen & Unwin", 2, 1, 0.5))
+		mock.ExpectCommit()
+
+		results, _, err := books.SearchBooks(ctx, []uint{1}, BookQuery{Text: "Tolkein", Fuzzy: true, Page: parsePage(t, RankedBookSort, "")})
+		assert.NoError(t, err)
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, "The Hobbit", results[0].Title)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Database Error", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`FROM "books"`)).
+			WillReturnError(errors.New("db error"))
+
+		_, _, err := books.SearchBooks(ctx, []uint{1}, BookQuery{Page: parsePage(t, BookSort, "")})
+		assert.EqualError(t, err, "db error")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSuggestionsSQL(t *testing.T) {
+	db, mock := newMockDB(t)
+	books := NewBookService(db, nil, config.Default().Search)
+	ctx := context.Background()
+
+	// Titles and authors starting with the prefix are read first, then those containing it fill the candidates
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "title","authors" FROM "books" WHERE library_id IN ($1) AND (title ILIKE $2 ESCAPE '\' OR authors ILIKE $3 ESCAPE '\') AND "books"."deleted_at" IS NULL LIMIT $4`)).
+		WithArgs(1, "tol%", "tol%", 200).
+		WillReturnRows(sqlmock.NewRows([]string{"title", "authors"}).
+			AddRow("Tolkien: A Biography", "Humphrey Carpenter"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "title","authors" FROM "books" WHERE library_id IN ($1) AND (title ILIKE $2 ESCAPE '\' OR authors ILIKE $3 ESCAPE '\') `+
+		`AND NOT (title ILIKE $4 ESCAPE '\' OR authors ILIKE $5 ESCAPE '\') AND "books"."deleted_at" IS NULL LIMIT $6`)).
+		WithArgs(1, "%tol%", "%tol%", "tol%", "tol%", 199).
+		WillReturnRows(sqlmock.NewRows([]string{"title", "authors"}).
+			AddRow("The Hobbit", "J.R.R. Tolkien"))
+
+	suggestions, err := books.Suggest(ctx, []uint{1}, "tol")
+	assert.NoError(t, err)
+	assert.Equal(t, []Suggestion{{Text: "Tolkien: A Biography", Kind: "title"}, {Text: "J.R.R. Tolkien", Kind: "author"}}, suggestions)
+
+	// Wildcards in the prefix are escaped
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "books"`)).
+		WithArgs(1, `100\%%`, `100\%%`, 200).
+		WillReturnRows(sqlmock.NewRows([]string{"title", "authors"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "books"`)).
+		WithArgs(1, `%100\%%`, `%100\%%`, `100\%%`, `100\%%`, 200).
+		WillReturnRows(sqlmock.NewRows([]string{"title", "authors"}))
+
+	suggestions, err = books.Suggest(ctx, []uint{1}, "100%")
+	assert.NoError(t, err)
+	assert.Empty(t, suggestions)
+
+	// Corrections are looked up by trigram similarity under the configured threshold
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`)).
+		WithArgs("0.5").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "title","authors" FROM "books" WHERE library_id IN ($1) AND ($2 <% title OR $3 <% authors) AND "books"."deleted_at" IS NULL LIMIT $4`)).
+		WithArgs(1, "Tolkein", "Tolkein", 200).
+		WillReturnRows(sqlmock.NewRows([]string{"title", "authors"}).AddRow("The Hobbit", "J.R.R. Tolkien"))
+	mock.ExpectCommit()
+
+	suggestions, err = books.DidYouMean(ctx, []uint{1}, "Tolkein")
+	assert.NoError(t, err)
+	assert.Equal(t, []Suggestion{{Text: "J.R.R. Tolkien", Kind: "author"}}, suggestions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveBookSQL(t *testing.T) {
+	db, mock := newMockDB(t)
+	books := NewBookService(db, nil, config.Default().Search)
+	findBook := regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL ORDER BY "books"."id" LIMIT $3`)
+
+	mock.ExpectQuery(findBook).
+		WithArgs("123456789", 1, 1).
+		WillReturnError(errors.New("record not found"))
+
+	_, _, err := books.RemoveBook(actorContext(), "123456789", 1)
+	assert.ErrorIs(t, err, circulation.ErrBookNotFound)
+
+	expectLastCopy := func(inUse int) {
+		mock.ExpectQuery(findBook).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "total_copies", "available_copies"}).AddRow(3, "123456789", 1, 1, 1-inUse))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "book_copies" WHERE (book_id = $1 AND status IN ($2,$3)) AND "book_copies"."deleted_at" IS NULL`)).
+			WithArgs(3, "issued", "on_hold").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(inUse))
+	}
+
+	// A last copy that is on loan keeps the book, so its return can restock it
+	expectLastCopy(1)
+	mock.ExpectRollback()
+
+	_, _, err = books.RemoveBook(actorContext(), "123456789", 1)
+	assert.ErrorIs(t, err, circulation.ErrCopyUnavailable)
+
+	// A shelved last copy takes the book off the catalogue
+	expectLastCopy(0)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_copies" SET "status"=$1,"updated_at"=$2 WHERE book_id = $3`)).
+		WithArgs("withdrawn", sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "deleted_at"=$1 WHERE "books"."id" = $2`)).
+		WithArgs(sqlmock.AnyArg(), 3).
+		WillReturnError(errors.New("failed to delete book"))
+	mock.ExpectRollback()
+
+	_, _, err = books.RemoveBook(actorContext(), "123456789", 1)
+	assert.EqualError(t, err, "failed to delete book")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
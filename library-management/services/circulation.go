@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"library-management/audit"
+	"library-management/circulation"
+	"library-management/models"
+	"library-management/notify"
+	"library-management/pagination"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type circulationService struct {
+	db       *gorm.DB
+	circ     *circulation.Service
+	notifier *notify.Notifier
+}
+
+// NewCirculationService returns a CirculationService backed by db that lends
+// through circ and acknowledges requests through notifier, which may be nil
+func NewCirculationService(db *gorm.DB, circ *circulation.Service, notifier *notify.Notifier) CirculationService {
+	return &circulationService{db: db, circ: circ, notifier: notifier}
+}
+
+func (s *circulationService) ListRequests(ctx context.Context, filter RequestFilter) ([]models.RequestEvent, pagination.Page, error) {
+	query := s.db.WithContext(ctx).Model(&models.RequestEvent{})
+	if filter.LibraryIDs != nil {
+		query = query.Where("library_id IN (?)", filter.LibraryIDs)
+	}
+	if filter.ReaderID != 0 {
+		query = query.Where("reader_id = ?", filter.ReaderID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Type != "" {
+		query = query.Where("request_type = ?", filter.Type)
+	}
+	return pagination.Find(query, filter.Page)
+}
+
+func (s *circulationService) GetRequest(ctx context.Context, id uint) (*models.RequestEvent, error) {
+	var request models.RequestEvent
+	err := s.db.WithContext(ctx).First(&request, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, circulation.ErrRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (s *circulationService) RequestIssue(ctx context.Context, readerID uint, isbn string, libraryID uint) (*models.RequestEvent, error) {
+	db := s.db.WithContext(ctx)
+
+	book, err := findBook(db, isbn, libraryID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The book must be on the shelf, or set aside for this reader
+	if book.AvailableCopies == 0 {
+		var readyHolds int64
+		if err := db.Model(&models.Hold{}).
+			Where("isbn = ? AND library_id = ? AND reader_id = ? AND status = ?", isbn, libraryID, readerID, models.HoldReady).
+			Count(&readyHolds).Error; err != nil {
+			return nil, err
+		}
+		if readyHolds == 0 {
+			return nil, ErrBookUnavailable
+		}
+	}
+
+	if err := s.checkBorrower(db, readerID, libraryID); err != nil {
+		return nil, err
+	}
+
+	var pending int64
+	if err := db.Model(&models.RequestEvent{}).
+		Where("reader_id = ? AND book_id = ? AND library_id = ? AND status = ?", readerID, isbn, libraryID, models.RequestPending).
+		Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, ErrDuplicateRequest
+	}
+
+	request := models.RequestEvent{
+		BookID:      isbn,
+		LibraryID:   libraryID,
+		ReaderID:    readerID,
+		RequestDate: time.Now().Unix(),
+		RequestType: "issue",
+		Status:      models.RequestPending,
+	}
+
+	// Save the request and acknowledge it in the reader's inbox
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&request).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, "request.create", "request", request.ID, nil, request); err != nil {
+			return err
+		}
+		if s.notifier == nil {
+			return nil
+		}
+		return s.notifier.Notify(tx, readerID, notify.EventIssueRequested, map[string]interface{}{"Title": book.Title})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// checkBorrower fails unless the reader is registered in the library and owes it
+// no more than it allows
+func (s *circulationService) checkBorrower(db *gorm.DB, readerID, libraryID uint) error {
+	var userLibrary models.UserLibrary
+	if err := db.Where("user_id = ? AND library_id = ?", readerID, libraryID).First(&userLibrary).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotMember
+		}
+		return err
+	}
+
+	// Readers owing more than the library allows must settle their fines first
+	return s.circ.CheckBalance(db, readerID, libraryID)
+}
+
+func (s *circulationService) ApproveIssue(ctx context.Context, requestID, approverID uint, barcode string) (*models.IssueRegistry, error) {
+	return s.circ.WithContext(ctx).ApproveIssue(requestID, approverID, barcode)
+}
+
+func (s *circulationService) RejectRequest(ctx context.Context, requestID, adminID uint, reason string) (*models.RequestEvent, error) {
+	return s.circ.WithContext(ctx).RejectRequest(requestID, adminID, reason)
+}
+
+func (s *circulationService) Issue(ctx context.Context, in circulation.IssueInput) (*models.IssueRegistry, error) {
+	if err := s.checkBorrower(s.db.WithContext(ctx), in.ReaderID, in.LibraryID); err != nil {
+		return nil, err
+	}
+	return s.circ.WithContext(ctx).Issue(in)
+}
+
+func (s *circulationService) CancelRequest(ctx context.Context, requestID, readerID uint) (*models.RequestEvent, error) {
+	return s.circ.WithContext(ctx).CancelRequest(requestID, readerID)
+}
+
+func (s *circulationService) RequestReturn(ctx context.Context, readerID uint, isbn string, libraryID uint) (*models.RequestEvent, error) {
+	db := s.db.WithContext(ctx)
+
+	// The reader must currently hold this book
+	var issue models.IssueRegistry
+	err := db.Where("reader_id = ? AND isbn = ? AND library_id = ? AND issue_status = ?", readerID, isbn, libraryID, "issued").First(&issue).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, circulation.ErrNoActiveLoan
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.RequestEvent
+	err = db.Where("issue_id = ? AND request_type = ? AND status = ?", issue.ID, "return", models.RequestPending).First(&existing).Error
+	if err == nil {
+		return nil, ErrDuplicateRequest
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	issueID := issue.ID
+	request := models.RequestEvent{
+		BookID:      isbn,
+		LibraryID:   libraryID,
+		ReaderID:    readerID,
+		RequestDate: time.Now().Unix(),
+		RequestType: "return",
+		IssueID:     &issueID,
+		Status:      models.RequestPending,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&request).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, "request.create", "request", request.ID, nil, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (s *circulationService) ApproveReturn(ctx context.Context, requestID, approverID uint, condition string) (*models.IssueRegistry, *models.Fine, error) {
+	return s.circ.WithContext(ctx).ApproveReturn(requestID, approverID, condition)
+}
+
+func (s *circulationService) ReturnCopy(ctx context.Context, barcode string, approverID uint, condition string) (*models.IssueRegistry, *models.Fine, error) {
+	return s.circ.WithContext(ctx).ReturnCopy(barcode, approverID, condition)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"library-management/circulation"
+	"library-management/config"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestListRequestsSQL(t *testing.T) {
+	db, mock := newMockDB(t)
+	requests := NewCirculationService(db, nil, nil)
+	ctx := context.Background()
+	requestColumns := []string{"id", "book_id", "library_id", "reader_id", "request_date", "request_type", "status"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE library_id IN ($1) AND "request_events"."deleted_at" IS NULL ORDER BY request_date, id LIMIT $2`)).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows(requestColumns).
+			AddRow(1, "123456789", 1, 2, 1741651200, "issue", "pending").
+			AddRow(2, "987654321", 1, 3, 1741651200, "issue", "pending"))
+
+	list, page, err := requests.ListRequests(ctx, RequestFilter{LibraryIDs: []uint{1}, Page: parsePage(t, RequestSort, "limit=1")})
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "123456789", list[0].BookID)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+
+	// The next page continues after the request date and ID of the last request
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE library_id IN ($1) AND ((request_date > $2) OR (request_date = $3 AND id > $4)) AND "request_events"."deleted_at" IS NULL ORDER BY request_date, id LIMIT $5`)).
+		WithArgs(1, 1741651200, 1741651200, 1, 2).
+		WillReturnRows(sqlmock.NewRows(requestColumns).
+			AddRow(2, "987654321", 1, 3, 1741651200, "issue", "pending"))
+
+	list, page, err = requests.ListRequests(ctx, RequestFilter{LibraryIDs: []uint{1}, Page: parsePage(t, RequestSort, "limit=1&cursor="+page.NextCursor)})
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "987654321", list[0].BookID)
+	}
+	assert.Empty(t, page.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestIssueSQL(t *testing.T) {
+	db, mock := newMockDB(t)
+	requests := NewCirculationService(db, circulation.NewService(db, config.Default().Circulation), nil)
+
+	expectChecks := func(balanceCents int) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL`)).
+			WithArgs("123456789", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"isbn", "library_id", "available_copies"}).AddRow("123456789", 1, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(2, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}).AddRow(2, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "circulation_policies" WHERE library_id = $1`)).
+			WithArgs(1, 1).
+			WillReturnError(gorm.ErrRecordNotFound)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(amount_cents), 0) FROM "ledger_entries" WHERE (reader_id = $1 AND library_id = $2)`)).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(balanceCents))
+	}
+
+	expectChecks(0)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "request_events" WHERE (reader_id = $1 AND book_id = $2 AND library_id = $3 AND status = $4) AND "request_events"."deleted_at" IS NULL`)).
+		WithArgs(2, "123456789", 1, "pending").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "request_events"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectAudit(mock, "request.create", "request", "1")
+	mock.ExpectCommit()
+
+	request, err := requests.RequestIssue(actorContext(), 2, "123456789", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), request.ID)
+
+	// Readers owing more than the library allows are refused before anything is written
+	expectChecks(750)
+
+	_, err = requests.RequestIssue(actorContext(), 2, "123456789", 1)
+	assert.ErrorIs(t, err, circulation.ErrBalanceLimit)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIssueSQL(t *testing.T) {
+	db, mock := newMockDB(t)
+	requests := NewCirculationService(db, circulation.NewService(db, config.Default().Circulation), nil)
+	in := circulation.IssueInput{ISBN: "123456789", LibraryID: 1, ReaderID: 2, ApproverID: 9}
+
+	// Books are only issued to readers registered in the library
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+		WithArgs(2, 1, 1).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	_, err := requests.Issue(actorContext(), in)
+	assert.ErrorIs(t, err, ErrNotMember)
+
+	// who owe no more than it allows
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+		WithArgs(2, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}).AddRow(2, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "circulation_policies" WHERE library_id = $1`)).
+		WithArgs(1, 1).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(amount_cents), 0) FROM "ledger_entries" WHERE (reader_id = $1 AND library_id = $2)`)).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(750))
+
+	_, err = requests.Issue(actorContext(), in)
+	assert.ErrorIs(t, err, circulation.ErrBalanceLimit)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"library-management/audit"
+	"library-management/circulation"
+	"library-management/models"
+	"library-management/pagination"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (s *bookService) AddCopy(ctx context.Context, isbn string, libraryID uint, input circulation.CopyInput) (*models.BookCopy, error) {
+	var added []models.BookCopy
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var book models.Book
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("isbn = ? AND library_id = ?", isbn, libraryID).First(&book).Error; err != nil {
+			return err
+		}
+
+		var err error
+		added, err = s.circ.AddCopies(tx, &book, input)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, "copy.create", "copy", added[0].ID, nil, added[0])
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, circulation.ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &added[0], nil
+}
+
+func (s *bookService) ListCopies(ctx context.Context, isbn string, libraryID uint, page pagination.Request[models.BookCopy]) (*models.Book, []models.BookCopy, pagination.Page, error) {
+	db := s.db.WithContext(ctx)
+
+	book, err := findBook(db, isbn, libraryID)
+	if err != nil {
+		return nil, nil, pagination.Page{}, err
+	}
+	copies, p, err := pagination.Find(db.Model(&models.BookCopy{}).Where("book_id = ?", book.ID), page)
+	return book, copies, p, err
+}
+
+func (s *bookService) GetCopy(ctx context.Context, barcode string) (*models.BookCopy, *models.Book, error) {
+	db := s.db.WithContext(ctx)
+
+	var bookCopy models.BookCopy
+	err := db.Where("barcode = ?", barcode).First(&bookCopy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, circulation.ErrCopyNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var book models.Book
+	err = db.First(&book, bookCopy.BookID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, circulation.ErrBookNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &bookCopy, &book, nil
+}
+
+func (s *bookService) UpdateCopy(ctx context.Context, barcode string, update CopyUpdate) (*models.BookCopy, *models.Book, error) {
+	bookCopy, book, err := s.GetCopy(ctx, barcode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(book, book.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(bookCopy, bookCopy.ID).Error; err != nil {
+			return err
+		}
+		before := *bookCopy
+
+		if update.Status != "" && (bookCopy.Status == models.CopyIssued || bookCopy.Status == models.CopyOnHold) {
+			return circulation.ErrCopyUnavailable
+		}
+
+		if update.Condition != nil {
+			bookCopy.Condition = *update.Condition
+		}
+		if update.ShelfLocation != nil {
+			bookCopy.ShelfLocation = *update.ShelfLocation
+		}
+		wasAvailable := bookCopy.Status == models.CopyAvailable
+		if update.Status != "" {
+			bookCopy.Status = update.Status
+		}
+		if err := tx.Save(bookCopy).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, "copy.update", "copy", bookCopy.ID, before, bookCopy); err != nil {
+			return err
+		}
+
+		// A repaired or found copy goes to the next reader waiting for the title
+		if update.Status == models.CopyAvailable && !wasAvailable {
+			if err := s.circ.ShelveCopy(tx, book, bookCopy.ID); err != nil {
+				return err
+			}
+		}
+		return circulation.SyncCopyCounts(tx, book)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return bookCopy, book, nil
+}
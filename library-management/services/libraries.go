@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"library-management/audit"
+	"library-management/models"
+	"library-management/pagination"
+
+	"gorm.io/gorm"
+)
+
+type libraryService struct {
+	db *gorm.DB
+}
+
+// NewLibraryService returns a LibraryService backed by db
+func NewLibraryService(db *gorm.DB) LibraryService {
+	return &libraryService{db: db}
+}
+
+func (s *libraryService) CreateLibrary(ctx context.Context, library *models.Library) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(library).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, "library.create", "library", library.ID, nil, library)
+	})
+}
+
+func (s *libraryService) GetLibrary(ctx context.Context, id uint) (*models.Library, error) {
+	var library models.Library
+	err := s.db.WithContext(ctx).First(&library, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &LibraryNotFoundError{ID: id}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &library, nil
+}
+
+func (s *libraryService) ListLibraries(ctx context.Context, page pagination.Request[models.Library]) ([]models.Library, pagination.Page, error) {
+	return pagination.Find(s.db.WithContext(ctx).Model(&models.Library{}), page)
+}
+
+func (s *libraryService) LibraryIDs(ctx context.Context, userID uint) ([]uint, error) {
+	var ids []uint
+	err := s.db.WithContext(ctx).Table("user_libraries").Where("user_id = ?", userID).Pluck("library_id", &ids).Error
+	return ids, err
+}
+
+func (s *libraryService) IsMember(ctx context.Context, userID, libraryID uint) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Table("user_libraries").Where("user_id = ? AND library_id = ?", userID, libraryID).Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"library-management/audit"
+	"library-management/models"
+	"library-management/pagination"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newMockDB opens GORM on a sqlmock connection speaking Postgres
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+	return gormDB, mock
+}
+
+// parsePage reads a page request from a query string, as the handlers do
+func parsePage[T any](t *testing.T, spec pagination.Spec[T], query string) pagination.Request[T] {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/?"+query, nil)
+	page, err := pagination.Parse(c, spec)
+	assert.NoError(t, err)
+	return page
+}
+
+// actorContext is the context of a request made by an owner, which changes are audited under
+func actorContext() context.Context {
+	ownerID := uint(9)
+	return audit.WithActor(context.Background(), audit.Actor{ID: &ownerID, Role: "owner"})
+}
+
+// expectAudit expects one audit event for a change to an entity
+func expectAudit(mock sqlmock.Sqlmock, action, entityType string, entityID interface{}) {
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_events"`)).
+		WithArgs(sqlmock.AnyArg(), 9, "owner", action, entityType, entityID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+func TestCreateLibrary(t *testing.T) {
+	db, mock := newMockDB(t)
+	libraries := NewLibraryService(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "libraries" ("name") VALUES ($1) RETURNING "id"`)).
+		WithArgs("Central Library").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	expectAudit(mock, "library.create", "library", "4")
+	mock.ExpectCommit()
+
+	library := models.Library{Name: "Central Library"}
+	assert.NoError(t, libraries.CreateLibrary(actorContext(), &library))
+	assert.Equal(t, uint(4), library.ID)
+
+	// A duplicate name is refused by the unique index and nothing is committed
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "libraries"`)).
+		WithArgs("Central Library").
+		WillReturnError(errors.New("duplicate key value violates unique constraint"))
+	mock.ExpectRollback()
+
+	assert.Error(t, libraries.CreateLibrary(actorContext(), &models.Library{Name: "Central Library"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListLibraries(t *testing.T) {
+	db, mock := newMockDB(t)
+	libraries := NewLibraryService(db)
+	ctx := context.Background()
+
+	// One row past the limit tells the caller another page follows
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "libraries" ORDER BY name DESC, id DESC LIMIT $1 OFFSET $2`)).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(3, "Eastside Library").
+			AddRow(2, "Downtown Library").
+			AddRow(1, "Central Library"))
+
+	list, page, err := libraries.ListLibraries(ctx, parsePage(t, LibrarySort, "limit=2&offset=1&sort=-name"))
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "Eastside Library", list[0].Name)
+		assert.Equal(t, "Downtown Library", list[1].Name)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "libraries"`)).
+		WillReturnError(errors.New("database timeout"))
+
+	_, _, err = libraries.ListLibraries(ctx, parsePage(t, LibrarySort, ""))
+	assert.EqualError(t, err, "database timeout")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLibraryMembership(t *testing.T) {
+	db, mock := newMockDB(t)
+	libraries := NewLibraryService(db)
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1).AddRow(3))
+
+	ids, err := libraries.LibraryIDs(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{1, 3}, ids)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	member, err := libraries.IsMember(ctx, 1, 3)
+	assert.NoError(t, err)
+	assert.True(t, member)
+	member, err = libraries.IsMember(ctx, 1, 2)
+	assert.NoError(t, err)
+	assert.False(t, member)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
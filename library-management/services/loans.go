@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"library-management/audit"
+	"library-management/circulation"
+	"library-management/models"
+	"library-management/pagination"
+
+	"gorm.io/gorm"
+)
+
+func (s *circulationService) GetLoan(ctx context.Context, id uint) (*models.IssueRegistry, error) {
+	var loan models.IssueRegistry
+	err := s.db.WithContext(ctx).First(&loan, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, circulation.ErrLoanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+func (s *circulationService) ListLoans(ctx context.Context, filter LoanFilter) ([]models.IssueRegistry, pagination.Page, error) {
+	query := s.db.WithContext(ctx).Model(&models.IssueRegistry{})
+	if filter.ReaderID != 0 {
+		query = query.Where("reader_id = ?", filter.ReaderID)
+	}
+	if filter.Status != "" {
+		query = query.Where("issue_status = ?", filter.Status)
+	}
+	return pagination.Find(query, filter.Page)
+}
+
+func (s *circulationService) RenewLoan(ctx context.Context, loanID, renewedByID uint) (*models.IssueRegistry, error) {
+	return s.circ.WithContext(ctx).Renew(loanID, renewedByID)
+}
+
+func (s *circulationService) ListRenewals(ctx context.Context, loanID uint, page pagination.Request[models.LoanRenewal]) ([]models.LoanRenewal, pagination.Page, error) {
+	return pagination.Find(s.db.WithContext(ctx).Model(&models.LoanRenewal{}).Where("issue_id = ?", loanID), page)
+}
+
+func (s *circulationService) PlaceHold(ctx context.Context, readerID uint, isbn string, libraryID uint) (*models.Hold, error) {
+	return s.circ.WithContext(ctx).PlaceHold(isbn, libraryID, readerID)
+}
+
+func (s *circulationService) ListHolds(ctx context.Context, readerID uint, page pagination.Request[models.Hold]) ([]HoldResult, pagination.Page, error) {
+	db := s.db.WithContext(ctx)
+	holds, p, err := pagination.Find(db.Model(&models.Hold{}).Where("reader_id = ?", readerID), page)
+	if err != nil {
+		return nil, p, err
+	}
+
+	positions, err := circulation.QueuePositions(db, holds)
+	if err != nil {
+		return nil, p, err
+	}
+	results := make([]HoldResult, len(holds))
+	for i, hold := range holds {
+		results[i] = HoldResult{Hold: hold, Position: positions[hold.ID]}
+	}
+	return results, p, nil
+}
+
+func (s *circulationService) QueuePosition(ctx context.Context, hold *models.Hold) (int64, error) {
+	return circulation.QueuePosition(s.db.WithContext(ctx), hold)
+}
+
+func (s *circulationService) CancelHold(ctx context.Context, holdID, readerID uint) (*models.Hold, error) {
+	return s.circ.WithContext(ctx).CancelHold(holdID, readerID)
+}
+
+func (s *circulationService) ListFines(ctx context.Context, readerID uint, page pagination.Request[models.Fine]) ([]models.Fine, pagination.Page, error) {
+	return pagination.Find(s.db.WithContext(ctx).Model(&models.Fine{}).Where("reader_id = ?", readerID), page)
+}
+
+func (s *circulationService) GetFine(ctx context.Context, id uint) (*models.Fine, error) {
+	var fine models.Fine
+	err := s.db.WithContext(ctx).First(&fine, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, circulation.ErrFineNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fine, nil
+}
+
+func (s *circulationService) Balances(ctx context.Context, readerID uint) ([]AccountBalance, error) {
+	var balances []AccountBalance
+	err := s.db.WithContext(ctx).Model(&models.LedgerEntry{}).
+		Select("library_id, SUM(amount_cents) AS balance_cents").
+		Where("reader_id = ?", readerID).
+		Group("library_id").
+		Scan(&balances).Error
+	return balances, err
+}
+
+func (s *circulationService) Balance(ctx context.Context, readerID, libraryID uint) (int64, error) {
+	return circulation.Balance(s.db.WithContext(ctx), readerID, libraryID)
+}
+
+func (s *circulationService) RecordPayment(ctx context.Context, readerID, libraryID uint, amountCents int64, note string, recordedByID uint) (*models.LedgerEntry, error) {
+	return s.circ.WithContext(ctx).RecordPayment(readerID, libraryID, amountCents, note, recordedByID)
+}
+
+func (s *circulationService) WaiveFine(ctx context.Context, fineID, waivedByID uint, reason string) (*models.Fine, error) {
+	return s.circ.WithContext(ctx).WaiveFine(fineID, waivedByID, reason)
+}
+
+func (s *circulationService) Policy(ctx context.Context, libraryID uint) (models.CirculationPolicy, error) {
+	return s.circ.Policy(s.db.WithContext(ctx), libraryID)
+}
+
+func (s *circulationService) UpdatePolicy(ctx context.Context, libraryID uint, update PolicyUpdate) (models.CirculationPolicy, error) {
+	db := s.db.WithContext(ctx)
+
+	policy, err := s.circ.Policy(db, libraryID)
+	if err != nil {
+		return policy, err
+	}
+	before := policy
+	update.apply(&policy)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&policy).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, "policy.update", "library", libraryID, before, policy)
+	})
+	return policy, err
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"library-management/models"
+	"library-management/pagination"
+
+	"gorm.io/gorm"
+)
+
+type auditService struct {
+	db *gorm.DB
+}
+
+// NewAuditService returns an AuditService that reads the audit log in db
+func NewAuditService(db *gorm.DB) AuditService {
+	return &auditService{db: db}
+}
+
+func (s *auditService) ListEvents(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, pagination.Page, error) {
+	query := s.db.WithContext(ctx).Model(&models.AuditEvent{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.From != nil {
+		query = query.Where("occurred_at >= ?", filter.From.Unix())
+	}
+	if filter.To != nil {
+		query = query.Where("occurred_at <= ?", filter.To.Unix())
+	}
+	return pagination.Find(query, filter.Page)
+}
+
+type jobService struct {
+	db *gorm.DB
+}
+
+// NewJobService returns a JobService that reads the job runs recorded in db
+func NewJobService(db *gorm.DB) JobService {
+	return &jobService{db: db}
+}
+
+func (s *jobService) ListRuns(ctx context.Context, job string, page pagination.Request[models.JobRun]) ([]models.JobRun, pagination.Page, error) {
+	query := s.db.WithContext(ctx).Model(&models.JobRun{})
+	if job != "" {
+		query = query.Where("job = ?", job)
+	}
+	return pagination.Find(query, page)
+}
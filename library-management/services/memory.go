@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"library-management/models"
+	"library-management/pagination"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory keeps libraries, users, inboxes, the audit log and job runs in maps
+// guarded by one lock, for tests of handlers that only store and read them.
+// It holds no circulation or sign-in rules: books, requests, loans and sessions
+// are only served by the GORM services, which tests run on SQLite. The inbox,
+// the audit log and job runs hold only what Notify, AddEvent and AddRun put there.
+type Memory struct {
+	mu            sync.Mutex
+	nextID        uint
+	libraries     map[uint]*models.Library
+	users         map[uint]*models.User
+	members       map[uint]map[uint]bool // User ID to the libraries they are registered in
+	notifications map[uint]*models.Notification
+	preferences   map[uint]map[string]bool // User ID to the channels they turned on or off
+	events        []models.AuditEvent
+	runs          []models.JobRun
+}
+
+var (
+	_ LibraryService      = (*Memory)(nil)
+	_ UserService         = (*Memory)(nil)
+	_ NotificationService = (*Memory)(nil)
+	_ AuditService        = (*Memory)(nil)
+	_ JobService          = (*Memory)(nil)
+)
+
+// NewMemory returns an empty Memory
+func NewMemory() *Memory {
+	return &Memory{
+		libraries:     map[uint]*models.Library{},
+		users:         map[uint]*models.User{},
+		members:       map[uint]map[uint]bool{},
+		notifications: map[uint]*models.Notification{},
+		preferences:   map[uint]map[string]bool{},
+	}
+}
+
+// Notify puts a message in a user's inbox
+func (m *Memory) Notify(userID uint, event, subject, body string) models.Notification {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	notification := models.Notification{UserID: userID, Event: event, Subject: subject, Body: body}
+	notification.ID = m.id()
+	notification.CreatedAt = time.Now()
+	stored := notification
+	m.notifications[notification.ID] = &stored
+	return notification
+}
+
+// AddEvent appends an event to the audit log
+func (m *Memory) AddEvent(event models.AuditEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event.ID = m.id()
+	m.events = append(m.events, event)
+}
+
+// AddRun records a background job run
+func (m *Memory) AddRun(run models.JobRun) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	run.ID = m.id()
+	m.runs = append(m.runs, run)
+}
+
+// id hands out the next identifier; IDs are unique across every kind of record
+func (m *Memory) id() uint {
+	m.nextID++
+	return m.nextID
+}
+
+func (m *Memory) CreateLibrary(ctx context.Context, library *models.Library) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.libraries {
+		if existing.Name == library.Name {
+			return fmt.Errorf("library %q already exists", library.Name)
+		}
+	}
+	library.ID = m.id()
+	stored := *library
+	m.libraries[library.ID] = &stored
+	return nil
+}
+
+func (m *Memory) GetLibrary(ctx context.Context, id uint) (*models.Library, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	library, ok := m.libraries[id]
+	if !ok {
+		return nil, &LibraryNotFoundError{ID: id}
+	}
+	found := *library
+	return &found, nil
+}
+
+func (m *Memory) ListLibraries(ctx context.Context, page pagination.Request[models.Library]) ([]models.Library, pagination.Page, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	libraries := make([]models.Library, 0, len(m.libraries))
+	for _, library := range m.libraries {
+		libraries = append(libraries, *library)
+	}
+	sort.Slice(libraries, func(i, j int) bool { return libraries[i].ID < libraries[j].ID })
+	libraries, p := pagination.Slice(libraries, page)
+	return libraries, p, nil
+}
+
+func (m *Memory) LibraryIDs(ctx context.Context, userID uint) ([]uint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]uint, 0, len(m.members[userID]))
+	for id := range m.members[userID] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (m *Memory) IsMember(ctx context.Context, userID, libraryID uint) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.members[userID][libraryID], nil
+}
+
+func (m *Memory) GetUser(ctx context.Context, id uint) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	found := *user
+	found.Library = nil
+	for libID := range m.members[id] {
+		found.Library = append(found.Library, *m.libraries[libID])
+	}
+	sort.Slice(found.Library, func(i, j int) bool { return found.Library[i].ID < found.Library[j].ID })
+	return &found, nil
+}
+
+func (m *Memory) CreateUser(ctx context.Context, user *models.User, libraryIDs []uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.users {
+		if existing.Email == user.Email {
+			return ErrEmailTaken
+		}
+	}
+	for _, libID := range libraryIDs {
+		if _, ok := m.libraries[libID]; !ok {
+			return &LibraryNotFoundError{ID: libID}
+		}
+	}
+
+	user.ID = m.id()
+	stored := *user
+	m.users[user.ID] = &stored
+	m.members[user.ID] = map[uint]bool{}
+	for _, libID := range libraryIDs {
+		m.members[user.ID][libID] = true
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"library-management/models"
+	"library-management/pagination"
+	"sort"
+	"time"
+)
+
+// memoryChannels are the channels Memory offers; it delivers nothing beyond the inbox
+var memoryChannels = []string{"inbox"}
+
+func (m *Memory) ListNotifications(ctx context.Context, userID uint, unreadOnly bool, page pagination.Request[models.Notification]) ([]models.Notification, pagination.Page, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var notifications []models.Notification
+	for _, notification := range m.notifications {
+		if notification.UserID == userID && (!unreadOnly || notification.ReadAt == nil) {
+			notifications = append(notifications, *notification)
+		}
+	}
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].ID < notifications[j].ID })
+	notifications, p := pagination.Slice(notifications, page)
+	return notifications, p, nil
+}
+
+func (m *Memory) UnreadCount(ctx context.Context, userID uint) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var unread int64
+	for _, notification := range m.notifications {
+		if notification.UserID == userID && notification.ReadAt == nil {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+func (m *Memory) MarkRead(ctx context.Context, userID, id uint) (*models.Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	notification, ok := m.notifications[id]
+	if !ok || notification.UserID != userID {
+		return nil, ErrNotificationNotFound
+	}
+	if notification.ReadAt == nil {
+		readAt := time.Now().Unix()
+		notification.ReadAt = &readAt
+	}
+	read := *notification
+	return &read, nil
+}
+
+func (m *Memory) MarkAllRead(ctx context.Context, userID uint) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	readAt := time.Now().Unix()
+	var marked int64
+	for _, notification := range m.notifications {
+		if notification.UserID == userID && notification.ReadAt == nil {
+			at := readAt
+			notification.ReadAt = &at
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (m *Memory) Preferences(ctx context.Context, userID uint) ([]ChannelPreference, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.channelPreferences(userID), nil
+}
+
+func (m *Memory) UpdatePreferences(ctx context.Context, userID uint, channels map[string]bool) ([]ChannelPreference, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	configured := make(map[string]bool)
+	for _, name := range memoryChannels {
+		configured[name] = true
+	}
+	for name := range channels {
+		if !configured[name] {
+			return nil, &UnknownChannelError{Channel: name}
+		}
+	}
+	if m.preferences[userID] == nil {
+		m.preferences[userID] = map[string]bool{}
+	}
+	for name, enabled := range channels {
+		m.preferences[userID][name] = enabled
+	}
+	return m.channelPreferences(userID), nil
+}
+
+// channelPreferences reports a user's channels as the GORM service does; the caller holds the lock
+func (m *Memory) channelPreferences(userID uint) []ChannelPreference {
+	var saved []models.NotificationPreference
+	for name, enabled := range m.preferences[userID] {
+		saved = append(saved, models.NotificationPreference{UserID: userID, Channel: name, Enabled: enabled})
+	}
+	return channelPreferences(memoryChannels, saved)
+}
+
+func (m *Memory) ListEvents(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, pagination.Page, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []models.AuditEvent
+	for _, event := range m.events {
+		if filter.ActorID != 0 && (event.ActorID == nil || *event.ActorID != filter.ActorID) {
+			continue
+		}
+		if (filter.EntityType != "" && event.EntityType != filter.EntityType) ||
+			(filter.EntityID != "" && event.EntityID != filter.EntityID) ||
+			(filter.From != nil && event.OccurredAt < filter.From.Unix()) ||
+			(filter.To != nil && event.OccurredAt > filter.To.Unix()) {
+			continue
+		}
+		events = append(events, event)
+	}
+	events, page := pagination.Slice(events, filter.Page)
+	return events, page, nil
+}
+
+func (m *Memory) ListRuns(ctx context.Context, job string, page pagination.Request[models.JobRun]) ([]models.JobRun, pagination.Page, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var runs []models.JobRun
+	for _, run := range m.runs {
+		if job == "" || run.Job == job {
+			runs = append(runs, run)
+		}
+	}
+	runs, p := pagination.Slice(runs, page)
+	return runs, p, nil
+}
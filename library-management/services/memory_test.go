@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"library-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newLibrary returns a Memory with one library, an admin and a reader registered in it
+func newLibrary(t *testing.T) (*Memory, uint, uint, uint) {
+	t.Helper()
+	ctx := context.Background()
+	m := NewMemory()
+
+	library := models.Library{Name: "Central"}
+	assert.NoError(t, m.CreateLibrary(ctx, &library))
+	admin := models.User{Name: "Admin", Email: "admin@example.com", Role: "admin"}
+	assert.NoError(t, m.CreateUser(ctx, &admin, []uint{library.ID}))
+	reader := models.User{Name: "Reader", Email: "reader@example.com", Role: "user"}
+	assert.NoError(t, m.CreateUser(ctx, &reader, []uint{library.ID}))
+	return m, library.ID, admin.ID, reader.ID
+}
+
+func TestMemoryCreateUser(t *testing.T) {
+	m, libID, _, readerID := newLibrary(t)
+	ctx := context.Background()
+
+	user, err := m.GetUser(ctx, readerID)
+	assert.NoError(t, err)
+	assert.Equal(t, "reader@example.com", user.Email)
+	if assert.Len(t, user.Library, 1) {
+		assert.Equal(t, libID, user.Library[0].ID)
+	}
+
+	err = m.CreateUser(ctx, &models.User{Email: "reader@example.com"}, nil)
+	assert.ErrorIs(t, err, ErrEmailTaken)
+
+	err = m.CreateUser(ctx, &models.User{Email: "new@example.com"}, []uint{99})
+	assert.ErrorIs(t, err, ErrLibraryNotFound)
+	assert.EqualError(t, err, "library 99 not found")
+
+	_, err = m.GetUser(ctx, 99)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestMemoryMembership(t *testing.T) {
+	m, libID, adminID, _ := newLibrary(t)
+	ctx := context.Background()
+	other := models.Library{Name: "Branch"}
+	assert.NoError(t, m.CreateLibrary(ctx, &other))
+
+	ids, err := m.LibraryIDs(ctx, adminID)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{libID}, ids)
+
+	member, err := m.IsMember(ctx, adminID, libID)
+	assert.NoError(t, err)
+	assert.True(t, member)
+	member, err = m.IsMember(ctx, adminID, other.ID)
+	assert.NoError(t, err)
+	assert.False(t, member)
+
+	assert.Error(t, m.CreateLibrary(ctx, &models.Library{Name: "Branch"}))
+}
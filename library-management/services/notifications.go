@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"library-management/models"
+	"library-management/notify"
+	"library-management/pagination"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type notificationService struct {
+	db       *gorm.DB
+	notifier *notify.Notifier
+}
+
+// NewNotificationService returns a NotificationService backed by db that offers
+// the channels configured in notifier
+func NewNotificationService(db *gorm.DB, notifier *notify.Notifier) NotificationService {
+	return &notificationService{db: db, notifier: notifier}
+}
+
+func (s *notificationService) ListNotifications(ctx context.Context, userID uint, unreadOnly bool, page pagination.Request[models.Notification]) ([]models.Notification, pagination.Page, error) {
+	query := s.db.WithContext(ctx).Model(&models.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	return pagination.Find(query, page)
+}
+
+func (s *notificationService) UnreadCount(ctx context.Context, userID uint) (int64, error) {
+	var unread int64
+	err := s.db.WithContext(ctx).Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread).Error
+	return unread, err
+}
+
+func (s *notificationService) MarkRead(ctx context.Context, userID, id uint) (*models.Notification, error) {
+	db := s.db.WithContext(ctx)
+
+	var notification models.Notification
+	err := db.Where("id = ? AND user_id = ?", id, userID).First(&notification).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotificationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if notification.ReadAt == nil {
+		readAt := time.Now().Unix()
+		if err := db.Model(&notification).Update("read_at", readAt).Error; err != nil {
+			return nil, err
+		}
+		notification.ReadAt = &readAt
+	}
+	return &notification, nil
+}
+
+func (s *notificationService) MarkAllRead(ctx context.Context, userID uint) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now().Unix())
+	return result.RowsAffected, result.Error
+}
+
+func (s *notificationService) Preferences(ctx context.Context, userID uint) ([]ChannelPreference, error) {
+	var saved []models.NotificationPreference
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	return channelPreferences(s.notifier.Channels(), saved), nil
+}
+
+func (s *notificationService) UpdatePreferences(ctx context.Context, userID uint, channels map[string]bool) ([]ChannelPreference, error) {
+	configured := make(map[string]bool)
+	for _, name := range s.notifier.Channels() {
+		configured[name] = true
+	}
+	rows := make([]models.NotificationPreference, 0, len(channels))
+	for name, enabled := range channels {
+		if !configured[name] {
+			return nil, &UnknownChannelError{Channel: name}
+		}
+		rows = append(rows, models.NotificationPreference{UserID: userID, Channel: name, Enabled: enabled})
+	}
+
+	if len(rows) > 0 {
+		if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+		}).Create(&rows).Error; err != nil {
+			return nil, err
+		}
+	}
+	return s.Preferences(ctx, userID)
+}
+
+// channelPreferences reports each channel as on or off given a user's saved
+// preferences, applying defaults for the rest
+func channelPreferences(channels []string, saved []models.NotificationPreference) []ChannelPreference {
+	enabled := make(map[string]bool)
+	for _, p := range saved {
+		enabled[p.Channel] = p.Enabled
+	}
+
+	preferences := make([]ChannelPreference, len(channels))
+	for i, name := range channels {
+		on, ok := enabled[name]
+		if !ok {
+			on = notify.DefaultEnabled(name)
+		}
+		preferences[i] = ChannelPreference{Channel: name, Enabled: on}
+	}
+	return preferences
+}
@@ -0,0 +1,447 @@
+// Package services holds the domain operations the HTTP handlers depend on.
+// Each service has a GORM implementation used by the server. The rules live
+// only there; handler tests run them on an in-memory SQLite database. Memory
+// stands in for the services that only store and read records.
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"library-management/circulation"
+	"library-management/models"
+	"library-management/pagination"
+	"library-management/utils"
+	"time"
+)
+
+var (
+	ErrLibraryNotFound  = errors.New("library not found")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrEmailTaken       = errors.New("email already registered")
+	ErrNotMember        = errors.New("user is not registered in this library")
+	ErrBookUnavailable  = errors.New("book not available for issue")
+	ErrDuplicateRequest = errors.New("a pending request for this book already exists")
+	ErrIssuedCopies     = errors.New("total copies cannot be less than issued copies")
+
+	ErrInvalidCredentials   = errors.New("invalid credentials")
+	ErrInvalidRefreshToken  = errors.New("invalid refresh token")
+	ErrRefreshTokenReused   = errors.New("refresh token already rotated")
+	ErrRefreshTokenExpired  = errors.New("refresh token expired")
+	ErrNotificationNotFound = errors.New("notification not found")
+	ErrUnknownChannel       = errors.New("unknown notification channel")
+)
+
+// LibraryNotFoundError names a library that does not exist; it matches ErrLibraryNotFound
+type LibraryNotFoundError struct {
+	ID uint
+}
+
+func (e *LibraryNotFoundError) Error() string {
+	return fmt.Sprintf("library %d not found", e.ID)
+}
+
+func (e *LibraryNotFoundError) Is(target error) bool {
+	return target == ErrLibraryNotFound
+}
+
+// UnknownChannelError names a notification channel that is not configured; it matches ErrUnknownChannel
+type UnknownChannelError struct {
+	Channel string
+}
+
+func (e *UnknownChannelError) Error() string {
+	return fmt.Sprintf("unknown notification channel %q", e.Channel)
+}
+
+func (e *UnknownChannelError) Is(target error) bool {
+	return target == ErrUnknownChannel
+}
+
+// LibraryService manages libraries and the users registered in them
+type LibraryService interface {
+	CreateLibrary(ctx context.Context, library *models.Library) error
+	GetLibrary(ctx context.Context, id uint) (*models.Library, error)
+	ListLibraries(ctx context.Context, page pagination.Request[models.Library]) ([]models.Library, pagination.Page, error)
+	// LibraryIDs lists the libraries a user is registered in, or administers
+	LibraryIDs(ctx context.Context, userID uint) ([]uint, error)
+	// IsMember reports whether a user is registered in, or administers, a library
+	IsMember(ctx context.Context, userID, libraryID uint) (bool, error)
+}
+
+// LibrarySort lists the fields libraries can be sorted by
+var LibrarySort = pagination.Spec[models.Library]{
+	Fields: []pagination.Field[models.Library]{
+		{Name: "id", Column: "id", Value: func(l models.Library) any { return l.ID }},
+		{Name: "name", Column: "name", Value: func(l models.Library) any { return l.Name }},
+	},
+	Default: "id",
+}
+
+// BookUpdate holds the editable details of a book
+type BookUpdate struct {
+	Title       string
+	Authors     string
+	Publisher   string
+	Version     string
+	TotalCopies int
+}
+
+// BookQuery narrows a catalogue search; empty fields match everything
+type BookQuery struct {
+	Text      string // Full-text search over all three fields, see search.Parse
+	Fuzzy     bool   // Match Text by trigram similarity to titles and authors instead, forgiving typos
+	Title     string
+	Author    string
+	Publisher string
+	Page      pagination.Request[BookResult] // Sorted by BookSort, or RankedBookSort with Text
+}
+
+// BookResult is a search hit with what a reader needs when no copy is on the shelf
+type BookResult struct {
+	models.Book
+	Rank            float64 // How well the book matches Text, best first
+	Headline        string  // Title, authors and publisher with the matched words in <b></b>
+	NextAvailableAt *int64  // Earliest due date of a loan, when every copy is out
+	HoldsWaiting    int64
+}
+
+var bookFields = []pagination.Field[BookResult]{
+	{Name: "id", Column: "id", Value: func(b BookResult) any { return b.ID }},
+	{Name: "title", Column: "title", Value: func(b BookResult) any { return b.Title }},
+	{Name: "authors", Column: "authors", Value: func(b BookResult) any { return b.Authors }},
+	{Name: "publisher", Column: "publisher", Value: func(b BookResult) any { return b.Publisher }},
+	{Name: "available_copies", Column: "available_copies", Value: func(b BookResult) any { return b.AvailableCopies }},
+}
+
+// BookSort lists the fields search results can be sorted by
+var BookSort = pagination.Spec[BookResult]{Fields: bookFields, Default: "title"}
+
+// RankedBookSort is BookSort for a search with Text, which can also be sorted
+// by rank and is by default, best first
+var RankedBookSort = pagination.Spec[BookResult]{
+	Fields: append(bookFields[:len(bookFields):len(bookFields)],
+		pagination.Field[BookResult]{Name: "rank", Column: "rank", Value: func(b BookResult) any { return b.Rank }}),
+	Default: "-rank",
+}
+
+// Kinds of Suggestion
+const (
+	SuggestTitle  = "title"
+	SuggestAuthor = "author"
+)
+
+// Suggestion is a title or author name offered to a reader as they type, or
+// in place of a search that found nothing
+type Suggestion struct {
+	Text string
+	Kind string
+}
+
+// BookService manages the books of a library and their copies
+type BookService interface {
+	// AddBook adds a title with book.TotalCopies copies, or that many more copies
+	// when the library already has it; created reports which
+	AddBook(ctx context.Context, book models.Book) (added *models.Book, created bool, err error)
+	UpdateBook(ctx context.Context, isbn string, libraryID uint, update BookUpdate) (*models.Book, error)
+	// RemoveBook withdraws one available copy, or removes the title when it has
+	// a single copy; removed reports the latter. A single copy on loan or set
+	// aside for a hold is refused with circulation.ErrCopyUnavailable
+	RemoveBook(ctx context.Context, isbn string, libraryID uint) (book *models.Book, removed bool, err error)
+	SearchBooks(ctx context.Context, libraryIDs []uint, query BookQuery) ([]BookResult, pagination.Page, error)
+	// Suggest completes prefix with titles and authors, those starting with it first
+	Suggest(ctx context.Context, libraryIDs []uint, prefix string) ([]Suggestion, error)
+	// DidYouMean lists titles and authors spelt like text, most alike first
+	DidYouMean(ctx context.Context, libraryIDs []uint, text string) ([]Suggestion, error)
+
+	// AddCopy adds one barcoded copy of a book; it goes to the oldest waiting hold, if any
+	AddCopy(ctx context.Context, isbn string, libraryID uint, input circulation.CopyInput) (*models.BookCopy, error)
+	ListCopies(ctx context.Context, isbn string, libraryID uint, page pagination.Request[models.BookCopy]) (*models.Book, []models.BookCopy, pagination.Page, error)
+	// GetCopy returns a copy and the book it belongs to
+	GetCopy(ctx context.Context, barcode string) (*models.BookCopy, *models.Book, error)
+	UpdateCopy(ctx context.Context, barcode string, update CopyUpdate) (*models.BookCopy, *models.Book, error)
+}
+
+// CopyUpdate holds the editable details of a copy; nil and empty fields keep their value
+type CopyUpdate struct {
+	Condition     *string
+	ShelfLocation *string
+	Status        string // Issued and held copies change status only through a return, pickup or hold expiry
+}
+
+// CopySort lists the fields copies can be sorted by
+var CopySort = pagination.Spec[models.BookCopy]{
+	Fields: []pagination.Field[models.BookCopy]{
+		{Name: "barcode", Column: "barcode", Value: func(c models.BookCopy) any { return c.Barcode }},
+		{Name: "id", Column: "id", Value: func(c models.BookCopy) any { return c.ID }},
+		{Name: "status", Column: "status", Value: func(c models.BookCopy) any { return c.Status }},
+	},
+	Default: "barcode",
+}
+
+// UserService manages accounts
+type UserService interface {
+	GetUser(ctx context.Context, id uint) (*models.User, error)
+	// CreateUser saves an account whose password is already hashed and registers
+	// it in the given libraries
+	CreateUser(ctx context.Context, user *models.User, libraryIDs []uint) error
+}
+
+// RequestFilter selects issue and return requests
+type RequestFilter struct {
+	LibraryIDs []uint // Any library when nil
+	ReaderID   uint   // Any reader when zero
+	Status     string // Any status when empty
+	Type       string // "issue" or "return", any when empty
+	Page       pagination.Request[models.RequestEvent]
+}
+
+// RequestSort lists the fields requests can be sorted by
+var RequestSort = pagination.Spec[models.RequestEvent]{
+	Fields: []pagination.Field[models.RequestEvent]{
+		{Name: "id", Column: "id", Value: func(r models.RequestEvent) any { return r.ID }},
+		{Name: "request_date", Column: "request_date", Value: func(r models.RequestEvent) any { return r.RequestDate }},
+		{Name: "status", Column: "status", Value: func(r models.RequestEvent) any { return r.Status }},
+	},
+	Default: "request_date",
+}
+
+// CirculationService handles readers' requests and the loans they lead to
+type CirculationService interface {
+	ListRequests(ctx context.Context, filter RequestFilter) ([]models.RequestEvent, pagination.Page, error)
+	GetRequest(ctx context.Context, id uint) (*models.RequestEvent, error)
+	// RequestIssue asks for a book on behalf of a reader registered in its library
+	RequestIssue(ctx context.Context, readerID uint, isbn string, libraryID uint) (*models.RequestEvent, error)
+	ApproveIssue(ctx context.Context, requestID, approverID uint, barcode string) (*models.IssueRegistry, error)
+	RejectRequest(ctx context.Context, requestID, adminID uint, reason string) (*models.RequestEvent, error)
+	// Issue lends a book straight to a reader, who must be registered in its library
+	// and owe it no more than it allows
+	Issue(ctx context.Context, in circulation.IssueInput) (*models.IssueRegistry, error)
+	// CancelRequest withdraws one of a reader's pending requests
+	CancelRequest(ctx context.Context, requestID, readerID uint) (*models.RequestEvent, error)
+
+	// RequestReturn asks to give back a book the reader has on loan
+	RequestReturn(ctx context.Context, readerID uint, isbn string, libraryID uint) (*models.RequestEvent, error)
+	// ApproveReturn and ReturnCopy close a loan; the fine is nil unless the book came back late
+	ApproveReturn(ctx context.Context, requestID, approverID uint, condition string) (*models.IssueRegistry, *models.Fine, error)
+	ReturnCopy(ctx context.Context, barcode string, approverID uint, condition string) (*models.IssueRegistry, *models.Fine, error)
+
+	GetLoan(ctx context.Context, id uint) (*models.IssueRegistry, error)
+	ListLoans(ctx context.Context, filter LoanFilter) ([]models.IssueRegistry, pagination.Page, error)
+	// RenewLoan pushes an open loan's due date forward by one loan period
+	RenewLoan(ctx context.Context, loanID, renewedByID uint) (*models.IssueRegistry, error)
+	ListRenewals(ctx context.Context, loanID uint, page pagination.Request[models.LoanRenewal]) ([]models.LoanRenewal, pagination.Page, error)
+
+	// PlaceHold queues a reader for a title that has no copy on the shelf
+	PlaceHold(ctx context.Context, readerID uint, isbn string, libraryID uint) (*models.Hold, error)
+	// ListHolds pages through a reader's holds with the queue places of waiting ones
+	ListHolds(ctx context.Context, readerID uint, page pagination.Request[models.Hold]) ([]HoldResult, pagination.Page, error)
+	// QueuePosition reports a waiting hold's 1-based place in its title's queue
+	QueuePosition(ctx context.Context, hold *models.Hold) (int64, error)
+	CancelHold(ctx context.Context, holdID, readerID uint) (*models.Hold, error)
+
+	ListFines(ctx context.Context, readerID uint, page pagination.Request[models.Fine]) ([]models.Fine, pagination.Page, error)
+	GetFine(ctx context.Context, id uint) (*models.Fine, error)
+	// Balances lists what a reader owes each library they have an account with
+	Balances(ctx context.Context, readerID uint) ([]AccountBalance, error)
+	Balance(ctx context.Context, readerID, libraryID uint) (int64, error)
+	RecordPayment(ctx context.Context, readerID, libraryID uint, amountCents int64, note string, recordedByID uint) (*models.LedgerEntry, error)
+	// WaiveFine cancels a fine, crediting whatever part of it is still owed
+	WaiveFine(ctx context.Context, fineID, waivedByID uint, reason string) (*models.Fine, error)
+
+	// Policy returns the rules in force for a library; it has no ID while the defaults apply
+	Policy(ctx context.Context, libraryID uint) (models.CirculationPolicy, error)
+	// UpdatePolicy changes a library's rules; the first update copies the defaults
+	// into a policy of the library's own
+	UpdatePolicy(ctx context.Context, libraryID uint, update PolicyUpdate) (models.CirculationPolicy, error)
+}
+
+// LoanFilter selects loans
+type LoanFilter struct {
+	ReaderID uint   // Any reader when zero
+	Status   string // "issued" or "returned", any when empty
+	Page     pagination.Request[models.IssueRegistry]
+}
+
+// LoanSort lists the fields loans can be sorted by
+var LoanSort = pagination.Spec[models.IssueRegistry]{
+	Fields: []pagination.Field[models.IssueRegistry]{
+		{Name: "id", Column: "id", Value: func(l models.IssueRegistry) any { return l.ID }},
+		{Name: "issue_date", Column: "issue_date", Value: func(l models.IssueRegistry) any { return l.IssueDate }},
+		{Name: "due_date", Column: "expected_return_date", Value: func(l models.IssueRegistry) any { return l.ExpectedReturnDate }},
+	},
+	Default: "-issue_date",
+}
+
+// RenewalSort lists the fields renewals can be sorted by
+var RenewalSort = pagination.Spec[models.LoanRenewal]{
+	Fields: []pagination.Field[models.LoanRenewal]{
+		{Name: "renewed_at", Column: "renewed_at", Value: func(r models.LoanRenewal) any { return r.RenewedAt }},
+		{Name: "id", Column: "id", Value: func(r models.LoanRenewal) any { return r.ID }},
+	},
+	Default: "renewed_at",
+}
+
+// HoldResult is one of a reader's holds with its place in the title's queue
+type HoldResult struct {
+	models.Hold
+	Position int64 // 1-based; zero unless the hold is waiting
+}
+
+// HoldSort lists the fields holds can be sorted by
+var HoldSort = pagination.Spec[models.Hold]{
+	Fields: []pagination.Field[models.Hold]{
+		{Name: "id", Column: "id", Value: func(h models.Hold) any { return h.ID }},
+		{Name: "placed_at", Column: "placed_at", Value: func(h models.Hold) any { return h.PlacedAt }},
+	},
+	Default: "-placed_at",
+}
+
+// FineSort lists the fields fines can be sorted by
+var FineSort = pagination.Spec[models.Fine]{
+	Fields: []pagination.Field[models.Fine]{
+		{Name: "id", Column: "id", Value: func(f models.Fine) any { return f.ID }},
+		{Name: "assessed_at", Column: "assessed_at", Value: func(f models.Fine) any { return f.AssessedAt }},
+		{Name: "amount_cents", Column: "amount_cents", Value: func(f models.Fine) any { return f.AmountCents }},
+	},
+	Default: "-assessed_at",
+}
+
+// AccountBalance is what a reader owes one library; negative is credit
+type AccountBalance struct {
+	LibraryID    uint  `json:"library_id"`
+	BalanceCents int64 `json:"balance_cents"`
+}
+
+// PolicyUpdate holds the circulation rules to change; nil fields keep their value
+type PolicyUpdate struct {
+	LoanPeriodDays  *int
+	MaxLoans        *int
+	MaxRenewals     *int
+	HoldPickupDays  *int
+	FinePerDayCents *int64
+	MaxFineCents    *int64
+	FineGraceDays   *int
+	MaxBalanceCents *int64
+}
+
+// apply copies the set fields onto policy
+func (u PolicyUpdate) apply(policy *models.CirculationPolicy) {
+	if u.LoanPeriodDays != nil {
+		policy.LoanPeriodDays = *u.LoanPeriodDays
+	}
+	if u.MaxLoans != nil {
+		policy.MaxLoans = *u.MaxLoans
+	}
+	if u.MaxRenewals != nil {
+		policy.MaxRenewals = *u.MaxRenewals
+	}
+	if u.HoldPickupDays != nil {
+		policy.HoldPickupDays = *u.HoldPickupDays
+	}
+	if u.FinePerDayCents != nil {
+		policy.FinePerDayCents = *u.FinePerDayCents
+	}
+	if u.MaxFineCents != nil {
+		policy.MaxFineCents = *u.MaxFineCents
+	}
+	if u.FineGraceDays != nil {
+		policy.FineGraceDays = *u.FineGraceDays
+	}
+	if u.MaxBalanceCents != nil {
+		policy.MaxBalanceCents = *u.MaxBalanceCents
+	}
+}
+
+// TokenPair is what a client signs in with: a short-lived access token and a
+// single-use refresh token
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// AuthService signs users in and out
+type AuthService interface {
+	// Login checks a user's password and starts a new refresh token family,
+	// upgrading the stored hash when it is outdated
+	Login(ctx context.Context, email, password string) (*TokenPair, error)
+	// Refresh exchanges a refresh token for a new pair. Presenting one that was
+	// already rotated is treated as theft and revokes every token in its family.
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	// Logout revokes an access token and, when refreshToken is not empty, its family
+	Logout(ctx context.Context, userID uint, jti string, expiresAt int64, refreshToken string) error
+	// RevokeUser revokes every refresh token of a user and rejects the access
+	// tokens issued so far; it reports how many refresh tokens were revoked
+	RevokeUser(ctx context.Context, userID uint) (int64, error)
+	// IsRevoked reports whether an access token was revoked by logout, or issued
+	// before all of its user's tokens were
+	IsRevoked(ctx context.Context, claims *utils.TokenClaims) (bool, error)
+}
+
+// NotificationService manages users' inboxes and the channels they are notified on
+type NotificationService interface {
+	ListNotifications(ctx context.Context, userID uint, unreadOnly bool, page pagination.Request[models.Notification]) ([]models.Notification, pagination.Page, error)
+	UnreadCount(ctx context.Context, userID uint) (int64, error)
+	// MarkRead marks one of a user's notifications read; marking twice keeps the first read time
+	MarkRead(ctx context.Context, userID, id uint) (*models.Notification, error)
+	// MarkAllRead marks every unread notification of a user read and reports how many there were
+	MarkAllRead(ctx context.Context, userID uint) (int64, error)
+	// Preferences reports each configured channel as on or off for a user, defaults applied
+	Preferences(ctx context.Context, userID uint) ([]ChannelPreference, error)
+	UpdatePreferences(ctx context.Context, userID uint, channels map[string]bool) ([]ChannelPreference, error)
+}
+
+// ChannelPreference is whether a user is notified on a channel
+type ChannelPreference struct {
+	Channel string `json:"channel"`
+	Enabled bool   `json:"enabled"`
+}
+
+// NotificationSort lists the fields notifications can be sorted by
+var NotificationSort = pagination.Spec[models.Notification]{
+	Fields: []pagination.Field[models.Notification]{
+		{Name: "id", Column: "id", Value: func(n models.Notification) any { return n.ID }},
+		{Name: "created_at", Column: "created_at", Value: func(n models.Notification) any { return n.CreatedAt }},
+	},
+	Default: "-created_at",
+}
+
+// AuditService reads the audit log
+type AuditService interface {
+	ListEvents(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, pagination.Page, error)
+}
+
+// AuditFilter selects audit events
+type AuditFilter struct {
+	ActorID    uint   // Any actor when zero
+	EntityType string // Any entity when empty
+	EntityID   string
+	From, To   *time.Time // Inclusive bounds on when the change happened
+	Page       pagination.Request[models.AuditEvent]
+}
+
+// AuditSort lists the fields audit events can be sorted by
+var AuditSort = pagination.Spec[models.AuditEvent]{
+	Fields: []pagination.Field[models.AuditEvent]{
+		{Name: "id", Column: "id", Value: func(e models.AuditEvent) any { return e.ID }},
+		{Name: "occurred_at", Column: "occurred_at", Value: func(e models.AuditEvent) any { return e.OccurredAt }},
+	},
+	Default: "-occurred_at",
+}
+
+// JobService reads the history of background job runs
+type JobService interface {
+	// ListRuns pages through runs of a job, or of every job when job is empty
+	ListRuns(ctx context.Context, job string, page pagination.Request[models.JobRun]) ([]models.JobRun, pagination.Page, error)
+}
+
+// JobRunSort lists the fields job runs can be sorted by
+var JobRunSort = pagination.Spec[models.JobRun]{
+	Fields: []pagination.Field[models.JobRun]{
+		{Name: "id", Column: "id", Value: func(r models.JobRun) any { return r.ID }},
+		{Name: "started_at", Column: "started_at", Value: func(r models.JobRun) any { return r.StartedAt }},
+		{Name: "job", Column: "job", Value: func(r models.JobRun) any { return r.Job }},
+	},
+	Default: "-started_at",
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"library-management/audit"
+	"library-management/models"
+
+	"gorm.io/gorm"
+)
+
+// auditedUser is the audit record of a new account and the libraries it was given
+type auditedUser struct {
+	models.User
+	LibraryIDs []uint
+}
+
+type userService struct {
+	db *gorm.DB
+}
+
+// NewUserService returns a UserService backed by db
+func NewUserService(db *gorm.DB) UserService {
+	return &userService{db: db}
+}
+
+func (s *userService) GetUser(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Preload("Library").First(&user, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *userService) CreateUser(ctx context.Context, user *models.User, libraryIDs []uint) error {
+	db := s.db.WithContext(ctx)
+
+	var taken int64
+	if err := db.Model(&models.User{}).Where("email = ?", user.Email).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrEmailTaken
+	}
+
+	// The user, their libraries and the audit event are saved together or not at all
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		for _, libID := range libraryIDs {
+			var library models.Library
+			err := tx.First(&library, libID).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &LibraryNotFoundError{ID: libID}
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Create(&models.UserLibrary{UserID: user.ID, LibraryID: libID}).Error; err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, "user.create", "user", user.ID, nil, auditedUser{*user, libraryIDs})
+	})
+}
@@ -0,0 +1,163 @@
+// Package storage opens the database chosen in the configuration and hides
+// the few places where the supported SQL dialects differ.
+//
+// Postgres is the production database and its schema comes from the versioned
+// migrations. SQLite, on disk or in memory, needs no server and is meant for
+// local development and tests; its schema is created from the models.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"library-management/migrations"
+	"library-management/models"
+	"strings"
+	"sync/atomic"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Supported drivers
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// Memory is the SQLite DSN for a database that lives as long as the process
+const Memory = ":memory:"
+
+// memoryDBs numbers in-memory databases so each Open gets its own
+var memoryDBs atomic.Int64
+
+// Open connects to the database; an empty driver means Postgres
+func Open(driver, dsn string) (*gorm.DB, error) {
+	switch driver {
+	case Postgres, "":
+		return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	case SQLite:
+		return openSQLite(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+func openSQLite(dsn string) (*gorm.DB, error) {
+	if dsn == Memory {
+		// A shared cache lets every pooled connection see the same database
+		dsn = fmt.Sprintf("file:lms-%d?mode=memory&cache=shared", memoryDBs.Add(1))
+	}
+	// Foreign keys are off by default, and without a busy timeout a writer
+	// fails at once instead of waiting for another one to commit
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return gorm.Open(sqlite.Open(dsn+sep+"_foreign_keys=1&_busy_timeout=5000"), &gorm.Config{})
+}
+
+// IsPostgres reports whether db talks to Postgres
+func IsPostgres(db *gorm.DB) bool {
+	return db.Dialector.Name() == Postgres
+}
+
+// ILike returns a case-insensitive "column LIKE ?" condition. SQLite's LIKE
+// already ignores case for ASCII, Postgres needs ILIKE. Both take a backslash
+// as the escape character, which SQLite has none of by default.
+func ILike(db *gorm.DB, column string) string {
+	if IsPostgres(db) {
+		return column + ` ILIKE ? ESCAPE '\'`
+	}
+	return column + ` LIKE ? ESCAPE '\'`
+}
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// EscapeLike makes text match itself literally in an ILike pattern
+func EscapeLike(text string) string {
+	return likeEscaper.Replace(text)
+}
+
+// TryLock takes a lock on key for the rest of the transaction, or reports that
+// another process holds it. Only Postgres is shared between server replicas,
+// so on other drivers the lock is always granted.
+func TryLock(tx *gorm.DB, key int64) (bool, error) {
+	if !IsPostgres(tx) {
+		return true, nil
+	}
+	var locked bool
+	err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", key).Scan(&locked).Error
+	return locked, err
+}
+
+// schema lists every table, for drivers the SQL migrations do not support
+var schema = []interface{}{
+	&models.Library{},
+	&models.User{},
+	&models.UserLibrary{},
+	&models.Book{},
+	&models.BookCopy{},
+	&models.RequestEvent{},
+	&models.IssueRegistry{},
+	&models.Hold{},
+	&models.LoanRenewal{},
+	&models.CirculationPolicy{},
+	&models.Fine{},
+	&models.LedgerEntry{},
+	&models.JobRun{},
+	&models.Notification{},
+	&models.NotificationPreference{},
+	&models.OutboxMessage{},
+	&models.RefreshToken{},
+	&models.RevokedToken{},
+	&models.AuditEvent{},
+}
+
+// PrepareSchema makes sure db is ready to serve. Postgres must already be
+// migrated; other drivers have their tables created from the models.
+func PrepareSchema(ctx context.Context, db *gorm.DB) error {
+	if !IsPostgres(db) {
+		return db.WithContext(ctx).AutoMigrate(schema...)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	runner, err := migrations.NewRunner(sqlDB)
+	if err != nil {
+		return err
+	}
+	pending, err := runner.Pending(ctx)
+	if err != nil {
+		return fmt.Errorf("schema check failed: %w", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is out of date (%d pending migrations), run `library-management migrate up`", len(pending))
+	}
+	return nil
+}
+
+// Migrate implements the `migrate` subcommand. Postgres runs the versioned
+// migrations; other drivers only support `up`, which creates the tables.
+func Migrate(ctx context.Context, db *gorm.DB, args []string, out io.Writer) error {
+	if IsPostgres(db) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return migrations.RunCommand(ctx, sqlDB, args, out)
+	}
+
+	if len(args) > 0 && args[0] != "up" {
+		return fmt.Errorf("migrate %s is only supported on Postgres", args[0])
+	}
+	if err := db.WithContext(ctx).AutoMigrate(schema...); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Schema is up to date")
+	return nil
+}
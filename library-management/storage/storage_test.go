@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"library-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteMemory(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(SQLite, Memory)
+	assert.NoError(t, err)
+	assert.False(t, IsPostgres(db))
+	assert.NoError(t, PrepareSchema(ctx, db))
+
+	library := models.Library{Name: "Central"}
+	assert.NoError(t, db.Create(&library).Error)
+	assert.NoError(t, db.Create(&models.Book{ISBN: "123", Title: "The Go Programming Language", LibraryID: library.ID}).Error)
+
+	t.Run("Case-insensitive match", func(t *testing.T) {
+		var books []models.Book
+		assert.NoError(t, db.Where(ILike(db, "title"), "%go programming%").Find(&books).Error)
+		assert.Len(t, books, 1)
+	})
+
+	t.Run("Escaped wildcards match themselves", func(t *testing.T) {
+		assert.NoError(t, db.Create(&models.Book{ISBN: "456", Title: "100% Go_Lang", LibraryID: library.ID}).Error)
+
+		var books []models.Book
+		assert.NoError(t, db.Where(ILike(db, "title"), "%"+EscapeLike("0% go_")+"%").Find(&books).Error)
+		if assert.Len(t, books, 1) {
+			assert.Equal(t, "456", books[0].ISBN)
+		}
+		assert.NoError(t, db.Where(ILike(db, "title"), EscapeLike("the_go")+"%").Find(&books).Error)
+		assert.Empty(t, books)
+	})
+
+	t.Run("Every connection sees the same database", func(t *testing.T) {
+		sqlDB, err := db.DB()
+		assert.NoError(t, err)
+		conn1, err := sqlDB.Conn(ctx)
+		assert.NoError(t, err)
+		defer conn1.Close()
+		conn2, err := sqlDB.Conn(ctx)
+		assert.NoError(t, err)
+		defer conn2.Close()
+
+		var count int
+		assert.NoError(t, conn2.QueryRowContext(ctx, "SELECT count(*) FROM libraries").Scan(&count))
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Each in-memory database is separate", func(t *testing.T) {
+		other, err := Open(SQLite, Memory)
+		assert.NoError(t, err)
+		assert.NoError(t, PrepareSchema(ctx, other))
+
+		var count int64
+		assert.NoError(t, other.Model(&models.Library{}).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Locks are always granted", func(t *testing.T) {
+		locked, err := TryLock(db, 42)
+		assert.NoError(t, err)
+		assert.True(t, locked)
+	})
+}
+
+func TestSQLiteMigrate(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(SQLite, t.TempDir()+"/lms.db")
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, Migrate(ctx, db, []string{"up"}, &out))
+	assert.Equal(t, "Schema is up to date\n", out.String())
+	assert.True(t, db.Migrator().HasTable(&models.AuditEvent{}))
+
+	assert.EqualError(t, Migrate(ctx, db, []string{"down"}, &out), "migrate down is only supported on Postgres")
+}
+
+func TestOpenUnknownDriver(t *testing.T) {
+	_, err := Open("mysql", "dsn")
+	assert.EqualError(t, err, `unsupported database driver "mysql"`)
+}
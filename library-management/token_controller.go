@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"library-management/audit"
+	"library-management/services"
+	"library-management/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshToken exchanges a refresh token for a new access/refresh token pair.
+// Each refresh token is single-use; presenting one that was already rotated
+// is treated as theft and revokes every token in its family.
+func RefreshToken(auth services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			RefreshToken string `json:"refresh_token" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		pair, err := auth.Refresh(c.Request.Context(), input.RefreshToken)
+		switch {
+		case errors.Is(err, services.ErrInvalidRefreshToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		case errors.Is(err, services.ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, please log in again"})
+			return
+		case errors.Is(err, services.ErrRefreshTokenExpired):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not refresh token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, tokenResponse(pair))
+	}
+}
+
+// Logout revokes the caller's access token and, if given, the refresh token family
+func Logout(auth services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+
+		// The body is optional; only malformed JSON is rejected
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		err := auth.Logout(c.Request.Context(), c.GetUint("userID"), c.GetString("tokenJTI"), c.GetInt64("tokenExpiresAt"), input.RefreshToken)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not log out"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	}
+}
+
+// JWKS publishes the public keys other services need to verify LMS tokens offline
+func JWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, utils.CurrentKeySet().JWKS())
+	}
+}
+
+// RevokeUserTokens signs a user out everywhere, as when a staff member leaves:
+// every refresh token of theirs is revoked and every access token issued so
+// far is rejected - Only Owner
+func RevokeUserTokens(auth services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		refreshTokens, err := auth.RevokeUser(audit.Context(c), uint(userID))
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke tokens"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Tokens revoked", "refresh_tokens_revoked": refreshTokens})
+	}
+}
+
+// IsTokenRevoked returns the lookup AuthMiddleware uses to reject access tokens
+// revoked by logout, or issued before an owner revoked all of the user's tokens
+func IsTokenRevoked(auth services.AuthService) func(claims *utils.TokenClaims) (bool, error) {
+	return func(claims *utils.TokenClaims) (bool, error) {
+		return auth.IsRevoked(context.Background(), claims)
+	}
+}
+
+// tokenResponse is the body returned on login and refresh
+func tokenResponse(pair *services.TokenPair) gin.H {
+	return gin.H{
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    int(pair.ExpiresIn.Seconds()),
+	}
+}
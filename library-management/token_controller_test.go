@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"bytes"
+	"library-management/services"
+	"library-management/utils"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestRefreshToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/auth/refresh", RefreshToken(services.NewAuthService(gormDB)))
+
+	tokenQuery := regexp.QuoteMeta(`SELECT * FROM "refresh_tokens" WHERE token_hash = $1`)
+	tokenColumns := []string{"id", "user_id", "token_hash", "family_id", "expires_at", "revoked_at"}
+	hash := utils.HashRefreshToken("refresh-token")
+	future := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name           string
+		input          string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:  "Successful rotation",
+			input: `{"refresh_token": "refresh-token"}`,
+			mockSetup: func() {
+				mock.ExpectQuery(tokenQuery).
+					WithArgs(hash, 1).
+					WillReturnRows(sqlmock.NewRows(tokenColumns).AddRow(1, 7, hash, "family", future, nil))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE "users"."id" = $1`)).
+					WithArgs(7, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(7, "user"))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_tokens" SET "revoked_at"=$1`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "refresh_tokens"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_tokens" SET "replaced_by_id"=$1`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "refresh_token",
+		},
+		{
+			name:  "Reused token revokes family",
+			input: `{"refresh_token": "refresh-token"}`,
+			mockSetup: func() {
+				mock.ExpectQuery(tokenQuery).
+					WithArgs(hash, 1).
+					WillReturnRows(sqlmock.NewRows(tokenColumns).AddRow(1, 7, hash, "family", future, time.Now().Unix()))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_tokens" SET "revoked_at"=$1`)).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "family").
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectCommit()
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Refresh token reuse detected",
+		},
+		{
+			name:  "Expired token",
+			input: `{"refresh_token": "refresh-token"}`,
+			mockSetup: func() {
+				mock.ExpectQuery(tokenQuery).
+					WithArgs(hash, 1).
+					WillReturnRows(sqlmock.NewRows(tokenColumns).AddRow(1, 7, hash, "family", time.Now().Add(-time.Hour).Unix(), nil))
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Refresh token expired",
+		},
+		{
+			name:  "Unknown token",
+			input: `{"refresh_token": "refresh-token"}`,
+			mockSetup: func() {
+				mock.ExpectQuery(tokenQuery).
+					WithArgs(hash, 1).
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Invalid refresh token",
+		},
+		{
+			name:           "Missing token",
+			input:          `{}`,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Field validation for 'RefreshToken' failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBufferString(tt.input))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/auth/logout", func(c *gin.Context) {
+		c.Set("userID", uint(7))
+		c.Set("tokenJTI", "jti-1")
+		c.Set("tokenExpiresAt", time.Now().Add(time.Minute).Unix())
+		Logout(services.NewAuthService(gormDB))(c)
+	})
+
+	t.Run("Revokes access token and refresh family", func(t *testing.T) {
+		hash := utils.HashRefreshToken("refresh-token")
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "revoked_tokens"`)).
+			WithArgs("jti-1", 7, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "refresh_tokens" WHERE (token_hash = $1 AND user_id = $2)`)).
+			WithArgs(hash, 7, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id"}).AddRow(1, 7, "family"))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_tokens" SET "revoked_at"=$1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPost, "/auth/logout", bytes.NewBufferString(`{"refresh_token": "refresh-token"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Logged out successfully")
+	})
+
+	t.Run("Without body only revokes access token", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "revoked_tokens"`)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,17 +1,25 @@
-// 🔍 Search Books by Title, Author, Publisher
+// 🔍 Search Books by Title, Author, Publisher, or all three at once
 package controllers
 
 import (
-	"library-management/models"
+	"errors"
+	"library-management/audit"
+	"library-management/circulation"
+	"library-management/pagination"
+	"library-management/services"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-// SearchBooks allows users to search for books in their registered libraries
-func SearchBooks(db *gorm.DB) gin.HandlerFunc {
+// SearchBooks allows users to search for books in their registered libraries.
+// q searches titles, authors and publishers together, best matches first; it
+// takes "quoted phrases" and prefix* words, or with fuzzy=true forgives typos
+// in titles and authors. A search that finds nothing suggests what the reader
+// may have meant. Results are paged, sorted by title or, with q, by rank.
+func SearchBooks(books services.BookService, libraries services.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -19,41 +27,44 @@ func SearchBooks(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var userLibraries []uint
-		if err := db.Table("user_libraries").Where("user_id = ?", userID).Pluck("library_id", &userLibraries).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch user libraries"})
+		text := c.Query("q")
+		spec := services.BookSort
+		if text != "" {
+			spec = services.RankedBookSort
+		}
+		req, err := pagination.Parse(c, spec)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if len(userLibraries) == 0 {
-			c.JSON(http.StatusOK, gin.H{"books": []gin.H{}})
+		userLibraries, err := libraries.LibraryIDs(c.Request.Context(), userID.(uint))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch user libraries"})
 			return
 		}
 
-		title := c.Query("title")
-		author := c.Query("author")
-		publisher := c.Query("publisher")
-
-		var books []models.Book
-		query := db.Where("library_id IN (?)", userLibraries)
-
-		if title != "" {
-			query = query.Where("title ILIKE ?", "%"+title+"%")
-		}
-		if author != "" {
-			query = query.Where("authors ILIKE ?", "%"+author+"%")
-		}
-		if publisher != "" {
-			query = query.Where("publisher ILIKE ?", "%"+publisher+"%")
+		if len(userLibraries) == 0 {
+			_, page := pagination.Slice(nil, req)
+			c.JSON(http.StatusOK, pagination.Envelope([]gin.H{}, page))
+			return
 		}
 
-		if err := query.Select("isbn, title, authors, publisher, available_copies, library_id").Find(&books).Error; err != nil {
+		results, page, err := books.SearchBooks(c.Request.Context(), userLibraries, services.BookQuery{
+			Text:      text,
+			Fuzzy:     c.Query("fuzzy") == "true",
+			Title:     c.Query("title"),
+			Author:    c.Query("author"),
+			Publisher: c.Query("publisher"),
+			Page:      req,
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching books"})
 			return
 		}
 
-		response := make([]gin.H, 0, len(books))
-		for _, book := range books {
+		response := make([]gin.H, 0, len(results))
+		for _, book := range results {
 			authors := book.Authors
 			if authors == "" {
 				authors = "Unknown"
@@ -67,30 +78,90 @@ func SearchBooks(db *gorm.DB) gin.HandlerFunc {
 				"available_copies": book.AvailableCopies,
 				"library_id":       book.LibraryID,
 			}
+			if text != "" {
+				bookData["rank"] = book.Rank
+				bookData["headline"] = book.Headline
+			}
 
 			if book.AvailableCopies == 0 {
-				var nextAvailableDate time.Time
-				var issue models.IssueRegistry
-
-				if err := db.Where("isbn = ? AND return_date IS NULL", book.ISBN).
-					Order("expected_return_date ASC").
-					First(&issue).Error; err == nil {
-					nextAvailableDate = time.Unix(issue.ExpectedReturnDate, 0)
-					bookData["next_available_date"] = nextAvailableDate.Format("2006-01-02 15:04:05")
+				if book.NextAvailableAt != nil {
+					bookData["next_available_date"] = time.Unix(*book.NextAvailableAt, 0).Format("2006-01-02 15:04:05")
 				} else {
 					bookData["next_available_date"] = "Unknown"
 				}
+				bookData["holds_waiting"] = book.HoldsWaiting
 			}
 
 			response = append(response, bookData)
 		}
 
-		c.JSON(http.StatusOK, gin.H{"books": response})
+		reply := pagination.Envelope(response, page)
+		if typed := firstNonEmpty(text, c.Query("author"), c.Query("title")); len(results) == 0 && req.First() && typed != "" {
+			suggestions, err := books.DidYouMean(c.Request.Context(), userLibraries, typed)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching books"})
+				return
+			}
+			reply["did_you_mean"] = suggestionList(suggestions)
+		}
+		c.JSON(http.StatusOK, reply)
 	}
 }
 
+// SuggestBooks completes what a reader is typing with titles and authors from
+// their registered libraries
+func SuggestBooks(books services.BookService, libraries services.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			return
+		}
+
+		prefix := strings.TrimSpace(c.Query("prefix"))
+		if prefix == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "prefix is required"})
+			return
+		}
+
+		userLibraries, err := libraries.LibraryIDs(c.Request.Context(), userID.(uint))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch user libraries"})
+			return
+		}
+		if len(userLibraries) == 0 {
+			c.JSON(http.StatusOK, gin.H{"suggestions": []gin.H{}})
+			return
+		}
+
+		suggestions, err := books.Suggest(c.Request.Context(), userLibraries, prefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching suggestions"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"suggestions": suggestionList(suggestions)})
+	}
+}
+
+func suggestionList(suggestions []services.Suggestion) []gin.H {
+	list := make([]gin.H, len(suggestions))
+	for i, s := range suggestions {
+		list[i] = gin.H{"text": s.Text, "kind": s.Kind}
+	}
+	return list
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 // RequestIssue allows users to request books from admins
-func RequestIssue(db *gorm.DB) gin.HandlerFunc {
+func RequestIssue(circ services.CirculationService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
 			BookID    string `json:"isbn" binding:"required"`
@@ -110,48 +181,24 @@ func RequestIssue(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Check if the book exists in the specified library
-		var book models.Book
-		if err := db.Where("isbn = ? AND library_id = ?", input.BookID, input.LibraryID).First(&book).Error; err != nil {
+		// The service checks the book is on the shelf, the reader belongs to the library,
+		// owes no more than it allows and has not already asked for the book
+		request, err := circ.RequestIssue(audit.Context(c), userID.(uint), input.BookID, input.LibraryID)
+		switch {
+		case errors.Is(err, circulation.ErrBookNotFound):
 			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in the specified library"})
 			return
-		}
-
-		// Check if the book is available for issue
-		if book.AvailableCopies == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Book not available for issue"})
+		case errors.Is(err, services.ErrBookUnavailable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Book not available for issue; place a hold to join the queue"})
 			return
-		}
-
-		// Check if the user is registered in the library
-		var userLibrary models.UserLibrary
-		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&userLibrary).Error; err != nil {
+		case errors.Is(err, services.ErrNotMember):
 			c.JSON(http.StatusForbidden, gin.H{"error": "You can only request books from libraries you are registered in"})
 			return
-		}
-
-		// Check if the user already has a pending request for this book in this library
-		var existingRequest models.RequestEvent
-		if err := db.Where("reader_id = ? AND book_id = ? AND library_id = ? AND approval_date IS NULL", userID, input.BookID, input.LibraryID).First(&existingRequest).Error; err == nil {
+		case errors.Is(err, services.ErrDuplicateRequest):
 			c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending request for this book in this library"})
 			return
-		}
-
-		// Create the issue request
-		requestDate := time.Now()
-		request := models.RequestEvent{
-			BookID:       input.BookID,
-			LibraryID:    input.LibraryID,
-			ReaderID:     userID.(uint),
-			RequestDate:  requestDate.Unix(),
-			ApprovalDate: nil,
-			ApproverID:   nil,
-			RequestType:  "issue",
-		}
-
-		// Save the request to the database
-		if err := db.Create(&request).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create issue request"})
+		case err != nil:
+			respondCirculationError(c, err, "Could not create issue request")
 			return
 		}
 
@@ -2,195 +2,180 @@ package controllers
 
 import (
 	"bytes"
-	"errors"
+	"context"
+	"fmt"
+	"library-management/models"
+	"library-management/services"
 	"net/http"
 	"net/http/httptest"
-	"regexp"
 	"testing"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 )
 
-func TestSearchBooks(t *testing.T) {
-	// Setup mock database
-	db, mock, err := sqlmock.New()
-	assert.NoError(t, err)
+// newCatalogue extends newStore with a few books in the reader's library and one in a library
+// the reader is not registered in
+func newCatalogue(t *testing.T) (store *testStore, libID, readerID uint) {
+	t.Helper()
+	ctx := context.Background()
+	store, libID, _, readerID = newStore(t)
+	other := models.Library{Name: "Branch Library"}
+	assert.NoError(t, store.CreateLibrary(ctx, &other))
+
+	for _, book := range []models.Book{
+		{ISBN: "123456789", Title: "Test Book", Authors: "Test Author", Publisher: "Test Publisher", LibraryID: libID, TotalCopies: 2},
+		{ISBN: "261102214", Title: "The Hobbit", Authors: "J.R.R. Tolkien", Publisher: "Allen & Unwin", LibraryID: libID, TotalCopies: 1},
+		{ISBN: "000000001", Title: "Tolkien: A Biography", Authors: "Humphrey Carpenter", LibraryID: libID, TotalCopies: 1},
+		{ISBN: "555555555", Title: "Branch Only", Authors: "Test Author", LibraryID: other.ID, TotalCopies: 1},
+	} {
+		_, _, err := store.AddBook(ctx, book)
+		assert.NoError(t, err)
+	}
+	return store, libID, readerID
+}
 
-	// Initialize GORM and Gin
-	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
-	assert.NoError(t, err)
+func TestSearchBooks(t *testing.T) {
+	store, _, readerID := newCatalogue(t)
+	ctx := context.Background()
+	newcomer := models.User{Name: "Newcomer", Email: "newcomer@example.com", Role: "user"}
+	assert.NoError(t, store.CreateUser(ctx, &newcomer, nil))
 
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
-	r.GET("/search", func(c *gin.Context) {
-		c.Set("userID", uint(1)) // Mock user ID
-		SearchBooks(gormDB)(c)
-	})
+	r.GET("/search", asUser(SearchBooks(store, store)))
+	search := func(query string, userID uint) *httptest.ResponseRecorder {
+		return serve(r, http.MethodGet, "/search"+query, userID, "")
+	}
 
 	// Successful Book Search
 	t.Run("Successful Book Search", func(t *testing.T) {
-		// Mock user libraries query
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1))
-
-		// Mock books query
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT isbn, title, authors, publisher, available_copies, library_id FROM "books" WHERE library_id IN ($1)`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"isbn", "title", "authors", "publisher", "available_copies", "library_id"}).
-				AddRow("123456789", "Test Book", "Test Author", "Test Publisher", 2, 1))
-
-		req := httptest.NewRequest(http.MethodGet, "/search", nil)
-		w := httptest.NewRecorder()
-		r.ServeHTTP(w, req)
+		w := search("", readerID)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "Test Book")
-		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Contains(t, w.Body.String(), "The Hobbit")
+		assert.NotContains(t, w.Body.String(), "Branch Only") // Only the reader's own libraries are searched
 	})
 
 	// Edge Case 2: User has no libraries
 	t.Run("No Libraries Found", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{})) // No libraries for user
-
-		req := httptest.NewRequest(http.MethodGet, "/search", nil)
-		w := httptest.NewRecorder()
-		r.ServeHTTP(w, req)
+		w := search("", newcomer.ID)
 
 		assert.Equal(t, http.StatusOK, w.Code)
-		assert.Contains(t, w.Body.String(), `"books":[]`)
+		assert.Contains(t, w.Body.String(), `"data":[]`)
 	})
 
-	// Edge Case 3: No books found in any library
-	t.Run("No Books Found", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1))
+	// Edge Case 3: Unauthorized
+	t.Run("Unauthorized", func(t *testing.T) {
+		w := search("", 0)
 
-		// Mock that no books are returned
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT isbn, title, authors, publisher, available_copies, library_id FROM "books" WHERE library_id IN ($1)`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"isbn", "title", "authors", "publisher", "available_copies", "library_id"})) // No books
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
 
-		req := httptest.NewRequest(http.MethodGet, "/search", nil)
-		w := httptest.NewRecorder()
-		r.ServeHTTP(w, req)
+	// Edge Case 4: Search with filters (e.g., title, author, publisher)
+	t.Run("Search with Filters", func(t *testing.T) {
+		w := search("?title=test&publisher=Test+Publisher", readerID)
 
 		assert.Equal(t, http.StatusOK, w.Code)
-		assert.Contains(t, w.Body.String(), `"books":[]`)
+		assert.Contains(t, w.Body.String(), "Test Book")
+		assert.NotContains(t, w.Body.String(), "The Hobbit")
 	})
 
-	// Edge Case 4: Error fetching user libraries
-	t.Run("Error Fetching User Libraries", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
-			WillReturnError(errors.New("db error"))
+	// Full-text search ranks its results
+	t.Run("Full-Text Search", func(t *testing.T) {
+		w := search("?q=test+auth*", readerID)
 
-		req := httptest.NewRequest(http.MethodGet, "/search", nil)
-		w := httptest.NewRecorder()
-		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Test Book")
+		assert.Contains(t, w.Body.String(), `"rank":`)
+		assert.NotContains(t, w.Body.String(), "The Hobbit")
+	})
 
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
-		assert.Contains(t, w.Body.String(), "Could not fetch user libraries")
+	// Fuzzy search forgives typos
+	t.Run("Fuzzy Search", func(t *testing.T) {
+		w := search("?q=Tolkein&fuzzy=true", readerID)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "The Hobbit")
+		assert.NotContains(t, w.Body.String(), "did_you_mean")
 	})
 
-	// Edge Case 5: Error searching books
-	t.Run("Error Searching Books", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1))
+	// An empty result suggests titles and authors spelt alike
+	t.Run("Did You Mean", func(t *testing.T) {
+		w := search("?author=Tolkein", readerID)
 
-		// Mock error in book query
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT isbn, title, authors, publisher, available_copies, library_id FROM "books" WHERE library_id IN ($1)`)).
-			WithArgs(1).
-			WillReturnError(errors.New("db error"))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data":[],"page":{"limit":20,"sort":"title"},"did_you_mean":[{"text":"J.R.R. Tolkien","kind":"author"},{"text":"Tolkien: A Biography","kind":"title"}]}`, w.Body.String())
+	})
 
-		req := httptest.NewRequest(http.MethodGet, "/search", nil)
-		w := httptest.NewRecorder()
-		r.ServeHTTP(w, req)
+	t.Run("Unknown Sort", func(t *testing.T) {
+		w := search("?sort=rank", readerID)
 
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
-		assert.Contains(t, w.Body.String(), "Error searching books")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
+}
 
-	// Edge Case 6: Search with filters (e.g., title, author, publisher)
-	t.Run("Search with Filters", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1))
+func TestSuggestBooks(t *testing.T) {
+	store, libID, readerID := newCatalogue(t)
 
-		// Mock books query with filters
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT isbn, title, authors, publisher, available_copies, library_id FROM "books" WHERE library_id IN ($1) AND title ILIKE $2`)).
-			WithArgs(1, "%Test Title%").
-			WillReturnRows(sqlmock.NewRows([]string{"isbn", "title", "authors", "publisher", "available_copies", "library_id"}).
-				AddRow("123456789", "Test Book", "Test Author", "Test Publisher", 2, 1))
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/suggest", asUser(SuggestBooks(store, store)))
 
-		req := httptest.NewRequest(http.MethodGet, "/search?title=Test+Title", nil)
-		w := httptest.NewRecorder()
-		r.ServeHTTP(w, req)
+	t.Run("Completions", func(t *testing.T) {
+		w := serve(r, http.MethodGet, "/suggest?prefix=tol", readerID, "")
 
 		assert.Equal(t, http.StatusOK, w.Code)
-		assert.Contains(t, w.Body.String(), "Test Book")
+		assert.JSONEq(t, `{"suggestions":[{"text":"Tolkien: A Biography","kind":"title"},{"text":"J.R.R. Tolkien","kind":"author"}]}`, w.Body.String())
 	})
 
+	// Books merely containing the prefix are read only after those starting with it
+	t.Run("Many Matches", func(t *testing.T) {
+		books := make([]models.Book, 250)
+		for i := range books {
+			books[i] = models.Book{ISBN: fmt.Sprintf("bristol-%d", i), Title: fmt.Sprintf("Bristol Guide %d", i), LibraryID: libID}
+		}
+		assert.NoError(t, store.db.CreateInBatches(books, 100).Error)
+		assert.NoError(t, store.db.Create(&models.Book{ISBN: "war-and-peace", Title: "War and Peace", Authors: "Tolstoy", LibraryID: libID}).Error)
+
+		w := serve(r, http.MethodGet, "/suggest?prefix=tol", readerID, "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"suggestions":[{"text":"Tolstoy","kind":"author"},{"text":"Tolkien: A Biography","kind":"title"},{"text":"J.R.R. Tolkien","kind":"author"}]}`, w.Body.String())
+	})
+
+	t.Run("Missing Prefix", func(t *testing.T) {
+		w := serve(r, http.MethodGet, "/suggest?prefix=+", readerID, "")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "prefix is required")
+	})
 }
 
 func TestRequestIssue(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	assert.NoError(t, err)
-
-	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
-	assert.NoError(t, err)
+	store, libID, readerID := newCatalogue(t)
 
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
-	r.POST("/request/issue", func(c *gin.Context) {
-		c.Set("userID", uint(1))
-		RequestIssue(gormDB)(c)
-	})
+	r.POST("/request/issue", asUser(RequestIssue(store)))
+	request := func(body string) *httptest.ResponseRecorder {
+		return serve(r, http.MethodPost, "/request/issue", readerID, body)
+	}
 
 	t.Run("Successful Issue Request", func(t *testing.T) {
-		// ✅ Mock book existence check
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL`)).
-			WithArgs("123456789", 1).
-			WillReturnRows(sqlmock.NewRows([]string{"isbn", "available_copies"}).
-				AddRow("123456789", 1))
-
-		// ✅ Mock check for user's library registration (Fix: Removed the extra LIMIT argument)
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
-			WithArgs(1, 1). // Only 2 arguments expected
-			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}).AddRow(1, 1))
-
-		// ✅ Mock check for existing issue request
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE (reader_id = $1 AND book_id = $2 AND library_id = $3 AND approval_date IS NULL) AND "request_events"."deleted_at" IS NULL`)).
-			WithArgs(1, "123456789", 1).
-			WillReturnRows(sqlmock.NewRows([]string{})) // No existing request found
-
-		// ✅ Mock successful issue request insertion
-		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "request_events"`)).
-			WillReturnResult(sqlmock.NewResult(1, 1)) // 1 row affected
-
-		req := httptest.NewRequest(http.MethodPost, "/request/issue", bytes.NewBufferString(`{"isbn":"123456789","libraryid":1}`))
-		req.Header.Set("Content-Type", "application/json")
-		w := httptest.NewRecorder()
-		r.ServeHTTP(w, req)
+		w := request(fmt.Sprintf(`{"isbn":"123456789","libraryid":%d}`, libID))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), "Issue request submitted")
 
-		//assert.Equal(t, http.StatusCreated, w.Code)
-		//assert.Contains(t, w.Body.String(), "Issue request submitted")
-		//assert.NoError(t, mock.ExpectationsWereMet()) // ✅ Ensure all expectations are met
+		requests, _, err := store.ListRequests(context.Background(), services.RequestFilter{ReaderID: readerID})
+		assert.NoError(t, err)
+		assert.Len(t, requests, 1)
 	})
 
 	t.Run("Invalid JSON", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodPost, "/request/issue", bytes.NewBufferString(`{"isbn": "123456789"}`)) // Missing libraryid
-		req.Header.Set("Content-Type", "application/json")
-		w := httptest.NewRecorder()
-		r.ServeHTTP(w, req)
+		w := request(`{"isbn": "123456789"}`) // Missing libraryid
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 		// Match the actual gin validation error message format
@@ -198,16 +183,54 @@ func TestRequestIssue(t *testing.T) {
 	})
 
 	t.Run("Book Not Found", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL`)).
-			WithArgs("123456789", 1).
-			WillReturnError(gorm.ErrRecordNotFound)
+		w := request(fmt.Sprintf(`{"isbn":"987654321","libraryid":%d}`, libID))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "Book not found in the specified library")
+	})
+}
 
-		req := httptest.NewRequest(http.MethodPost, "/request/issue", bytes.NewBufferString(`{"isbn":"123456789","libraryid":1}`))
+// The request rules: membership, outstanding fines and duplicate requests
+func TestRequestIssueRules(t *testing.T) {
+	ctx := context.Background()
+	store, homeID, _, readerID := newStore(t)
+	other := models.Library{Name: "Branch"}
+	assert.NoError(t, store.CreateLibrary(ctx, &other))
+	for _, book := range []models.Book{
+		{ISBN: "111", LibraryID: homeID, TotalCopies: 1},
+		{ISBN: "111", LibraryID: other.ID, TotalCopies: 1},
+	} {
+		_, _, err := store.AddBook(ctx, book)
+		assert.NoError(t, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/request/issue", func(c *gin.Context) {
+		c.Set("userID", readerID)
+		RequestIssue(store)(c)
+	})
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/request/issue", bytes.NewBufferString(body))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
+		return w
+	}
 
-		assert.Equal(t, http.StatusNotFound, w.Code)
-		assert.Contains(t, w.Body.String(), "Book not found in the specified library")
-	})
+	w := post(fmt.Sprintf(`{"isbn":"111","libraryid":%d}`, other.ID))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "libraries you are registered in")
+
+	store.charge(t, readerID, homeID, 600)
+	w = post(fmt.Sprintf(`{"isbn":"111","libraryid":%d}`, homeID))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Outstanding fines")
+	store.charge(t, readerID, homeID, -600)
+
+	w = post(fmt.Sprintf(`{"isbn":"111","libraryid":%d}`, homeID))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = post(fmt.Sprintf(`{"isbn":"111","libraryid":%d}`, homeID))
+	assert.Equal(t, http.StatusConflict, w.Code)
 }
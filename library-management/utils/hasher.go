@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes and verifies user passwords.
+// Implementations must produce self-describing hashes so several
+// algorithms (e.g. bcrypt today, argon2id later) can coexist in one table.
+type PasswordHasher interface {
+	// Hash returns the encoded hash for a plaintext password
+	Hash(password string) (string, error)
+	// Verify reports whether password matches the encoded hash
+	Verify(hash, password string) (bool, error)
+	// Recognizes reports whether the encoded hash was produced by this algorithm
+	Recognizes(hash string) bool
+	// NeedsRehash reports whether the hash uses outdated parameters
+	NeedsRehash(hash string) bool
+}
+
+// BcryptHasher hashes passwords with bcrypt at the given cost
+type BcryptHasher struct {
+	Cost int
+}
+
+// NewBcryptHasher returns a bcrypt hasher, falling back to the default cost when cost is out of range
+func NewBcryptHasher(cost int) *BcryptHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &BcryptHasher{Cost: cost}
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h *BcryptHasher) Verify(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (h *BcryptHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// passwordHasher is used for every new hash; knownHashers can still verify older hashes.
+// bcrypt verifies a hash of any cost, so legacyHasher covers every bcrypt row.
+var (
+	legacyHasher   PasswordHasher   = NewBcryptHasher(bcrypt.DefaultCost)
+	passwordHasher PasswordHasher   = legacyHasher
+	knownHashers   []PasswordHasher = []PasswordHasher{passwordHasher}
+)
+
+// SetPasswordHasher replaces the hasher used for new passwords.
+// Existing bcrypt hashes keep verifying whatever hasher replaces it.
+func SetPasswordHasher(h PasswordHasher) {
+	passwordHasher = h
+	knownHashers = []PasswordHasher{h, legacyHasher}
+}
+
+// HashPassword hashes a plaintext password with the configured hasher
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+	return passwordHasher.Hash(password)
+}
+
+// CheckPassword compares a login attempt against the stored password.
+// Rows written before hashing was introduced hold the plaintext password; those
+// still match, and rehash is set so the caller can upgrade the row in place.
+// A stored value in the $id$ hash format that no known hasher recognizes never
+// matches, so a hash cannot be used as a password.
+// rehash is also set when the stored hash uses another algorithm or outdated cost.
+func CheckPassword(stored, password string) (match bool, rehash bool) {
+	for _, h := range knownHashers {
+		if !h.Recognizes(stored) {
+			continue
+		}
+		ok, err := h.Verify(stored, password)
+		if err != nil || !ok {
+			return false, false
+		}
+		return true, h != passwordHasher || h.NeedsRehash(stored)
+	}
+
+	// Legacy plaintext row
+	if stored == "" || strings.HasPrefix(stored, "$") || subtle.ConstantTimeCompare([]byte(stored), []byte(password)) != 1 {
+		return false, false
+	}
+	return true, true
+}
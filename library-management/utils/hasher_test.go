@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPassword(t *testing.T) {
+	SetPasswordHasher(NewBcryptHasher(bcrypt.MinCost))
+
+	t.Run("Hash is not the plaintext", func(t *testing.T) {
+		hashed, err := HashPassword("password123")
+		assert.NoError(t, err)
+		assert.NotEqual(t, "password123", hashed)
+		assert.True(t, passwordHasher.Recognizes(hashed))
+	})
+
+	t.Run("Empty password", func(t *testing.T) {
+		_, err := HashPassword("")
+		assert.Error(t, err)
+	})
+}
+
+func TestCheckPassword(t *testing.T) {
+	SetPasswordHasher(NewBcryptHasher(bcrypt.MinCost))
+	hashed, err := HashPassword("password123")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		stored         string
+		password       string
+		expectedMatch  bool
+		expectedRehash bool
+	}{
+		{"Matching hash", hashed, "password123", true, false},
+		{"Wrong password", hashed, "wrongpassword", false, false},
+		{"Legacy plaintext match", "password123", "password123", true, true},
+		{"Legacy plaintext mismatch", "password123", "wrongpassword", false, false},
+		{"Empty stored password", "", "", false, false},
+		{"Unknown hash format", "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$aGFzaA", "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$aGFzaA", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash := CheckPassword(tt.stored, tt.password)
+			assert.Equal(t, tt.expectedMatch, match)
+			assert.Equal(t, tt.expectedRehash, rehash)
+		})
+	}
+
+	t.Run("Outdated cost is rehashed", func(t *testing.T) {
+		SetPasswordHasher(NewBcryptHasher(bcrypt.MinCost + 1))
+		match, rehash := CheckPassword(hashed, "password123")
+		assert.True(t, match)
+		assert.True(t, rehash)
+	})
+
+	t.Run("Hashers are replaced, not stacked", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			SetPasswordHasher(NewBcryptHasher(bcrypt.MinCost))
+		}
+		assert.Len(t, knownHashers, 2)
+		match, _ := CheckPassword(hashed, "password123")
+		assert.True(t, match)
+	})
+}
@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Supported JWT signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SigningKey is one entry of the key set, identified by the kid header.
+// Keys without private material can only verify tokens.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+
+	secret     []byte
+	privateKey crypto.PrivateKey
+	publicKey  crypto.PublicKey
+}
+
+// NewHMACKey returns an HS256 key for a shared secret
+func NewHMACKey(id string, secret []byte) (*SigningKey, error) {
+	if id == "" {
+		return nil, errors.New("key id is required")
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("HS256 key requires a secret")
+	}
+	return &SigningKey{ID: id, Algorithm: AlgHS256, secret: secret}, nil
+}
+
+// ParsePEMKey returns an RS256 or EdDSA key from PEM data.
+// A private key can sign and verify; a public key is accepted for verification only,
+// which is enough for a retired key whose tokens have not expired yet.
+func ParsePEMKey(id, algorithm string, pemData []byte) (*SigningKey, error) {
+	if id == "" {
+		return nil, errors.New("key id is required")
+	}
+
+	key := &SigningKey{ID: id, Algorithm: algorithm}
+	switch algorithm {
+	case AlgRS256:
+		if priv, err := jwt.ParseRSAPrivateKeyFromPEM(pemData); err == nil {
+			key.privateKey, key.publicKey = priv, &priv.PublicKey
+		} else if pub, err := jwt.ParseRSAPublicKeyFromPEM(pemData); err == nil {
+			key.publicKey = pub
+		} else {
+			return nil, fmt.Errorf("key %q: invalid RSA PEM key", id)
+		}
+	case AlgEdDSA:
+		if priv, err := jwt.ParseEdPrivateKeyFromPEM(pemData); err == nil {
+			key.privateKey, key.publicKey = priv, priv.(ed25519.PrivateKey).Public()
+		} else if pub, err := jwt.ParseEdPublicKeyFromPEM(pemData); err == nil {
+			key.publicKey = pub
+		} else {
+			return nil, fmt.Errorf("key %q: invalid Ed25519 PEM key", id)
+		}
+	default:
+		return nil, fmt.Errorf("key %q: unsupported algorithm %q", id, algorithm)
+	}
+	return key, nil
+}
+
+func (k *SigningKey) signingMethod() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+func (k *SigningKey) signingKey() interface{} {
+	if k.Algorithm == AlgHS256 {
+		return k.secret
+	}
+	return k.privateKey
+}
+
+func (k *SigningKey) verificationKey() interface{} {
+	if k.Algorithm == AlgHS256 {
+		return k.secret
+	}
+	return k.publicKey
+}
+
+func (k *SigningKey) canSign() bool {
+	return k.Algorithm == AlgHS256 || k.privateKey != nil
+}
+
+// KeySet holds the key that signs new tokens plus previous keys that are still
+// accepted, so keys can be rotated without logging everybody out.
+type KeySet struct {
+	current *SigningKey
+	keys    map[string]*SigningKey
+	order   []string
+}
+
+// NewKeySet builds a key set; current must be able to sign
+func NewKeySet(current *SigningKey, previous ...*SigningKey) (*KeySet, error) {
+	if current == nil || !current.canSign() {
+		return nil, errors.New("current JWT key must include signing material")
+	}
+
+	ks := &KeySet{current: current, keys: map[string]*SigningKey{}}
+	for _, key := range append([]*SigningKey{current}, previous...) {
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate JWT key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+		ks.order = append(ks.order, key.ID)
+	}
+	return ks, nil
+}
+
+// Current returns the key used to sign new tokens
+func (ks *KeySet) Current() *SigningKey {
+	return ks.current
+}
+
+// keyFunc resolves the verification key from the kid header.
+// Tokens issued before kid headers existed fall back to the current key.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := ks.current
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = ks.keys[kid]; !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.verificationKey(), nil
+}
+
+// JWKS returns the public keys as a JSON Web Key Set.
+// HS256 secrets are never published, so a pure HS256 setup yields an empty set.
+func (ks *KeySet) JWKS() map[string]interface{} {
+	keys := []map[string]string{}
+	for _, id := range ks.order {
+		key := ks.keys[id]
+		switch pub := key.publicKey.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": key.Algorithm,
+				"kid": key.ID,
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"use": "sig",
+				"alg": key.Algorithm,
+				"kid": key.ID,
+				"x":   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// keySet signs and validates every token. The default is the legacy
+// development secret; config refuses to start with it unless
+// jwt.allow_insecure_dev_key is set.
+var keySet = func() *KeySet {
+	key, _ := NewHMACKey("default", []byte("secret_key"))
+	ks, _ := NewKeySet(key)
+	return ks
+}()
+
+// SetKeySet replaces the keys used by GenerateJWT and ParseJWT
+func SetKeySet(ks *KeySet) {
+	keySet = ks
+}
+
+// CurrentKeySet returns the active key set
+func CurrentKeySet() *KeySet {
+	return keySet
+}
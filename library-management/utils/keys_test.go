@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rsaPEM(t *testing.T) []byte {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+}
+
+func ed25519PEM(t *testing.T) []byte {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestKeySetSignAndValidate(t *testing.T) {
+	defer SetKeySet(CurrentKeySet())
+
+	hmacKey, err := NewHMACKey("hs", []byte("test-secret"))
+	assert.NoError(t, err)
+	rsaKey, err := ParsePEMKey("rs", AlgRS256, rsaPEM(t))
+	assert.NoError(t, err)
+	edKey, err := ParsePEMKey("ed", AlgEdDSA, ed25519PEM(t))
+	assert.NoError(t, err)
+
+	for _, key := range []*SigningKey{hmacKey, rsaKey, edKey} {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			ks, err := NewKeySet(key)
+			assert.NoError(t, err)
+			SetKeySet(ks)
+
+			token, err := GenerateJWT(42, "admin")
+			assert.NoError(t, err)
+
+			claims, err := ParseJWT(token)
+			assert.NoError(t, err)
+			assert.Equal(t, uint(42), claims.UserID)
+			assert.Equal(t, "admin", claims.Role)
+			assert.NotEmpty(t, claims.JTI)
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	defer SetKeySet(CurrentKeySet())
+
+	oldKey, err := NewHMACKey("2025", []byte("old-secret"))
+	assert.NoError(t, err)
+	newKey, err := ParsePEMKey("2026", AlgEdDSA, ed25519PEM(t))
+	assert.NoError(t, err)
+
+	oldSet, err := NewKeySet(oldKey)
+	assert.NoError(t, err)
+	SetKeySet(oldSet)
+	oldToken, err := GenerateJWT(1, "user")
+	assert.NoError(t, err)
+
+	rotated, err := NewKeySet(newKey, oldKey)
+	assert.NoError(t, err)
+	SetKeySet(rotated)
+
+	t.Run("Token signed by previous key is still valid", func(t *testing.T) {
+		_, _, err := ValidateJWT(oldToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Token signed by a dropped key is rejected", func(t *testing.T) {
+		ks, err := NewKeySet(newKey)
+		assert.NoError(t, err)
+		SetKeySet(ks)
+
+		_, _, err = ValidateJWT(oldToken)
+		assert.Error(t, err)
+	})
+}
+
+func TestKeySetConstruction(t *testing.T) {
+	t.Run("Duplicate key ids", func(t *testing.T) {
+		a, _ := NewHMACKey("same", []byte("a"))
+		b, _ := NewHMACKey("same", []byte("b"))
+		_, err := NewKeySet(a, b)
+		assert.Error(t, err)
+	})
+
+	t.Run("Public key cannot be current", func(t *testing.T) {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		assert.NoError(t, err)
+
+		key, err := ParsePEMKey("pub", AlgEdDSA, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		assert.NoError(t, err)
+		_, err = NewKeySet(key)
+		assert.Error(t, err)
+	})
+
+	t.Run("Unsupported algorithm", func(t *testing.T) {
+		_, err := ParsePEMKey("es", "ES256", nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	hmacKey, _ := NewHMACKey("hs", []byte("secret"))
+	rsaKey, err := ParsePEMKey("rs", AlgRS256, rsaPEM(t))
+	assert.NoError(t, err)
+	edKey, err := ParsePEMKey("ed", AlgEdDSA, ed25519PEM(t))
+	assert.NoError(t, err)
+
+	ks, err := NewKeySet(rsaKey, edKey, hmacKey)
+	assert.NoError(t, err)
+
+	keys := ks.JWKS()["keys"].([]map[string]string)
+	assert.Len(t, keys, 2) // HS256 secrets are never published
+	assert.Equal(t, "RSA", keys[0]["kty"])
+	assert.Equal(t, "rs", keys[0]["kid"])
+	assert.Equal(t, "OKP", keys[1]["kty"])
+	assert.Equal(t, "Ed25519", keys[1]["crv"])
+}
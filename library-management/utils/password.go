@@ -1,29 +1,51 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// Secret key for signing JWT tokens
-var jwtKey = []byte("secret_key")
+// AccessTokenTTL is how long a token from GenerateJWT stays valid.
+// Kept short because clients renew it with a refresh token.
+var AccessTokenTTL = 15 * time.Minute
+
+// TokenClaims holds the validated contents of an access token
+type TokenClaims struct {
+	UserID    uint
+	Role      string
+	JTI       string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
 
 // GenerateJWT creates a JWT token for a user
 func GenerateJWT(userID uint, role string) (string, error) {
+	jti, err := NewTokenID()
+	if err != nil {
+		return "", errors.New("failed to generate token id")
+	}
+
 	// Define the claims
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"user_id": userID,
 		"role":    role,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(), // Token expires in 1 day
+		"jti":     jti, // Lets the token be revoked before it expires
+		"iat":     now.Unix(),
+		"exp":     now.Add(AccessTokenTTL).Unix(),
 	}
 
-	// Create the token with HS256 signing method
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Sign with the current key and name it in the kid header so validators can pick the right key
+	key := keySet.Current()
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
 
 	// Sign and return the token
-	signedToken, err := token.SignedString(jwtKey)
+	signedToken, err := token.SignedString(key.signingKey())
 	if err != nil {
 		// Return a specific error if signing the token fails
 		return "", errors.New("failed to sign JWT token")
@@ -34,29 +56,58 @@ func GenerateJWT(userID uint, role string) (string, error) {
 
 // ValidateJWT parses and validates a JWT token
 func ValidateJWT(tokenString string) (uint, string, error) {
+	claims, err := ParseJWT(tokenString)
+	if err != nil {
+		return 0, "", err
+	}
+
+	// Return the user ID and role
+	return claims.UserID, claims.Role, nil
+}
+
+// ParseJWT validates a JWT token and returns all of its claims
+func ParseJWT(tokenString string) (*TokenClaims, error) {
 	// Parse the token with claims
 	claims := jwt.MapClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, keySet.keyFunc)
 
 	// Check if parsing failed or token is not valid
 	if err != nil || !token.Valid {
-		return 0, "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	// Extract userID from claims
 	userIDFloat, ok := claims["user_id"].(float64)
 	if !ok {
-		return 0, "", errors.New("invalid user_id")
+		return nil, errors.New("invalid user_id")
 	}
 
 	// Extract role from claims
 	role, ok := claims["role"].(string)
 	if !ok {
-		return 0, "", errors.New("invalid role")
+		return nil, errors.New("invalid role")
 	}
 
-	// Return the user ID and role
-	return uint(userIDFloat), role, nil
+	// Tokens issued before revocation support carry no jti
+	jti, _ := claims["jti"].(string)
+
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+
+	return &TokenClaims{
+		UserID:    uint(userIDFloat),
+		Role:      role,
+		JTI:       jti,
+		IssuedAt:  time.Unix(int64(iat), 0),
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
+
+// NewTokenID returns a random identifier for the jti claim
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }